@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"policeScrapper/internal/browser"
+	"policeScrapper/internal/daemon"
+	"policeScrapper/pkg/clock"
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/egress"
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/scraper"
+	"policeScrapper/pkg/targetlist"
+)
+
+// command is a command of the scraper
+type command struct {
+	name    string
+	usage   string // Arguments and flags after the name
+	summary string
+	raw     bool // Parses its own arguments, e.g. ctl
+}
+
+// commands are the scraper's commands, run is the default
+var commands = []command{
+	{name: "run", usage: "[--interval DURATION] [--no-notify] [--desktop-notify] [--api-addr ADDR]", summary: "Check for slots every interval until stopped"},
+	{name: "serve", usage: "[--interval DURATION] [--no-notify] [--desktop-notify] [--api-addr ADDR]", summary: "Run with the control API also served over HTTP"},
+	{name: "check", usage: "[--once] [--test] [--output text|json|csv] [--no-notify] [--desktop-notify]", summary: "Check once and print the slots found, notifying them with --once"},
+	{name: "verify", usage: "[--no-notify]", summary: "Check the test target once and send a test message through every channel"},
+	{name: "notify-test", usage: "[--channel NAME]", summary: "Send a test message through every notification channel"},
+	{name: "targets", usage: "[--json]", summary: "List the targets and their IDs"},
+	{name: "stats", summary: "Print the check totals and when slots appeared"},
+	{name: "doctor", usage: "[--offline]", summary: "Check Chrome, the state directory, the clock and the network"},
+	{name: "version", summary: "Print the build and platform"},
+	{name: "schema", summary: "Print the JSON schema of check results"},
+	{name: "ctl", usage: "COMMAND [ARGS]", summary: "Control a running scraper, see scraper ctl help", raw: true},
+	{name: "state", usage: "export|import ARCHIVE", summary: "Move the state and configuration to another server", raw: true},
+	{name: "replay", usage: `show --at "YYYY-MM-DD HH:MM"`, summary: "Show what the table looked like at a given time", raw: true},
+	{name: "record", usage: "[-o script.json] [-url URL] [-replay [-steps n]]", summary: "Record or replay the booking flow in a visible browser", raw: true},
+	{name: "debug", usage: "bundle [-n checks] [-o archive.zip]", summary: "Write a debug bundle for bug reports", raw: true},
+}
+
+// options is the parsed command line
+type options struct {
+	command       string
+	args          []string // Arguments of raw commands
+	test          bool     // Check the test target
+	once          bool     // Check once through the daemon, for cron
+	output        string   // Format check prints the result in on stdout, see writeOutput
+	noNotify      bool
+	desktopNotify bool
+	apiAddr       string
+	interval      time.Duration // Overrides the configured interval
+	channel       string        // Only channel notify-test sends to
+	json          bool
+	offline       bool // Skip doctor's network checks
+}
+
+// lookupCommand returns the command called name
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// parseArgs parses the command line, without the program name, printing
+// the usage if it's wrong or asked for. Flags of the run commands may also
+// come before the command, and the positional "test" of older versions
+// still means check --test.
+func parseArgs(args []string) (options, error) {
+	o := options{output: outputText}
+	global := flag.NewFlagSet("scraper", flag.ContinueOnError)
+	global.Usage = func() { printUsage(global.Output()) }
+	runFlags(global, &o)
+	if err := global.Parse(args); err != nil {
+		return o, err
+	}
+	rest := global.Args()
+
+	o.command = "run"
+	if len(rest) > 0 {
+		o.command, rest = rest[0], rest[1:]
+	}
+	switch o.command {
+	case "help":
+		printUsage(os.Stdout)
+		return o, flag.ErrHelp
+	case "test":
+		o.command = "check"
+		o.test = true
+	}
+	c, ok := lookupCommand(o.command)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", o.command)
+		printUsage(os.Stderr)
+		return o, fmt.Errorf("unknown command %q", o.command)
+	}
+	if c.raw {
+		o.args = rest
+		return o, nil
+	}
+
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: scraper %s %s\n\n%s\n", c.name, c.usage, c.summary)
+		fs.PrintDefaults()
+	}
+	switch c.name {
+	case "run", "serve":
+		runFlags(fs, &o)
+	case "check":
+		runFlags(fs, &o)
+		fs.BoolVar(&o.test, "test", o.test, "check the test target instead of the configured ones")
+		fs.BoolVar(&o.once, "once", false, "check like the service does, keeping its state, and exit 0 if slots were found, 1 if none, 2 on error")
+		fs.StringVar(&o.output, "output", outputText, "print the result to stdout as text (only the log), json or csv")
+	case "verify":
+		fs.BoolVar(&o.noNotify, "no-notify", o.noNotify, "don't send the test messages")
+	case "notify-test":
+		fs.StringVar(&o.channel, "channel", "", `only test this channel, e.g. "LINE" or "Email"`)
+	case "targets":
+		fs.BoolVar(&o.json, "json", false, "print the targets as JSON")
+	case "doctor":
+		fs.BoolVar(&o.offline, "offline", false, "skip the clock and network checks")
+	}
+	if err := fs.Parse(rest); err != nil {
+		return o, err
+	}
+	if o.output != outputText && o.output != outputJSON && o.output != outputCSV {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q\n", o.output)
+		fs.Usage()
+		return o, fmt.Errorf("unknown output format %q", o.output)
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return o, fmt.Errorf("unexpected argument %q for %s", fs.Arg(0), c.name)
+	}
+	return o, nil
+}
+
+// runFlags defines the flags of the commands that run checks
+func runFlags(fs *flag.FlagSet, o *options) {
+	fs.BoolVar(&o.noNotify, "no-notify", o.noNotify, "run without sending notifications")
+	fs.BoolVar(&o.desktopNotify, "desktop-notify", o.desktopNotify, "also raise native desktop notifications")
+	fs.StringVar(&o.apiAddr, "api-addr", o.apiAddr, "also serve the control API over HTTP on this address")
+	fs.DurationVar(&o.interval, "interval", o.interval, "time between scheduled checks, e.g. 30s, instead of SCRAPER_INTERVAL")
+}
+
+// printUsage lists the commands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: scraper [--profile NAME] [COMMAND] [FLAGS]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run scraper COMMAND -h for the flags of a command. Without a command, the")
+	fmt.Fprintln(w, "scraper runs; test is short for check --test.")
+}
+
+// checklist collects the pass/fail lines that verify, notify-test and
+// doctor end with
+type checklist struct {
+	lines  []string
+	failed bool
+}
+
+// report adds a passed line, or a failed one if err is set
+func (c *checklist) report(name string, err error, detail string) {
+	if err != nil {
+		c.failed = true
+		c.lines = append(c.lines, fmt.Sprintf("❌ %s: %v", name, err))
+		return
+	}
+	c.lines = append(c.lines, fmt.Sprintf("✅ %s: %s", name, detail))
+}
+
+// skip adds a line for something that couldn't be checked
+func (c *checklist) skip(name, why string) {
+	c.lines = append(c.lines, fmt.Sprintf("⏭ %s: skipped, %s", name, why))
+}
+
+// print prints the lines and returns the exit status, 1 if anything failed
+func (c *checklist) print() int {
+	fmt.Println()
+	for _, line := range c.lines {
+		fmt.Println(line)
+	}
+	if c.failed {
+		return 1
+	}
+	return 0
+}
+
+// testChannels sends a test message from command through every channel
+func testChannels(list *checklist, command string, channels []channel, noNotify bool) {
+	for _, c := range channels {
+		a, ok := c.notifier.(notify.Alerter)
+		switch {
+		case c.err != nil:
+			list.report(c.name, c.err, "")
+		case noNotify:
+			list.skip(c.name, "notifications are disabled")
+		case c.skip != "":
+			list.skip(c.name, c.skip)
+		case !ok:
+			list.skip(c.name, "it can't send alerts")
+		default:
+			list.report(c.name, a.Alert("🧪 Test message from scraper "+command+": "+c.name+" works"), "test message sent")
+		}
+	}
+}
+
+// runNotifyTest sends a test message through every channel, or only the
+// one named, e.g. "Webhook" for all the webhooks
+func runNotifyTest(channels []channel, noNotify bool, name string) int {
+	if name != "" {
+		var names []string
+		var matched []channel
+		for _, c := range channels {
+			names = append(names, c.name)
+			kind, _, _ := strings.Cut(c.name, " ")
+			if strings.EqualFold(c.name, name) || strings.EqualFold(kind, name) {
+				matched = append(matched, c)
+			}
+		}
+		if len(matched) == 0 {
+			fmt.Fprintf(os.Stderr, "No channel %q, configured: %s\n", name, strings.Join(names, ", "))
+			return 2
+		}
+		channels = matched
+	}
+
+	var list checklist
+	testChannels(&list, "notify-test", channels, noNotify)
+	return list.print()
+}
+
+// runTargets prints the targets the scraper monitors, those changed with
+// ctl targets if they were
+func runTargets(cfg config.Config, asJSON bool) int {
+	targets := cfg.Targets
+	if len(targets) == 0 {
+		targets = []config.Target{config.GetTarget(false)}
+	}
+	list, err := targetlist.Load(filepath.Join(cfg.StateDir, "targets.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the changed targets: %v\n", err)
+		return 1
+	}
+	state := list.State()
+	if len(state.Targets) > 0 {
+		targets = state.Targets
+	}
+
+	if asJSON {
+		type target struct {
+			ID string `json:"id"`
+			config.Target
+		}
+		out := make([]target, 0, len(targets))
+		for _, t := range targets {
+			out = append(out, target{ID: t.ID(), Target: t})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if len(state.Targets) > 0 {
+		fmt.Printf("Changed on %s with ctl targets, scraper ctl targets reset goes back to the configured ones\n",
+			state.ChangedAt.Local().Format("2006-01-02 15:04"))
+	}
+	for _, t := range targets {
+		category := t.Category
+		if category == "" {
+			category = "any category"
+		}
+		line := t.ID() + "\t" + t.Location + "\t" + category
+		if t.Critical {
+			line += "\tcritical"
+		}
+		if t.Interval > 0 {
+			line += "\tevery " + t.Interval.String()
+		}
+		fmt.Println(line)
+	}
+	return 0
+}
+
+// runDoctor checks what the scraper needs from the machine it runs on,
+// without checking the site itself like verify does
+func runDoctor(cfg config.Config, offline bool) int {
+	var list checklist
+	detail := "loaded"
+	if cfg.Profile != "" {
+		detail += ", profile " + cfg.Profile
+	}
+	list.report("Configuration", nil, detail)
+	list.report("State directory", checkWritable(cfg.StateDir), cfg.StateDir+" is writable")
+	list.report("Logs directory", checkWritable("logs"), "logs is writable")
+
+	chrome, err := browser.FindChrome()
+	list.report("Chrome", err, chrome)
+
+	if cfg.LineChannelToken == "" || cfg.LineUserID == "" {
+		list.report("LINE", errors.New("LINE_CHANNEL_TOKEN or LINE_USER_ID is missing, notifications are disabled"), "")
+	} else {
+		list.report("LINE", nil, "credentials set, scraper notify-test sends a test message")
+	}
+
+	if offline {
+		list.skip("Clock", "--offline")
+		list.skip("Network", "--offline")
+		return list.print()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	offset, err := clock.Query(ctx, cfg.NTPServer)
+	if err == nil && (offset > cfg.ClockMaxSkew || offset < -cfg.ClockMaxSkew) {
+		err = fmt.Errorf("off by %s from %s, fix the system time", offset.Round(time.Millisecond), cfg.NTPServer)
+	}
+	list.report("Clock", err, fmt.Sprintf("off by %s from %s", offset.Round(time.Millisecond), cfg.NTPServer))
+
+	probe, err := egress.NewMonitor(cfg.EgressIPURL, cfg.BaseURL, cfg.Proxy, 0, nil)
+	if err != nil {
+		list.report("Network", err, "")
+		return list.print()
+	}
+	ip, err := probe.PublicIP(ctx)
+	list.report("Public IP", err, ip)
+	blocked, err := probe.SiteBlocked(ctx)
+	if err == nil && blocked {
+		err = errors.New("the reservation site answers 403 Forbidden, our IP may be blocked")
+	}
+	list.report("Reservation site", err, "reachable")
+	return list.print()
+}
+
+// Formats of check --output
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputCSV  = "csv"
+)
+
+// writeOutput writes the result of a check in format: json is the check
+// result of the published JSON schema, csv a row per slot (see
+// scraper.CSVHeader) and text nothing, the log already has it
+func writeOutput(w io.Writer, format string, result scraper.CheckResult) error {
+	if result.Slots == nil {
+		result.Slots = []scraper.Slot{}
+	}
+	switch format {
+	case outputJSON:
+		return json.NewEncoder(w).Encode(result)
+	case outputCSV:
+		return result.WriteCSV(w)
+	}
+	return nil
+}
+
+// Exit statuses of check --once
+const (
+	exitSlots   = 0
+	exitNoSlots = 1
+	exitError   = 2
+)
+
+// runCheckOnce runs a single check through the daemon, so the state kept
+// between checks (slots already notified, snoozes, history...) carries over
+// from one cron run to the next, and returns the exit status. Slots held
+// for a digest are sent right away, there's no later run to send them.
+func runCheckOnce(d *daemon.Daemon, digest *notify.Digest, output string) int {
+	result, err := d.CheckOnce()
+	if errors.Is(err, daemon.ErrMaintenance) {
+		log.Printf("🔧 Site maintenance, not checking")
+		return exitNoSlots
+	}
+	if digest != nil {
+		if err := digest.Flush(); err != nil {
+			log.Printf("Error sending digest: %v", err)
+		}
+	}
+	if err != nil {
+		return exitError
+	}
+	if err := writeOutput(os.Stdout, output, result); err != nil {
+		log.Printf("Error writing the result: %v", err)
+		return exitError
+	}
+	if len(result.Slots) > 0 {
+		return exitSlots
+	}
+	return exitNoSlots
+}
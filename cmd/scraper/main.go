@@ -1,19 +1,113 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"policeScrapper/internal/api"
 	"policeScrapper/internal/browser"
+	"policeScrapper/internal/ctl"
+	"policeScrapper/internal/daemon"
+	"policeScrapper/pkg/adaptive"
+	"policeScrapper/pkg/booked"
+	"policeScrapper/pkg/bundle"
+	"policeScrapper/pkg/changes"
+	"policeScrapper/pkg/clock"
 	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/cooldown"
+	"policeScrapper/pkg/coord"
+	"policeScrapper/pkg/counters"
+	"policeScrapper/pkg/desktop"
+	"policeScrapper/pkg/discord"
+	"policeScrapper/pkg/egress"
+	"policeScrapper/pkg/email"
+	"policeScrapper/pkg/flow"
+	"policeScrapper/pkg/gchat"
+	"policeScrapper/pkg/history"
 	"policeScrapper/pkg/line"
+	"policeScrapper/pkg/logfmt"
+	"policeScrapper/pkg/matrix"
+	"policeScrapper/pkg/mqtt"
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/pause"
+	"policeScrapper/pkg/rules"
+	"policeScrapper/pkg/scraper"
+	"policeScrapper/pkg/sentry"
+	"policeScrapper/pkg/snooze"
+	"policeScrapper/pkg/state"
+	"policeScrapper/pkg/store"
+	"policeScrapper/pkg/systemd"
+	"policeScrapper/pkg/tablewatch"
+	"policeScrapper/pkg/targetlist"
+	"policeScrapper/pkg/teams"
+	"policeScrapper/pkg/twilio"
+	"policeScrapper/pkg/webhook"
 )
 
+// profile is the config file profile selected with --profile
+var profile string
+
+// cli is the parsed command line
+var cli options
+
+// defaultServeAddr is where serve exposes the control API when
+// SCRAPER_API_ADDR isn't set, only to this machine
+const defaultServeAddr = "127.0.0.1:8080"
+
+// extractProfile removes --profile NAME or --profile=NAME from args, so it
+// can come before any command
+func extractProfile(args []string) (string, []string) {
+	var name string
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--profile" && i+1 < len(args):
+			name = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--profile="):
+			name = strings.TrimPrefix(args[i], "--profile=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return name, rest
+}
+
 func init() {
+	profile, os.Args = extractProfile(os.Args)
+	var err error
+	if cli, err = parseArgs(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+
+	// Only the commands that check log to a file, the others keep their
+	// own output clean
+	switch cli.command {
+	case "run", "serve", "check", "verify", "notify-test":
+	default:
+		return
+	}
+
 	// Create logs directory if it doesn't exist
 	logsDir := "logs"
 	// Fix G301: Reduce directory permissions to 0750
@@ -43,13 +137,44 @@ func init() {
 	}
 
 	// Create a multi-writer to write to both file and stdout
-	multiWriter := io.MultiWriter(os.Stdout, f)
-	log.SetOutput(multiWriter)
+	if cli.output != outputText {
+		logConsole = os.Stderr
+	}
+	setLogOutput(io.MultiWriter(logConsole, f))
 
 	// Log startup message
 	log.Printf("=== Starting new session ===")
 }
 
+// Log destination, the format lines are written in, where else lines go
+// regardless of the destination, e.g. the dashboard's event stream, and
+// the console the daily log file is copied to, stderr when stdout carries
+// the results of check --output
+var (
+	logOutput  io.Writer = os.Stderr
+	logFormat            = logfmt.FormatEmoji
+	logEvents  io.Writer
+	logConsole io.Writer = os.Stdout
+)
+
+// setLogOutput sends the log to w in the current log format
+func setLogOutput(w io.Writer) {
+	logOutput = w
+	if logEvents != nil {
+		w = io.MultiWriter(w, logEvents)
+	}
+	log.SetOutput(logfmt.Writer{W: w, Format: logFormat})
+}
+
+// setLogFormat switches the log format. JSON lines carry their own time.
+func setLogFormat(f logfmt.Format) {
+	logFormat = f
+	if f == logfmt.FormatJSON {
+		log.SetFlags(0)
+	}
+	setLogOutput(logOutput)
+}
+
 // isValidLogPath validates the log file path
 func isValidLogPath(path string) bool {
 	// Get absolute path of logs directory
@@ -98,29 +223,73 @@ func rotateLogFile() {
 	}
 
 	// Create a multi-writer to write to both file and stdout
-	multiWriter := io.MultiWriter(os.Stdout, f)
-	log.SetOutput(multiWriter)
+	setLogOutput(io.MultiWriter(logConsole, f))
 	log.Printf("=== Log rotated to new file ===")
 }
 
-func main() {
-	// Parse command line arguments
-	isTestMode := false
-	noNotify := false
+// newPreferences validates a subscriber's configured preferences
+func newPreferences(s config.Subscription) (notify.Preferences, error) {
+	quiet, err := notify.ParseQuietHours(s.QuietHours, s.TimeZone)
+	if err != nil {
+		return notify.Preferences{}, err
+	}
+	dates, err := notify.ParseDateRange(s.Dates)
+	if err != nil {
+		return notify.Preferences{}, err
+	}
+	lang, err := notify.ParseLanguage(s.Language)
+	if err != nil {
+		return notify.Preferences{}, err
+	}
+	return notify.Preferences{Quiet: quiet, Dates: dates, Romanize: s.Romanize, Language: lang}, nil
+}
 
-	for _, arg := range os.Args[1:] {
-		switch arg {
-		case "test":
-			isTestMode = true
-		case "--no-notify":
-			noNotify = true
-			log.Println("Notifications disabled (--no-notify flag is set)")
+// newEmailSubscribers creates one email subscriber per configured recipient,
+// each with their own preferences
+func newEmailSubscribers(c config.SMTPConfig, names notify.LocationNames, noNotify bool) (notify.Multi, error) {
+	var subscribers notify.Multi
+	for _, r := range c.Recipients {
+		profile, err := notify.ParseProfile(r.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %s: %v", r.Address, err)
 		}
+		prefs, err := newPreferences(r.Subscription)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %s: %v", r.Address, err)
+		}
+		recipients := []email.Recipient{{Address: r.Address, Profile: profile}}
+		subscribers = append(subscribers, notify.Subscriber{
+			Name:     r.Address,
+			Notifier: email.NewClient(c.Host, c.Port, c.Username, c.Password, c.From, recipients, noNotify),
+			Prefs:    prefs,
+			Names:    names,
+		})
 	}
+	return subscribers, nil
+}
+
+// notifiers are the notification channels of a config
+type notifiers struct {
+	notifier  notify.Multi          // Where slots go
+	alerter   notify.Multi          // Where operational alerts go
+	channels  []channel             // Each channel on its own, for verify and notify-test
+	noNotify  bool                  // Set too when the LINE credentials are missing
+	line      *line.Client          // For replies of the LINE bot
+	sms       *twilio.Client        // For calls when slots aren't acknowledged, nil without SMS
+	lineQuota *notify.QuotaFallback // Nil when notifications are off
+}
+
+// newNotifiers creates the notification channels of the config, at startup
+// and again when it's reloaded. MQTT keeps its connection, so it isn't one
+// of them.
+func newNotifiers(cfg config.Config, noNotify, desktopNotify bool) (notifiers, error) {
+	// Desktop notifications are for laptops, which often have no LINE setup,
+	// so only --no-notify turns them off
+	desktopQuiet := noNotify
 
 	// Validate LINE credentials
-	lineToken := "9685aef47a7887752aa10f0e4c2838f2"
-	lineUserID := "@172xcnhzs"
+	lineToken := cfg.LineChannelToken
+	lineUserID := cfg.LineUserID
 	if lineToken == "" || lineUserID == "" {
 		log.Printf("⚠️ LINE credentials not set properly:")
 		if lineToken == "" {
@@ -136,71 +305,1390 @@ func main() {
 			len(lineToken), len(lineUserID))
 	}
 
-	// Get target based on mode
-	target := config.GetTarget(isTestMode)
-	if isTestMode {
-		log.Printf("Running in TEST mode - Looking for slots at %s for %s", target.Location, target.Category)
-	} else {
-		log.Printf("Running in REAL mode - Looking for slots at %s for %s", target.Location, target.Category)
+	// Create email client if recipients are configured
+	locationNames := notify.NewLocationNames(cfg.LocationNames)
+	var guardedEmail *notify.Guarded
+	if len(cfg.SMTP.Recipients) > 0 {
+		subscribers, err := newEmailSubscribers(cfg.SMTP, locationNames, noNotify)
+		if err != nil {
+			log.Printf("⚠️ Email notifications disabled: %v", err)
+		} else {
+			guardedEmail = notify.Guard(subscribers, notify.EmailLimits)
+			log.Printf("✓ Email notifications enabled for %d recipient(s)", len(cfg.SMTP.Recipients))
+		}
+	}
+
+	// Create SMS client if numbers are configured
+	var sms *twilio.Client
+	var guardedSMS *notify.Guarded
+	if len(cfg.Twilio.To) > 0 {
+		sms = twilio.NewClient(cfg.Twilio.AccountSID, cfg.Twilio.AuthToken, cfg.Twilio.From, cfg.Twilio.To, noNotify)
+		guardedSMS = notify.Guard(sms, notify.SMSLimits)
+		log.Printf("✓ SMS notifications enabled for %d number(s)", len(cfg.Twilio.To))
 	}
 
 	// Create LINE client
 	lineClient := line.NewClient(lineToken, lineUserID, noNotify)
+	lineClient.AltTextStyle = cfg.LineAltText
+	// Quick replies come back to the bot, which needs its channel secret
+	lineClient.SnoozeReplies = cfg.LineSecret != ""
+	linePrefs, err := newPreferences(cfg.Line)
+	if err != nil {
+		return notifiers{}, fmt.Errorf("invalid LINE_OPTIONS: %v", err)
+	}
+	var lineNotifier notify.Notifier = notify.Guard(notify.Subscriber{
+		Name:     "LINE",
+		Notifier: lineClient,
+		Prefs:    linePrefs,
+		Names:    locationNames,
+	}, notify.LineLimits)
+
+	// Track the LINE monthly quota, falling back to email or SMS once it's
+	// used up
+	var lineQuota *notify.QuotaFallback
+	if !noNotify {
+		var fallback notify.Multi
+		if guardedEmail != nil && cfg.SMTP.FallbackOnly {
+			fallback = append(fallback, guardedEmail)
+		}
+		if guardedSMS != nil && cfg.Twilio.FallbackOnly {
+			fallback = append(fallback, guardedSMS)
+		}
+		var fallbackNotifier notify.Notifier
+		if len(fallback) > 0 {
+			fallbackNotifier = fallback
+		}
+		lineQuota = notify.NewQuotaFallback(lineNotifier, lineClient, fallbackNotifier)
+		lineNotifier = lineQuota
+	}
+
+	channels := []channel{{name: "LINE", notifier: lineNotifier, limits: notify.LineLimits}}
+	if lineToken == "" || lineUserID == "" {
+		channels[0].err = fmt.Errorf("LINE_CHANNEL_TOKEN or LINE_USER_ID is missing")
+	}
+	if guardedEmail != nil {
+		channels = append(channels, channel{name: "Email", notifier: guardedEmail, limits: notify.EmailLimits})
+	}
+	if guardedSMS != nil {
+		channels = append(channels, channel{name: "SMS", notifier: guardedSMS, limits: notify.SMSLimits})
+	}
+
+	// Operational alerts go to their own LINE user and email addresses, if
+	// set, so the booking ones only get slots
+	adminLine, adminEmail := cfg.AlertLineUserID != "", len(cfg.AlertEmails) > 0
+	alertLine := lineNotifier
+	if adminLine {
+		alertLine = notify.Guard(line.NewClient(lineToken, cfg.AlertLineUserID, noNotify), notify.LineLimits)
+		channels = append(channels, channel{name: "LINE alerts", notifier: alertLine, limits: notify.LineLimits})
+		log.Printf("✓ Operational alerts over LINE go to their own user")
+	}
+	alertEmail := guardedEmail
+	if adminEmail {
+		recipients := make([]email.Recipient, len(cfg.AlertEmails))
+		for i, address := range cfg.AlertEmails {
+			recipients[i] = email.Recipient{Address: address, Profile: notify.ProfileFull}
+		}
+		c := cfg.SMTP
+		alertEmail = notify.Guard(email.NewClient(c.Host, c.Port, c.Username, c.Password, c.From, recipients, noNotify), notify.EmailLimits)
+		channels = append(channels, channel{name: "Email alerts", notifier: alertEmail, limits: notify.EmailLimits})
+		log.Printf("✓ Operational alerts by email go to %d address(es) of their own", len(cfg.AlertEmails))
+	}
+
+	notifier := notify.Multi{lineNotifier}
+	alerter := notify.Multi{}
+	admin := cfg.AlertChannel == "admin"
+	if cfg.AlertChannel == "line" || cfg.AlertChannel == "all" || admin && adminLine {
+		alerter = append(alerter, alertLine)
+	}
+	if guardedEmail != nil && !cfg.SMTP.FallbackOnly {
+		notifier = append(notifier, guardedEmail)
+	}
+	if alertEmail != nil && (cfg.AlertChannel == "email" || cfg.AlertChannel == "all" || admin && adminEmail) {
+		alerter = append(alerter, alertEmail)
+	}
+	if guardedSMS != nil {
+		if !cfg.Twilio.FallbackOnly {
+			notifier = append(notifier, guardedSMS)
+		}
+		if cfg.AlertChannel == "sms" || cfg.AlertChannel == "all" {
+			alerter = append(alerter, guardedSMS)
+		}
+	}
+
+	if cfg.Matrix.RoomID != "" {
+		room := notify.Guard(matrix.NewClient(cfg.Matrix.Homeserver, cfg.Matrix.AccessToken, cfg.Matrix.RoomID, noNotify), notify.MatrixLimits)
+		notifier = append(notifier, room)
+		channels = append(channels, channel{name: "Matrix", notifier: room, limits: notify.MatrixLimits})
+		if cfg.AlertChannel == "all" {
+			alerter = append(alerter, room)
+		}
+		log.Printf("✓ Matrix notifications enabled for %s", cfg.Matrix.RoomID)
+	}
+
+	if cfg.Teams.WebhookURL != "" {
+		teamsChannel := notify.Guard(teams.NewClient(cfg.Teams.WebhookURL, noNotify), notify.TeamsLimits)
+		notifier = append(notifier, teamsChannel)
+		channels = append(channels, channel{name: "Teams", notifier: teamsChannel, limits: notify.TeamsLimits})
+		if cfg.AlertChannel == "all" {
+			alerter = append(alerter, teamsChannel)
+		}
+		log.Printf("✓ Teams notifications enabled")
+	}
+
+	if cfg.GoogleChat.WebhookURL != "" {
+		space := notify.Guard(gchat.NewClient(cfg.GoogleChat.WebhookURL, noNotify), notify.GoogleChatLimits)
+		notifier = append(notifier, space)
+		channels = append(channels, channel{name: "Google Chat", notifier: space, limits: notify.GoogleChatLimits})
+		if cfg.AlertChannel == "all" {
+			alerter = append(alerter, space)
+		}
+		log.Printf("✓ Google Chat notifications enabled")
+	}
+
+	if desktopNotify {
+		popup := notify.Guard(desktop.NewClient(desktopQuiet), notify.DesktopLimits)
+		notifier = append(notifier, popup)
+		channels = append(channels, channel{name: "Desktop", notifier: popup, limits: notify.DesktopLimits})
+		if cfg.AlertChannel == "all" {
+			alerter = append(alerter, popup)
+		}
+		log.Printf("✓ Desktop notifications enabled")
+	}
+
+	for _, wc := range cfg.Webhooks {
+		retries := webhook.DefaultRetries
+		if wc.Retries != nil {
+			retries = *wc.Retries
+		}
+		hook, err := webhook.NewClient(webhook.Config{
+			URL:           wc.URL,
+			Method:        wc.Method,
+			ContentType:   wc.ContentType,
+			Headers:       wc.Headers,
+			Template:      wc.Template,
+			AlertTemplate: wc.AlertTemplate,
+			Retries:       retries,
+			Secret:        wc.Secret,
+			Receipts:      receiptsPath(cfg),
+		}, cfg.BaseURL, noNotify)
+		if err != nil {
+			log.Printf("⚠️ Webhook %s disabled: %v", wc.URL, err)
+			continue
+		}
+		notifier = append(notifier, hook)
+		c := channel{name: "Webhook " + wc.URL, notifier: hook}
+		if wc.AlertTemplate == "" {
+			c.skip = "it has no alert template"
+		}
+		channels = append(channels, c)
+		if cfg.AlertChannel == "all" {
+			alerter = append(alerter, hook)
+		}
+	}
+
+	return notifiers{
+		notifier:  notifier,
+		alerter:   alerter,
+		channels:  channels,
+		noNotify:  noNotify,
+		line:      lineClient,
+		sms:       sms,
+		lineQuota: lineQuota,
+	}, nil
+}
+
+// runState exports or imports the state directory and configuration
+func runState(dir string, args []string) int {
+	const usage = "Usage: scraper state export|import <archive.tar.gz>\n"
+	if len(args) != 2 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "export":
+		f, err := os.OpenFile(args[1], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		err = state.Export(f, dir, config.Environment())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting state: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Exported %s and configuration to %s (contains secrets, keep it private)\n", dir, args[1])
+	case "import":
+		f, err := os.Open(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		restored, err := state.Import(f, dir)
+		for _, name := range restored {
+			fmt.Printf("Restored %s\n", name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing state: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Load the configuration with: source %s\n", filepath.Join(dir, state.ConfigFile))
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	return 0
+}
+
+// historyPath returns the path of the check history log
+func historyPath(cfg config.Config) string {
+	return filepath.Join(cfg.StateDir, "history.jsonl")
+}
+
+// receiptsPath returns the path of the webhook delivery receipts
+func receiptsPath(cfg config.Config) string {
+	return filepath.Join(cfg.StateDir, "webhook-receipts.jsonl")
+}
+
+// prune removes the checks and webhook receipts older than the retention,
+// now and then daily until ctx is cancelled
+func prune(ctx context.Context, cfg config.Config, h *history.Log, st store.Store) {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for {
+		before := time.Now().Add(-cfg.Retention)
+		if n, err := h.Prune(before); err != nil {
+			log.Printf("❌ Failed to prune the check history: %v", err)
+		} else if n > 0 {
+			log.Printf("🧹 Pruned %d check(s) older than %s from the history", n, cfg.Retention)
+		}
+		if st != nil {
+			if n, err := st.Prune(before); err != nil {
+				log.Printf("❌ Failed to prune the store: %v", err)
+			} else if n > 0 {
+				log.Printf("🧹 Pruned %d check(s) older than %s from the store", n, cfg.Retention)
+			}
+		}
+		if n, err := webhook.PruneReceipts(receiptsPath(cfg), before); err != nil {
+			log.Printf("❌ Failed to prune the webhook receipts: %v", err)
+		} else if n > 0 {
+			log.Printf("🧹 Pruned %d webhook receipt(s) older than %s", n, cfg.Retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// countersPath returns the path of the persisted check totals
+func countersPath(cfg config.Config) string {
+	return filepath.Join(cfg.StateDir, "counters.json")
+}
+
+// flowPath returns the path of the recorded booking flow
+func flowPath(cfg config.Config) string {
+	return filepath.Join(cfg.StateDir, "flows", "booking.json")
+}
+
+// runRecord records a click-through of the site in a visible browser as a
+// replayable script, the basis of the booking flow, or replays one to see
+// whether it still works
+func runRecord(cfg config.Config, args []string) int {
+	fs := flag.NewFlagSet("record", flag.ContinueOnError)
+	out := fs.String("o", flowPath(cfg), "script to write, or to replay with -replay")
+	url := fs.String("url", cfg.BaseURL, "page to start from")
+	replay := fs.Bool("replay", false, "replay the script instead of recording one")
+	steps := fs.Int("steps", 0, "replay only the first actions, e.g. to stop before submitting")
+	if fs.Parse(args) != nil || fs.NArg() > 0 || *steps < 0 {
+		fmt.Fprintln(os.Stderr, "Usage: scraper record [-o script.json] [-url URL] [-replay [-steps n]]")
+		return 2
+	}
+	// Validated when the configuration is loaded
+	width, height, _ := config.ParseWindowSize(cfg.WindowSize)
+	opts := browser.Options{URL: *url, Proxy: cfg.Proxy, Locale: cfg.Locale, Width: width, Height: height, Device: cfg.Device}
+
+	// Enter or Ctrl-C ends the recording, as does closing the window
+	stop := make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		enter := make(chan struct{})
+		go func() {
+			_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+			close(enter)
+		}()
+		select {
+		case <-sig:
+		case <-enter:
+		}
+		close(stop)
+	}()
+
+	if *replay {
+		script, err := flow.Load(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if *steps > 0 && *steps < len(script.Actions) {
+			script.Actions = script.Actions[:*steps]
+		}
+		fmt.Fprintf(os.Stderr, "Replaying %d action(s) of %s. Press Enter or close the window when done.\n", len(script.Actions), *out)
+		if err := browser.Replay(opts, script, stop); err != nil {
+			fmt.Fprintf(os.Stderr, "Replay failed: %v\n", err)
+			return 1
+		}
+		fmt.Println("Replayed every action")
+		return 0
+	}
+
+	fmt.Fprintln(os.Stderr, "Click through the site in the browser window. Press Enter or close the window when done.")
+	script, err := browser.Record(opts, stop)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := script.Save(*out); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving the script: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Recorded %d action(s) to %s\n", len(script.Actions), *out)
+	return 0
+}
+
+// runReplay prints what the table looked like at a given time
+func runReplay(cfg config.Config, args []string) int {
+	fs := flag.NewFlagSet("replay show", flag.ContinueOnError)
+	at := fs.String("at", "", `local time to show, e.g. "2024-08-01 09:15"`)
+	if len(args) == 0 || args[0] != "show" || fs.Parse(args[1:]) != nil || *at == "" {
+		fmt.Fprintln(os.Stderr, `Usage: scraper replay show --at "YYYY-MM-DD HH:MM"`)
+		return 2
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", *at, time.Local)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid time %q: expected YYYY-MM-DD HH:MM\n", *at)
+		return 2
+	}
+
+	result, ok, err := checkAt(cfg, t)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		return 1
+	}
+	if !ok {
+		fmt.Printf("No check recorded before %s\n", t.Format("2006-01-02 15:04"))
+		return 1
+	}
+
+	fmt.Printf("As of check at %s (%s before the requested time):\n",
+		result.CheckedAt.Local().Format("2006-01-02 15:04:05"), t.Sub(result.CheckedAt).Round(time.Second))
+	printMatrix(history.NewMatrix(result))
+	return 0
+}
+
+// checkAt returns the last check at or before t, from the store shared by
+// the instances if there's one, else from the local history
+func checkAt(cfg config.Config, t time.Time) (scraper.CheckResult, bool, error) {
+	if cfg.StoreURL == "none" || cfg.StoreURL == "memory:" {
+		return history.Open(historyPath(cfg)).At(t)
+	}
+	st, err := store.Open(cfg.StoreURL, cfg.Coord.Instance)
+	if err != nil {
+		return scraper.CheckResult{}, false, err
+	}
+	defer st.Close()
+	checks, _, err := st.Checks(history.Filter{To: t.Add(time.Nanosecond)}, 0, 1)
+	if err != nil || len(checks) == 0 {
+		return scraper.CheckResult{}, false, err
+	}
+	return checks[0], true, nil
+}
+
+// printMatrix prints the table of a check: each row's cells by status, then
+// ○ under the dates it had open
+func printMatrix(m history.Matrix) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "Location\tCategory\tAvailable\tFull\tClosed")
+	for _, date := range m.Dates {
+		fmt.Fprintf(w, "\t%s", date)
+	}
+	fmt.Fprintln(w)
+	for _, row := range m.Rows {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d", row.Location, row.Category,
+			row.Counts["available"], row.Counts["full"], row.Counts["closed"])
+		for _, date := range m.Dates {
+			cell := "·"
+			if times, ok := row.Open[date]; ok {
+				cell = "○"
+				if len(times) > 0 {
+					cell = strings.Join(times, " ")
+				}
+			}
+			fmt.Fprintf(w, "\t%s", cell)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	if len(m.Rows) == 0 {
+		fmt.Println("No rows recorded")
+	}
+	for _, w := range m.Warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+}
+
+// printResult prints the slots, cell counts and warnings of a check
+func printResult(result scraper.CheckResult) {
+	fmt.Println(result.Summary())
+	for _, slot := range result.Slots {
+		fmt.Printf("  %s\t%s\t%s\n", slot.When(), slot.Location, slot.Category)
+	}
+	if len(result.StatusCounts) > 0 {
+		counts, _ := json.Marshal(result.StatusCounts)
+		fmt.Printf("Cells by status: %s\n", counts)
+	}
+	for _, w := range result.Warnings {
+		fmt.Printf("Warning: %s\n", w)
+	}
+}
+
+// artifactDir returns the directory of the pages and screenshots of the
+// last checks
+func artifactDir() string {
+	return filepath.Join("logs", "checks")
+}
+
+// runDebug writes a debug bundle: the last checks with their logs, pages
+// and screenshots, the redacted configuration and version information
+func runDebug(cfg config.Config, args []string) int {
+	fs := flag.NewFlagSet("debug bundle", flag.ContinueOnError)
+	n := fs.Int("n", 5, "number of checks to include")
+	out := fs.String("o", "", "archive to write (default scraper-debug-<time>.zip)")
+	if len(args) == 0 || args[0] != "bundle" || fs.Parse(args[1:]) != nil || *n < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: scraper debug bundle [-n checks] [-o archive.zip]")
+		return 2
+	}
+	if *out == "" {
+		*out = "scraper-debug-" + time.Now().Format("20060102-150405") + ".zip"
+	}
+
+	checks, _, err := history.Open(historyPath(cfg)).Checks(history.Filter{}, 0, *n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		return 1
+	}
+	configYAML, err := cfg.RedactedYAML()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering configuration: %v\n", err)
+		return 1
+	}
+
+	// The daily logs of the checks, and today's for anything since
+	today := time.Now().Format("2006-01-02")
+	days := []string{today}
+	seen := map[string]bool{today: true}
+	for _, c := range checks {
+		if day := c.CheckedAt.Local().Format("2006-01-02"); !seen[day] {
+			days = append(days, day)
+			seen[day] = true
+		}
+	}
+	sort.Strings(days)
+	var files []bundle.File
+	for _, day := range days {
+		files = append(files, bundle.File{Name: "logs/" + day + ".log", Path: filepath.Join("logs", day+".log"), Text: true})
+	}
+	for _, dir := range browser.ArtifactDirs(artifactDir(), *n) {
+		for _, name := range []string{"page.html", "screenshot.png"} {
+			files = append(files, bundle.File{Name: "checks/" + filepath.Base(dir) + "/" + name, Path: filepath.Join(dir, name)})
+		}
+	}
+	files = append(files, bundle.File{Name: "state/counters.json", Path: countersPath(cfg)})
+
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	err = bundle.Write(f, bundle.Bundle{
+		Version: versionInfo(),
+		Config:  configYAML,
+		Checks:  checks,
+		Files:   files,
+		Redact:  cfg.RedactText,
+	})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing debug bundle: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s with the last %d check(s). Secrets are redacted, but look it over before sharing.\n", *out, len(checks))
+	return 0
+}
+
+// newTracker creates the Sentry client of the config, nil without one
+func newTracker(cfg config.Config) *sentry.Client {
+	if cfg.SentryDSN == "" {
+		return nil
+	}
+	environment := cfg.SentryEnv
+	if environment == "" {
+		environment = cfg.Profile
+	}
+	var release string
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				release = s.Value
+			}
+		}
+	}
+	tracker, err := sentry.New(cfg.SentryDSN, environment, release)
+	if err != nil {
+		log.Printf("⚠️ Error reporting disabled: %v", err)
+		return nil
+	}
+	log.Printf("✓ Panics and repeated check errors are reported to Sentry")
+	return tracker
+}
+
+// versionInfo describes the build and platform
+func versionInfo() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&sb, "module: %s %s\n", info.Main.Path, info.Main.Version)
+		for _, s := range info.Settings {
+			if strings.HasPrefix(s.Key, "vcs.") {
+				fmt.Fprintf(&sb, "%s: %s\n", s.Key, s.Value)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// runStats prints the check totals and statistics computed from the
+// check history
+func runStats(h *history.Log, totalsPath string) int {
+	store, err := counters.Load(totalsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading check totals: %v\n", err)
+		return 1
+	}
+	if totals := store.Totals(); totals.Checks == 0 {
+		fmt.Println("Checks: none counted yet")
+	} else {
+		failed := 0
+		for _, n := range totals.Errors {
+			failed += n
+		}
+		fmt.Printf("Checks since %s: %d (%d failed), %d slot(s) found\n",
+			totals.Since.Local().Format("2006-01-02"), totals.Checks, failed, totals.SlotsFound)
+	}
+
+	stats, err := h.SlotStats()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		return 1
+	}
+	if stats.Slots == 0 {
+		fmt.Println("Slots: none seen yet")
+	} else {
+		printSlotStats(stats)
+	}
+
+	latency, err := h.SlotLatency()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		return 1
+	}
+	if latency.Count == 0 {
+		fmt.Println("Slot lifetime: no slot has come and gone yet")
+		return 0
+	}
+	fmt.Printf("Slot lifetime (%d slots): average %s, p50 %s, p90 %s\n", latency.Count,
+		stats.AvgLifetime.Round(time.Second), latency.P50.Round(time.Second), latency.P90.Round(time.Second))
+	fmt.Println("Half of the slots were gone within the p50, act faster than that to book them.")
+	return 0
+}
+
+// printSlotStats prints when and where slots appeared, with bars to spot
+// the busy hours and days at a glance
+func printSlotStats(stats history.SlotStats) {
+	fmt.Printf("Slots seen appearing: %d\n", stats.Slots)
+
+	fmt.Println("\nBy hour of first sighting (JST):")
+	most := 0
+	for _, n := range stats.ByHour {
+		most = max(most, n)
+	}
+	for hour, n := range stats.ByHour {
+		if n > 0 {
+			fmt.Printf("  %02d:00  %s %d\n", hour, bar(n, most), n)
+		}
+	}
+
+	fmt.Println("\nBy day of the week:")
+	most = 0
+	for _, n := range stats.ByWeekday {
+		most = max(most, n)
+	}
+	for i := 1; i <= 7; i++ {
+		// Monday first, as schedules are planned
+		day := time.Weekday(i % 7)
+		n := stats.ByWeekday[day]
+		fmt.Printf("  %s  %s %d\n", day.String()[:3], bar(n, most), n)
+	}
+
+	fmt.Println("\nBy location:")
+	locations := make([]string, 0, len(stats.ByLocation))
+	for location := range stats.ByLocation {
+		locations = append(locations, location)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		if stats.ByLocation[locations[i]] != stats.ByLocation[locations[j]] {
+			return stats.ByLocation[locations[i]] > stats.ByLocation[locations[j]]
+		}
+		return locations[i] < locations[j]
+	})
+	for _, location := range locations {
+		fmt.Printf("  %s: %d\n", location, stats.ByLocation[location])
+	}
+	fmt.Println()
+}
+
+// bar renders n as a bar of up to 30 characters, full for most
+func bar(n, most int) string {
+	width := n * 30 / most
+	if width == 0 && n > 0 {
+		width = 1
+	}
+	return strings.Repeat("#", width)
+}
+
+// effectiveConfig is the configuration in effect once the config file,
+// profile, environment and flags are resolved, with secrets redacted
+type effectiveConfig struct {
+	Profile      string                 `json:"profile,omitempty"`
+	Mode         string                 `json:"mode"`
+	Targets      []config.Target        `json:"targets"`
+	Interval     string                 `json:"interval"`
+	MaxPages     int                    `json:"max_pages"`
+	PageDelay    string                 `json:"page_delay"`
+	Browser      string                 `json:"browser"`
+	Notify       bool                   `json:"notify"` // False with --no-notify or without LINE credentials
+	Channels     []channelSummary       `json:"channels"`
+	AlertChannel string                 `json:"alert_channel"`
+	Settings     map[string]interface{} `json:"settings"` // Every setting, keyed like the config file
+}
+
+// channelSummary is a notification channel and its limits
+type channelSummary struct {
+	Name      string `json:"name"`
+	MaxSlots  int    `json:"max_slots,omitempty"`
+	PerMinute int    `json:"per_minute,omitempty"`
+}
+
+// newEffectiveConfig collects the effective configuration. Webhook channels
+// are only named by their kind, their URLs are among the redacted settings.
+func newEffectiveConfig(cfg config.Config, mode string, targets []config.Target, channels []channel, extra []string, noNotify bool) (effectiveConfig, error) {
+	settings, err := cfg.Settings()
+	if err != nil {
+		return effectiveConfig{}, err
+	}
+	e := effectiveConfig{
+		Profile:      cfg.Profile,
+		Mode:         mode,
+		Targets:      targets,
+		Interval:     cfg.Interval.String(),
+		MaxPages:     cfg.MaxPages,
+		PageDelay:    cfg.PageDelay.String(),
+		Browser:      "headless Chrome (chromedp)",
+		Notify:       !noNotify,
+		AlertChannel: cfg.AlertChannel,
+		Settings:     settings,
+	}
+	if cfg.Proxy != "" {
+		e.Browser += ", proxy " + config.Config{Proxy: cfg.Proxy}.Redacted().Proxy
+	}
+	if cfg.Locale != "" {
+		e.Browser += ", locale " + cfg.Locale
+	}
+	e.Browser += ", " + cfg.BrowserMode
+	if cfg.SlotTimes {
+		e.Browser += ", slot times"
+	}
+	if cfg.Device != "" {
+		e.Browser += ", emulating " + cfg.Device
+	} else {
+		e.Browser += ", window " + cfg.WindowSize
+	}
+	for _, c := range channels {
+		name := c.name
+		if strings.HasPrefix(name, "Webhook ") {
+			name = "Webhook"
+		}
+		e.Channels = append(e.Channels, channelSummary{Name: name, MaxSlots: c.limits.MaxSlots, PerMinute: c.limits.PerMinute})
+	}
+	for _, name := range extra {
+		e.Channels = append(e.Channels, channelSummary{Name: name})
+	}
+	return e, nil
+}
+
+// logEffectiveConfig logs the effective configuration at startup, so
+// misconfiguration shows right away rather than in how the scraper behaves
+func logEffectiveConfig(e effectiveConfig, cfg config.Config) {
+	log.Printf("=== Effective configuration ===")
+	if e.Profile != "" {
+		log.Printf("Profile: %s", e.Profile)
+	}
+	log.Printf("Mode: %s", e.Mode)
+	for _, t := range e.Targets {
+		category := t.Category
+		if category == "" {
+			category = "any category"
+		}
+		log.Printf("Target: %s, %s", t.Location, category)
+	}
+	log.Printf("Interval: %s, max pages: %d, page delay: %s", e.Interval, e.MaxPages, e.PageDelay)
+	log.Printf("Browser: %s", e.Browser)
+	var names []string
+	for _, c := range e.Channels {
+		switch {
+		case c.MaxSlots > 0:
+			names = append(names, fmt.Sprintf("%s (%d slots/message, %d/min)", c.Name, c.MaxSlots, c.PerMinute))
+		case c.PerMinute > 0:
+			names = append(names, fmt.Sprintf("%s (%d/min)", c.Name, c.PerMinute))
+		default:
+			names = append(names, c.Name)
+		}
+	}
+	if !e.Notify {
+		names = append(names, "notifications disabled")
+	}
+	log.Printf("Channels: %s", strings.Join(names, ", "))
+	log.Printf("Alerts: %s", e.AlertChannel)
+
+	data, err := cfg.RedactedYAML()
+	if err != nil {
+		log.Printf("Error rendering settings: %v", err)
+		return
+	}
+	log.Printf("Settings:")
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		log.Printf("  %s", line)
+	}
+}
+
+// channel is one notification channel, tested on its own by verify and
+// notify-test
+type channel struct {
+	name     string
+	notifier notify.Notifier
+	limits   notify.Limits // Payload and rate limits it's guarded with
+	skip     string        // Why it can't be sent a test message, if it can't
+	err      error         // Why the channel can't work, if it can't
+}
+
+// runVerify checks that a fresh deployment works end to end: it runs one
+// check of the test target, prints what was parsed, sends a test message
+// through every channel and prints a pass/fail line per subsystem
+func runVerify(b *browser.Browser, stateDir string, channels []channel, noNotify bool) int {
+	var list checklist
+	list.report("State directory", checkWritable(stateDir), stateDir+" is writable")
+
+	result, err := b.CheckAvailability()
+	list.report("Browser", err, fmt.Sprintf("checked %d pages in %.1fs", result.PagesChecked, result.Duration.Seconds()))
+	if err == nil {
+		fmt.Println("Parsed table:")
+		printResult(result)
+		switch {
+		case result.PagesChecked == 0:
+			list.report("Parser", fmt.Errorf("no page was parsed"), "")
+		case len(result.Warnings) > 0:
+			list.report("Parser", fmt.Errorf("%d warning(s), see above", len(result.Warnings)), "")
+		default:
+			list.report("Parser", nil, fmt.Sprintf("%d slot(s), no warnings", len(result.Slots)))
+		}
+	}
+
+	testChannels(&list, "verify", channels, noNotify)
+	return list.print()
+}
+
+// checkWritable creates and removes a file in dir
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".verify-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func main() {
+	// Commands describing the build work without a configuration
+	switch cli.command {
+	case "version":
+		fmt.Print(versionInfo())
+		os.Exit(0)
+	case "schema":
+		// Print the published JSON schema and exit
+		if _, err := os.Stdout.Write(scraper.JSONSchema); err != nil {
+			log.Printf("Error writing schema: %v", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	cfg, err := config.Load(profile)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	if cfg.Device != "" {
+		if _, err := browser.LookupDevice(cfg.Device); err != nil {
+			log.Fatalf("Error loading configuration: %v", err)
+		}
+	}
+	var discordKey ed25519.PublicKey
+	if cfg.Discord.PublicKey != "" {
+		if discordKey, err = discord.ParsePublicKey(cfg.Discord.PublicKey); err != nil {
+			log.Fatalf("Error loading configuration: %v", err)
+		}
+	}
+
+	format, err := logfmt.ParseFormat(cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	setLogFormat(format)
+
+	switch cli.command {
+	case "ctl":
+		// Control client mode talks to a running daemon and exits
+		os.Exit(ctl.Run(cfg.SocketPath, cli.args))
+	case "state":
+		// State commands move the scraper's state between servers and exit
+		os.Exit(runState(cfg.StateDir, cli.args))
+	case "replay":
+		// Replay shows recorded checks and exits
+		os.Exit(runReplay(cfg, cli.args))
+	case "debug":
+		// Debug bundles what a bug report needs and exits
+		os.Exit(runDebug(cfg, cli.args))
+	case "record":
+		// Record captures a click-through of the site and exits
+		os.Exit(runRecord(cfg, cli.args))
+	case "stats":
+		// Stats summarizes the recorded checks and exits
+		os.Exit(runStats(history.Open(historyPath(cfg)), countersPath(cfg)))
+	case "targets":
+		os.Exit(runTargets(cfg, cli.json))
+	case "doctor":
+		os.Exit(runDoctor(cfg, cli.offline))
+	}
+
+	if cfg.Profile != "" {
+		log.Printf("Using profile %s of the config file", cfg.Profile)
+	}
+	tracker := newTracker(cfg)
+	defer tracker.Recover()
+
+	// Check once in test mode, which always checks the test target, unless
+	// check --once runs it through the daemon
+	isTestMode := cfg.IsTestMode || cli.test
+	quickCheck := (isTestMode || cli.command == "check") && !cli.once
+	noNotify := cfg.NoNotify || cli.noNotify
+	desktopNotify := cfg.DesktopNotify || cli.desktopNotify
+	if cli.noNotify {
+		log.Println("Notifications disabled (--no-notify flag is set)")
+	}
+	if cli.interval != 0 && cli.interval < config.MinInterval {
+		log.Fatalf("Invalid --interval %s: must be at least %s", cli.interval, config.MinInterval)
+	}
+	applyFlags(&cfg)
+	if err := checkAPIAddr(cfg); err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if cli.command == "verify" {
+		// Verify a deployment with one check of the test target
+		isTestMode = true
+	}
+
+	// Get targets based on mode, test mode always uses the test target
+	targets := cfg.Targets
+	if isTestMode || len(targets) == 0 {
+		targets = []config.Target{config.GetTarget(isTestMode)}
+	}
+	mode := "REAL"
+	if isTestMode {
+		mode = "TEST"
+	}
+	for _, target := range targets {
+		category := target.Category
+		if category == "" {
+			category = "any category"
+		}
+		log.Printf("Running in %s mode - Looking for slots at %s for %s", mode, target.Location, category)
+	}
+
+	notify.ReserveURL = cfg.BaseURL
+
+	n, err := newNotifiers(cfg, noNotify, desktopNotify)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	noNotify = n.noNotify
+	notifier, alerter, channels, sms, lineQuota := n.notifier, n.alerter, n.channels, n.sms, n.lineQuota
+	var mqttClient *mqtt.Client
+	if cfg.MQTT.Broker != "" {
+		mqttClient, err = mqtt.NewClient(mqtt.Config{
+			Broker:   cfg.MQTT.Broker,
+			Topic:    cfg.MQTT.Topic,
+			QoS:      byte(cfg.MQTT.QoS),
+			Username: cfg.MQTT.Username,
+			Password: cfg.MQTT.Password,
+			ClientID: cfg.MQTT.ClientID,
+		}, noNotify)
+		if err != nil {
+			log.Printf("⚠️ MQTT events disabled: %v", err)
+		} else {
+			notifier = append(notifier, mqttClient)
+			log.Printf("✓ MQTT events enabled on %s", cfg.MQTT.Broker)
+		}
+	}
+
+	var extraChannels []string
+	if mqttClient != nil {
+		extraChannels = append(extraChannels, "MQTT")
+	}
+	effective, err := newEffectiveConfig(cfg, mode, targets, channels, extraChannels, noNotify)
+	if err != nil {
+		log.Fatalf("Error resolving the effective configuration: %v", err)
+	}
+	logEffectiveConfig(effective, cfg)
+
+	// Notify-test only needs the channels
+	if cli.command == "notify-test" {
+		os.Exit(runNotifyTest(channels, noNotify, cli.channel))
+	}
 
 	log.Println("Scraper started - press Ctrl+C to stop")
 
+	// Kill Chrome processes left behind by crashed runs before starting ours
+	browser.ReapOrphans()
+
 	// Create browser instance
-	b := browser.New(target, 12) // Check up to 12 pages (24 weeks)
+	if cfg.Proxy != "" {
+		log.Printf("🌐 Routing browser traffic through %s", cfg.Proxy)
+	}
+	width, height, _ := config.ParseWindowSize(cfg.WindowSize)
+	b := browser.New(targets, browser.Options{
+		URL:         cfg.BaseURL,
+		MaxPages:    cfg.MaxPages,
+		PageDelay:   cfg.PageDelay,
+		Proxy:       cfg.Proxy,
+		Locale:      cfg.Locale,
+		Mode:        cfg.BrowserMode,
+		SlotTimes:   cfg.SlotTimes,
+		Width:       width,
+		Height:      height,
+		Device:      cfg.Device,
+		ArtifactDir: artifactDir(),
+	})
 	defer b.Close()
 
-	// For test mode, just do one check and exit
-	if isTestMode {
-		slots, err := b.CheckAvailability()
+	if cli.command == "verify" {
+		os.Exit(runVerify(b, cfg.StateDir, channels, noNotify))
+	}
+
+	// For check and test mode, just do one check and exit
+	if quickCheck {
+		result, err := b.CheckAvailability()
 		if err != nil {
 			log.Printf("Error during test check: %v", err)
 			os.Exit(1)
 		}
-		if len(slots) > 0 {
-			if err := lineClient.NotifyAvailableSlots(slots); err != nil {
+		daemon.LogResult(result)
+		if err := writeOutput(os.Stdout, cli.output, result); err != nil {
+			log.Printf("Error writing the result: %v", err)
+			os.Exit(1)
+		}
+		// Only the test target is notified here. Real slots go through the
+		// daemon, which skips booked, snoozed and already notified ones.
+		if len(result.Slots) > 0 && isTestMode {
+			if err := notifier.NotifyAvailableSlots(result.Slots); err != nil {
 				log.Printf("Error sending test notification: %v", err)
 			}
+		} else if len(result.Slots) > 0 {
+			log.Printf("Not notifying the slots, `check --once` notifies like the service does")
 		}
 		log.Printf("Test check complete")
 		os.Exit(0)
 	}
 
 	// Main loop for normal operation
-	consecutiveErrors := 0
-	for {
-		slots, err := b.CheckAvailability()
-		if err != nil {
-			consecutiveErrors++
-			log.Printf("Error during check: %v", err)
-			// Exponential backoff for consecutive errors
-			backoffDuration := time.Duration(consecutiveErrors*consecutiveErrors) * time.Second
-			if backoffDuration > 5*time.Minute {
-				backoffDuration = 5 * time.Minute // Cap at 5 minutes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	tracker.Go(func() { browser.RunReaper(ctx, time.Hour) })
+
+	// Slots and alerts go through switches, which a reloaded config points
+	// at its channels
+	slotSwitch, alertSwitch := notify.NewSwitch(notifier), notify.NewSwitch(alerter)
+
+	// Call when found slots aren't acknowledged in time
+	var slotNotifier notify.Notifier = slotSwitch
+	var escalation *notify.Escalation
+	// One-shot checks exit before they could call
+	if sms != nil && cfg.Twilio.CallAfter > 0 && !cli.once {
+		escalation = notify.NewEscalation(slotSwitch, sms, cfg.Twilio.CallAfter)
+		slotNotifier = escalation
+		log.Printf("📞 Calling when slots aren't acknowledged within %s", cfg.Twilio.CallAfter)
+	}
+
+	// Route slots through the rules, holding those that aren't urgent for
+	// digests
+	var digest *notify.Digest
+	if len(cfg.Rules) > 0 {
+		ruleset, err := rules.Parse(cfg.Rules)
+		if err != nil {
+			log.Fatalf("Invalid rules: %v", err)
+		}
+		digest = notify.NewDigest(slotSwitch)
+		tracker.Go(func() { digest.Run(ctx, cfg.DigestInterval) })
+		slotNotifier = rules.NewRouter(ruleset, slotNotifier, digest)
+		log.Printf("📏 Routing slots through %d rule(s)", len(ruleset))
+	}
+
+	// Instances sharing a database all check, but only the leader notifies.
+	// One-shot checks don't stay long enough to be elected.
+	var opsAlerter notify.Alerter = alertSwitch
+	var elector *coord.Elector
+	if cfg.Coord.DatabaseURL != "" && !cli.once {
+		elector, err = coord.NewElector(ctx, cfg.Coord.DatabaseURL, cfg.Coord.Instance, cfg.Coord.LeaseTTL)
+		if err != nil {
+			log.Fatalf("Error joining coordinated instances: %v", err)
+		}
+		tracker.Go(func() { elector.Run(ctx) })
+		slotNotifier = coord.LeaderOnly{Notifier: slotNotifier, Elector: elector}
+		opsAlerter = coord.LeaderOnly{Notifier: alertSwitch, Elector: elector}
+		log.Printf("🤝 Coordinating with other instances as %s", cfg.Coord.Instance)
+	}
+
+	d := daemon.New(b, slotNotifier, targets, cfg.Interval)
+	d.Alerter = opsAlerter
+	d.Escalation = escalation
+	if mqttClient != nil {
+		d.CheckDone = mqttClient.PublishStatus
+		if cfg.MQTT.HomeAssistant {
+			if err := mqttClient.PublishDiscovery(cfg.MQTT.DiscoveryPrefix); err != nil {
+				log.Printf("⚠️ Home Assistant discovery failed: %v", err)
+			} else {
+				log.Printf("🏠 Announced sensors to Home Assistant")
 			}
-			log.Printf("Waiting %d seconds before retry (consecutive errors: %d)", int(backoffDuration.Seconds()), consecutiveErrors)
-			time.Sleep(backoffDuration)
-			continue
 		}
-		// Reset error counter on successful check
-		consecutiveErrors = 0
+	}
+	d.History = history.Open(historyPath(cfg))
+	if cfg.StoreURL != "none" {
+		st, err := store.Open(cfg.StoreURL, cfg.Coord.Instance)
+		if err != nil {
+			log.Fatalf("Error opening store: %v", err)
+		}
+		defer st.Close()
+		d.Store = st
+	}
+	totals, err := counters.Load(countersPath(cfg))
+	if err != nil {
+		log.Printf("⚠️ Check totals won't persist: %v", err)
+	} else {
+		d.Counters = totals
+	}
+	if elector != nil && cfg.Coord.Standby {
+		d.Standby = elector.Standby
+	}
+	// Both validated by config.Load
+	d.Maintenance, _ = config.ParseWindows(cfg.Maintenance)
+	d.Quiet, _ = notify.ParseQuietHours(cfg.QuietHours, cfg.QuietTimeZone)
+	if len(cfg.Maintenance) > 0 {
+		log.Printf("🔧 Not checking during the site's maintenance, %s JST", strings.Join(cfg.Maintenance, ", "))
+	}
+	if cfg.QuietHours != "" {
+		log.Printf("🔕 Quiet hours %s: only critical slots are notified", cfg.QuietHours)
+	}
+	d.AlertThreshold = cfg.AlertThreshold
+	d.Tracker = tracker
+	d.AlertWarnings = cfg.AlertWarnings
+	if cfg.NoRowsAlert > 0 {
+		watch, err := tablewatch.Load(filepath.Join(cfg.StateDir, "table-snapshot.json"), cfg.NoRowsAlert)
+		if err != nil {
+			log.Printf("⚠️ Alerts about the table not being parsed disabled: %v", err)
+		} else {
+			d.TableWatch = watch
+		}
+	}
+	bookedFlag, err := booked.Load(filepath.Join(cfg.StateDir, "booked.json"))
+	if err != nil {
+		log.Printf("⚠️ Marking as booked disabled: %v", err)
+	} else {
+		d.Booked = bookedFlag
+		if s := bookedFlag.State(); s.Booked {
+			log.Printf("📕 Booked since %s, slots won't be notified until re-armed", s.Since.Format("2006-01-02"))
+		}
+	}
+	// The test mode always checks the test target
+	if !isTestMode {
+		targetList, err := targetlist.Load(filepath.Join(cfg.StateDir, "targets.json"))
+		if err != nil {
+			log.Printf("⚠️ Target changes won't survive restarts: %v", err)
+		} else {
+			d.TargetList = targetList
+			if restored, err := d.RestoreTargets(); err != nil {
+				log.Printf("⚠️ Failed to restore the changed targets: %v", err)
+			} else if restored {
+				log.Printf("🎯 Monitoring the targets changed on %s, `ctl targets reset` goes back to the configured ones",
+					targetList.State().ChangedAt.Format("2006-01-02 15:04"))
+			}
+		}
+	}
+	pauseState, err := pause.Load(filepath.Join(cfg.StateDir, "paused.json"))
+	if err != nil {
+		log.Printf("⚠️ Pauses won't survive restarts: %v", err)
+	} else {
+		d.PauseState = pauseState
+		if d.RestorePause() {
+			if until := pauseState.State().Until; until.IsZero() {
+				log.Printf("⏸ Still paused, `ctl resume` restarts checks")
+			} else {
+				log.Printf("⏸ Still paused until %s, `ctl resume` restarts checks sooner", until.Format("01/02 15:04"))
+			}
+		}
+	}
+	if cfg.NotifyOn == "new" || cfg.NotifyGone {
+		feed, err := changes.Load(filepath.Join(cfg.StateDir, "open-slots.json"))
+		if err != nil {
+			log.Printf("⚠️ Notifying every open slot, never gone ones: %v", err)
+		} else {
+			d.Changes = feed
+			d.OnlyNew = cfg.NotifyOn == "new"
+			d.NotifyGone = cfg.NotifyGone
+		}
+	}
+	if cfg.NotifyCooldown > 0 {
+		notified, err := cooldown.Load(filepath.Join(cfg.StateDir, "notified.json"), cfg.NotifyCooldown)
+		if err != nil {
+			log.Printf("⚠️ Notification cooldown disabled: %v", err)
+		} else {
+			d.Cooldown = notified
+		}
+	}
+	snoozes, err := snooze.Load(filepath.Join(cfg.StateDir, "snoozes.json"))
+	if err != nil {
+		log.Printf("⚠️ Snoozes disabled: %v", err)
+	} else {
+		d.Snoozes = snoozes
+	}
+	reload := &reloader{
+		d:         d,
+		b:         b,
+		slots:     slotSwitch,
+		alerts:    alertSwitch,
+		mqtt:      mqttClient,
+		mode:      mode,
+		testMode:  isTestMode,
+		cfg:       cfg,
+		lineQuota: lineQuota,
+	}
+	if lineQuota != nil {
+		d.StatusExtras = map[string]func() interface{}{
+			"line_quota": reload.quotaStatus,
+		}
+	}
+	// Only rotate log file at the start of each day
+	d.AfterCheck = rotateLogFile
+
+	if cli.once {
+		code := runCheckOnce(d, digest, cli.output)
+		b.Close()
+		os.Exit(code)
+	}
 
-		if len(slots) > 0 {
-			if err := lineClient.NotifyAvailableSlots(slots); err != nil {
-				log.Printf("Error sending notification: %v", err)
+	if cfg.AdaptiveHot > 0 {
+		cold := cfg.AdaptiveCold
+		if cold == 0 {
+			cold = cfg.Interval
+		}
+		polling := adaptive.New(d.History.SlotStats, cfg.AdaptiveHot, cold, cfg.AdaptiveMin)
+		log.Printf("📈 Adaptive polling: every %s in the hours slots appear most, every %s otherwise", cfg.AdaptiveHot, cold)
+		d.Polling = polling
+		tracker.Go(func() { polling.Run(ctx) })
+	}
+
+	if cfg.EgressInterval > 0 {
+		monitor, err := egress.NewMonitor(cfg.EgressIPURL, cfg.BaseURL, cfg.Proxy, cfg.EgressInterval, opsAlerter)
+		if err != nil {
+			log.Printf("⚠️ Egress check disabled: %v", err)
+		} else {
+			tracker.Go(func() { monitor.Run(ctx) })
+		}
+	}
+
+	if cfg.ClockInterval > 0 {
+		tracker.Go(func() { clock.NewMonitor(cfg.NTPServer, cfg.ClockInterval, cfg.ClockMaxSkew, opsAlerter).Run(ctx) })
+	}
+
+	if cfg.BackupInterval > 0 {
+		backups := &state.Backups{
+			Dir:       cfg.StateDir,
+			BackupDir: cfg.BackupDir,
+			Keep:      cfg.BackupKeep,
+			Env:       config.Environment,
+		}
+		tracker.Go(func() { backups.Run(ctx, cfg.BackupInterval) })
+	}
+
+	if cfg.Retention > 0 {
+		tracker.Go(func() { prune(ctx, cfg, d.History, d.Store) })
+	}
+
+	events := api.NewEvents()
+	if checkDone := d.CheckDone; checkDone != nil {
+		d.CheckDone = func(result scraper.CheckResult, err error) {
+			checkDone(result, err)
+			events.CheckDone(result, err)
+		}
+	} else {
+		d.CheckDone = events.CheckDone
+	}
+	sd := systemd.FromEnv()
+	if sd != nil {
+		checkDone := d.CheckDone
+		d.CheckDone = func(result scraper.CheckResult, err error) {
+			checkDone(result, err)
+			if err := sd.Send("STATUS=" + checkStatus(result, err)); err != nil {
+				log.Printf("Error notifying systemd: %v", err)
 			}
 		}
+	}
+	logEvents = events
+	setLogOutput(logOutput)
+
+	server := api.New(d)
+	server.SetConfig(effective)
+	reload.server = server
+	reload.systemd = sd
+	tracker.Go(func() { reload.run(ctx) })
+	tracker.Go(func() { watchPauseSignals(ctx, d) })
+	server.SetEvents(events)
+	server.SetToken(cfg.APIToken)
+	if cfg.Pprof {
+		server.EnablePprof()
+		log.Printf("✓ Go runtime profiles served at /debug/pprof/ on the control API")
+	}
+	tracker.Go(func() {
+		if err := server.ListenUnix(cfg.SocketPath); err != nil {
+			log.Printf("Error serving control API: %v", err)
+		}
+	})
+	if cfg.APIAddr != "" {
+		if cfg.APIToken == "" {
+			log.Printf("⚠️ The TCP control API has no authentication, set SCRAPER_API_TOKEN to serve it beyond this host")
+		} else {
+			log.Printf("🔒 The TCP control API requires SCRAPER_API_TOKEN")
+		}
+		tracker.Go(func() {
+			if err := server.ListenTCP(cfg.APIAddr); err != nil {
+				log.Printf("Error serving control API: %v", err)
+			}
+		})
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error stopping control API: %v", err)
+		}
+	}()
+
+	if cfg.LineSecret != "" && cfg.PublicAddr == "" {
+		log.Printf("⚠️ LINE bot commands disabled: they're served on SCRAPER_PUBLIC_ADDR, which isn't set")
+	}
+	if (cfg.Slack.SigningSecret != "" || discordKey != nil) && cfg.PublicAddr == "" {
+		log.Printf("⚠️ Slack and Discord commands disabled: they're served on SCRAPER_PUBLIC_ADDR, which isn't set")
+	}
+	if cfg.PublicAddr != "" {
+		locationNames := notify.NewLocationNames(cfg.LocationNames)
+		var liff *api.LIFF
+		if cfg.LIFF.ID != "" {
+			liff = api.NewLIFF(d, cfg.LIFF.ID, cfg.LIFF.ChannelID, cfg.LIFF.AllowedUsers)
+			log.Printf("✓ LIFF mini-app enabled for %d user(s)", len(cfg.LIFF.AllowedUsers))
+		}
+		var bot *api.LineBot
+		if cfg.LineSecret != "" {
+			users := append([]string{cfg.LineUserID}, cfg.LIFF.AllowedUsers...)
+			bot = api.NewLineBot(d, cfg.LineSecret, n.line, users, locationNames)
+			log.Printf("✓ LINE bot commands enabled at /line/webhook")
+		}
+		var slackBot *api.SlackBot
+		if cfg.Slack.SigningSecret != "" {
+			slackBot = api.NewSlackBot(d, cfg.Slack.SigningSecret, cfg.Slack.AllowedUsers, locationNames)
+			log.Printf("✓ Slack commands enabled at /slack/commands for %d user(s)", len(cfg.Slack.AllowedUsers))
+		}
+		var discordBot *api.DiscordBot
+		if discordKey != nil {
+			discordBot = api.NewDiscordBot(d, discordKey, cfg.Discord.AllowedUsers, locationNames)
+			log.Printf("✓ Discord commands enabled at /discord/interactions for %d user(s)", len(cfg.Discord.AllowedUsers))
+		}
+		public := api.NewPublic(d, liff, bot, slackBot, discordBot)
+		tracker.Go(func() {
+			if err := public.ListenTCP(cfg.PublicAddr); err != nil {
+				log.Printf("Error serving status page: %v", err)
+			}
+		})
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := public.Shutdown(shutdownCtx); err != nil {
+				log.Printf("Error stopping status page: %v", err)
+			}
+		}()
+	}
 
-		// Wait 15 minutes before next check
-		nextCheck := time.Now().Add(15 * time.Minute)
-		log.Printf("✓ Check complete. Next check in 15 minutes at %s",
-			nextCheck.Format("15:04:05"))
-		time.Sleep(15 * time.Minute)
+	if err := sd.Send("READY=1", "STATUS=Waiting for the first check"); err != nil {
+		log.Printf("Error notifying systemd: %v", err)
+	}
+	// A check hung inside chromedp, or a stuck schedule, stops the pings
+	tracker.Go(func() {
+		sd.Watchdog(ctx, func() (bool, string) {
+			p := d.Probe()
+			return p.Live, p.Reason
+		})
+	})
+	d.Run(ctx)
+	if err := sd.Send("STOPPING=1"); err != nil {
+		log.Printf("Error notifying systemd: %v", err)
+	}
+	log.Println("Scraper stopped")
+}
 
-		// Only rotate log file at the start of each day
-		rotateLogFile()
+// checkStatus describes a check for systemctl status
+func checkStatus(result scraper.CheckResult, err error) string {
+	if err != nil {
+		return fmt.Sprintf("Last check failed at %s: %v", time.Now().Format("15:04:05"), err)
 	}
+	return fmt.Sprintf("Last check at %s: %d slot(s)", result.CheckedAt.Format("15:04:05"), len(result.Slots))
 }
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"policeScrapper/internal/api"
+	"policeScrapper/internal/browser"
+	"policeScrapper/internal/daemon"
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/mqtt"
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/systemd"
+)
+
+// reloadable are the keys of the config file a reload applies. The others
+// only change with a restart.
+var reloadable = map[string]bool{
+	"targets": true, "interval": true, "maintenance": true, "quiet_hours": true, "quiet_time_zone": true,
+	"max_pages": true, "page_delay": true, "slot_times": true, "device": true,
+	"line_channel_token": true, "line_user_id": true, "line": true, "line_alt_text": true,
+	"no_notify": true, "desktop_notify": true, "location_names": true,
+	"alert_channel": true, "alert_line_user": true, "alert_emails": true,
+	"smtp": true, "twilio": true, "matrix": true, "teams": true, "google_chat": true, "webhooks": true,
+}
+
+// applyFlags overrides the config with the command line flags
+func applyFlags(cfg *config.Config) {
+	if cli.apiAddr != "" {
+		cfg.APIAddr = cli.apiAddr
+	}
+	if cli.interval != 0 {
+		cfg.Interval = cli.interval
+	}
+	if cli.command == "serve" && cfg.APIAddr == "" {
+		// Run as a service controlled over HTTP, the control API on TCP
+		cfg.APIAddr = defaultServeAddr
+	}
+}
+
+// checkAPIAddr refuses to serve the control API over TCP without a token on
+// anything but a loopback address, as anyone reaching it can control the
+// scraper
+func checkAPIAddr(cfg config.Config) error {
+	if cfg.APIAddr == "" || cfg.APIToken != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(cfg.APIAddr)
+	if err != nil {
+		return fmt.Errorf("invalid API address %q: %v", cfg.APIAddr, err)
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+	return fmt.Errorf("the control API on %s isn't on a loopback address, so it needs SCRAPER_API_TOKEN", cfg.APIAddr)
+}
+
+// reloader loads the config again on SIGHUP and applies it without
+// restarting: the targets, interval, maintenance and quiet hours, how the
+// table is read and the notification channels. Chrome keeps running.
+type reloader struct {
+	d        *daemon.Daemon
+	b        *browser.Browser
+	server   *api.Server
+	slots    *notify.Switch    // Where slots go, see newNotifiers
+	alerts   *notify.Switch    // Where operational alerts go
+	mqtt     *mqtt.Client      // Kept for its connection, nil without MQTT
+	systemd  *systemd.Notifier // Told about reloads, nil outside systemd
+	mode     string
+	testMode bool // The test target stays
+
+	mu        sync.Mutex
+	cfg       config.Config // The config in effect
+	lineQuota *notify.QuotaFallback
+}
+
+// run reloads the config on every SIGHUP until ctx is cancelled
+func (r *reloader) run(ctx context.Context) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sig:
+			r.reload()
+		}
+	}
+}
+
+// reload loads the config and applies it, keeping the one in effect if it
+// doesn't load
+func (r *reloader) reload() {
+	log.Printf("🔄 Reloading the configuration")
+	r.notifySystemd("RELOADING=1")
+	defer r.notifySystemd("READY=1")
+	cfg, err := config.Load(profile)
+	if err == nil && cfg.Device != "" {
+		_, err = browser.LookupDevice(cfg.Device)
+	}
+	if err != nil {
+		log.Printf("❌ Configuration not reloaded: %v", err)
+		return
+	}
+	applyFlags(&cfg)
+	n, err := newNotifiers(cfg, cfg.NoNotify || cli.noNotify, cfg.DesktopNotify || cli.desktopNotify)
+	if err != nil {
+		log.Printf("❌ Configuration not reloaded: %v", err)
+		return
+	}
+	var extraChannels []string
+	if r.mqtt != nil {
+		n.notifier = append(n.notifier, r.mqtt)
+		extraChannels = append(extraChannels, "MQTT")
+	}
+
+	r.mu.Lock()
+	previous := r.cfg
+	r.cfg, r.lineQuota = cfg, n.lineQuota
+	r.mu.Unlock()
+
+	r.slots.Set(n.notifier)
+	r.alerts.Set(n.alerter)
+	targets := r.d.Targets()
+	if !r.testMode {
+		targets = cfg.Targets
+		if len(targets) == 0 {
+			targets = []config.Target{config.GetTarget(false)}
+		}
+		if err := r.d.SetConfigured(targets); err != nil {
+			log.Printf("⚠️ Targets not changed: %v", err)
+		}
+	}
+	r.d.SetInterval(cfg.Interval)
+	// Both validated by config.Load
+	maintenance, _ := config.ParseWindows(cfg.Maintenance)
+	r.d.SetMaintenance(maintenance)
+	quiet, _ := notify.ParseQuietHours(cfg.QuietHours, cfg.QuietTimeZone)
+	r.d.SetQuiet(quiet)
+	r.b.SetOptions(browser.Options{
+		MaxPages:  cfg.MaxPages,
+		PageDelay: cfg.PageDelay,
+		SlotTimes: cfg.SlotTimes,
+		Device:    cfg.Device,
+	})
+
+	effective, err := newEffectiveConfig(cfg, r.mode, targets, n.channels, extraChannels, n.noNotify)
+	if err != nil {
+		log.Printf("Error resolving the effective configuration: %v", err)
+	} else {
+		r.server.SetConfig(effective)
+		logEffectiveConfig(effective, cfg)
+	}
+
+	changed, err := config.ChangedKeys(previous, cfg)
+	if err != nil {
+		log.Printf("Error comparing the configurations: %v", err)
+		return
+	}
+	var pending []string
+	for _, key := range changed {
+		if !reloadable[key] {
+			pending = append(pending, key)
+		}
+	}
+	if len(pending) > 0 {
+		log.Printf("⚠️ Restart to apply the changes to %s", strings.Join(pending, ", "))
+	}
+	log.Printf("🔄 Configuration reloaded")
+}
+
+// notifySystemd tells systemd about the reload, if it started the scraper
+func (r *reloader) notifySystemd(state string) {
+	if err := r.systemd.Send(state); err != nil {
+		log.Printf("Error notifying systemd: %v", err)
+	}
+}
+
+// quotaStatus returns the LINE quota of the channels in effect, nil if it
+// isn't tracked
+func (r *reloader) quotaStatus() interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lineQuota == nil {
+		return nil
+	}
+	return r.lineQuota.Status()
+}
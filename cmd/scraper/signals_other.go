@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+
+	"policeScrapper/internal/daemon"
+)
+
+// watchPauseSignals does nothing: there's no SIGUSR1 or SIGUSR2 here, pause
+// over the API instead
+func watchPauseSignals(ctx context.Context, d *daemon.Daemon) {}
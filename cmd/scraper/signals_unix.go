@@ -0,0 +1,32 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"policeScrapper/internal/daemon"
+)
+
+// watchPauseSignals pauses scheduled checks on SIGUSR1 and resumes them on
+// SIGUSR2 until ctx is cancelled
+func watchPauseSignals(ctx context.Context, d *daemon.Daemon) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-sig:
+			if s == syscall.SIGUSR1 {
+				d.Pause()
+			} else {
+				d.Resume()
+			}
+		}
+	}
+}
@@ -4,6 +4,20 @@
 # These values should be set in GitHub Actions repository secrets
 export LINE_CHANNEL_TOKEN="your_line_channel_token"
 export LINE_USER_ID="your_line_user_id"
+# export LINE_CHANNEL_SECRET="your_line_channel_secret"  # chat commands, see README
+
+# Optional /slots slash commands, see README
+# export SLACK_SIGNING_SECRET="your_slack_signing_secret"
+# export SLACK_ALLOWED_USERS="U0123ABCD"
+# export DISCORD_PUBLIC_KEY="your_discord_application_public_key"
+# export DISCORD_ALLOWED_USERS="123456789012345678"
+
+# Optional email notifications ("address[:profile]", profile is full or sms)
+# export SMTP_HOST="smtp.example.com"
+# export SMTP_USERNAME="user"
+# export SMTP_PASSWORD="password"
+# export SMTP_FROM="scraper@example.com"
+# export EMAIL_RECIPIENTS="me@example.com,09012345678@sms.example.ne.jp:sms"
 
 # For local testing only - do not commit actual values
 # Copy this file to config.local.sh for local development 
\ No newline at end of file
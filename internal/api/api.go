@@ -0,0 +1,607 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"policeScrapper/internal/daemon"
+	"policeScrapper/pkg/booked"
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/history"
+	"policeScrapper/pkg/scraper"
+	"policeScrapper/pkg/snooze"
+)
+
+// Controller is the part of the daemon exposed over the API
+type Controller interface {
+	Status() daemon.Status
+	Schedule() daemon.Schedule
+	Probe() daemon.Probe
+	CheckNow(ctx context.Context) (scraper.CheckResult, error)
+	ResetBackoff(ctx context.Context) (scraper.CheckResult, error)
+	Pause()
+	PauseFor(d time.Duration) (time.Time, error)
+	Resume()
+	Sprint(interval, duration time.Duration) (time.Time, error)
+	EndSprint() bool
+	Targets() []config.Target
+	SetTargets(targets []config.Target) error
+	AddTarget(target config.Target) error
+	RemoveTarget(location, category string) error
+	ResetTargets() error
+	Snooze(date string, d time.Duration) (snooze.Entry, error)
+	Unsnooze(date string) error
+	SnoozedDates() []snooze.Entry
+	Acknowledge() bool
+	MarkBooked(note string) (booked.State, error)
+	Rearm() error
+	BookedState() booked.State
+	CheckHistory(f history.Filter, offset, limit int) ([]scraper.CheckResult, int, error)
+	SlotHistory(f history.Filter, offset, limit int) ([]history.SlotRecord, int, error)
+}
+
+// Server serves the control API or the public status page
+type Server struct {
+	name   string
+	ctrl   Controller
+	events *Events // Served at /api/events, see SetEvents
+	mux    *http.ServeMux
+	server *http.Server
+
+	configMu sync.Mutex
+	config   interface{} // Served at /api/config, see SetConfig
+}
+
+// New creates a new API server
+func New(ctrl Controller) *Server {
+	s := &Server{name: "Control API", ctrl: ctrl}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("/api/schedule", s.handleSchedule)
+	mux.HandleFunc("/api/check", s.handleCheck)
+	mux.HandleFunc("/api/reset-backoff", s.handleResetBackoff)
+	mux.HandleFunc("/api/pause", s.handlePause)
+	mux.HandleFunc("/api/resume", s.handleResume)
+	mux.HandleFunc("/api/sprint", s.handleSprint)
+	mux.HandleFunc("/api/targets", s.handleTargets)
+	mux.HandleFunc("/api/targets/reset", s.handleResetTargets)
+	mux.HandleFunc("/api/snoozes", s.handleSnoozes)
+	mux.HandleFunc("/api/ack", s.handleAck)
+	mux.HandleFunc("/api/booked", s.handleBooked)
+	mux.HandleFunc("/api/history", s.handleHistory)
+	mux.HandleFunc("/api/slots", s.handleSlots)
+	mux.HandleFunc("/api/replay", s.handleReplay)
+	mux.HandleFunc("/api/config", s.handleConfig)
+	mux.HandleFunc("/api/v1/targets", s.handleTargetStatus)
+	mux.HandleFunc("/api/v1/targets/", s.handleTargetStatus)
+	mux.HandleFunc("/calendar.ics", calendarHandler(ctrl))
+	mux.Handle("/", dashboardHandler())
+	registerProbes(mux, ctrl)
+	s.mux = mux
+
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// SetConfig sets the effective configuration served at /api/config, again
+// when the config is reloaded. It must not hold secrets.
+func (s *Server) SetConfig(v interface{}) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.config = v
+}
+
+// SetEvents streams the events at /api/events. It must be called before
+// serving.
+func (s *Server) SetEvents(e *Events) {
+	s.events = e
+	s.mux.HandleFunc("/api/events", e.handle)
+}
+
+// EnablePprof serves the Go runtime profiles of net/http/pprof under
+// /debug/pprof/, e.g. to see where memory goes in a long-running daemon. It
+// must be called before serving. Chrome runs in processes of its own, so
+// its memory doesn't show in the profiles.
+func (s *Server) EnablePprof() {
+	s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// ListenUnix serves the API on a unix domain socket until Shutdown is called.
+// Access is controlled by file permissions: only the owner can connect.
+func (s *Server) ListenUnix(path string) error {
+	// Remove a stale socket left behind by a previous run, but never
+	// anything that isn't a socket
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return fmt.Errorf("failed to restrict socket permissions: %v", err)
+	}
+	log.Printf("🔌 %s listening on unix:%s", s.name, path)
+	return s.serve(ln)
+}
+
+// ListenTCP serves the API on a TCP address until Shutdown is called
+func (s *Server) ListenTCP(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("🔌 %s listening on tcp:%s", s.name, ln.Addr())
+	return s.serve(ln)
+}
+
+// Shutdown stops the server, ending the event streams first as they'd
+// never finish on their own
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.events != nil {
+		s.events.close()
+	}
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) serve(ln net.Listener) error {
+	if err := s.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.ctrl.Status())
+}
+
+func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.ctrl.Schedule())
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.configMu.Lock()
+	v := s.config
+	s.configMu.Unlock()
+	if v == nil {
+		writeError(w, http.StatusNotFound, "no configuration to show")
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	result, err := s.ctrl.CheckNow(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleResetBackoff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	result, err := s.ctrl.ResetBackoff(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.ctrl.Pause()
+	writeJSON(w, http.StatusOK, s.ctrl.Status())
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.ctrl.Resume()
+	writeJSON(w, http.StatusOK, s.ctrl.Status())
+}
+
+// Default sprint, checking every 2 minutes for 3 hours
+const (
+	DefaultSprintInterval = 2 * time.Minute
+	DefaultSprintDuration = 3 * time.Hour
+)
+
+// SprintRequest is the body of POST /api/sprint
+type SprintRequest struct {
+	Interval string `json:"interval"` // e.g. 2m, defaults to DefaultSprintInterval
+	Duration string `json:"duration"` // e.g. 3h, defaults to DefaultSprintDuration
+}
+
+func (s *Server) handleSprint(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req SprintRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid JSON body")
+				return
+			}
+		}
+		interval, duration := DefaultSprintInterval, DefaultSprintDuration
+		if req.Interval != "" {
+			d, err := time.ParseDuration(req.Interval)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid interval")
+				return
+			}
+			interval = d
+		}
+		if req.Duration != "" {
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid duration")
+				return
+			}
+			duration = d
+		}
+		if _, err := s.ctrl.Sprint(interval, duration); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, s.ctrl.Status())
+	case http.MethodDelete:
+		s.ctrl.EndSprint()
+		writeJSON(w, http.StatusOK, s.ctrl.Status())
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleTargets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.ctrl.Targets())
+	case http.MethodPut:
+		// The targets replace the monitored ones
+		var targets []config.Target
+		if err := json.NewDecoder(r.Body).Decode(&targets); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if err := config.ValidateTargets(targets); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.writeTargets(w, s.ctrl.SetTargets(targets))
+	case http.MethodPost:
+		// The target is added, or replaces the one of its location and
+		// category
+		var target config.Target
+		if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if err := config.ValidateTargets([]config.Target{target}); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.writeTargets(w, s.ctrl.AddTarget(target))
+	case http.MethodDelete:
+		location := r.URL.Query().Get("location")
+		if location == "" {
+			writeError(w, http.StatusBadRequest, "location is required")
+			return
+		}
+		s.writeTargets(w, s.ctrl.RemoveTarget(location, r.URL.Query().Get("category")))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleResetTargets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.writeTargets(w, s.ctrl.ResetTargets())
+}
+
+// writeTargets answers a change of targets with the monitored targets, or
+// the error of the change
+func (s *Server) writeTargets(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, daemon.ErrFixedTargets):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, daemon.ErrUnknownTarget):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, daemon.ErrNoTargets):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, s.ctrl.Targets())
+	}
+}
+
+// SnoozeRequest is the body of POST /api/snoozes
+type SnoozeRequest struct {
+	Date     string `json:"date"`     // MM/DD
+	Duration string `json:"duration"` // e.g. 24h, defaults to 24h
+}
+
+func (s *Server) handleSnoozes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.ctrl.SnoozedDates())
+	case http.MethodPost:
+		var req SnoozeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		duration := 24 * time.Hour
+		if req.Duration != "" {
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil || d <= 0 {
+				writeError(w, http.StatusBadRequest, "invalid duration")
+				return
+			}
+			duration = d
+		}
+		entry, err := s.ctrl.Snooze(req.Date, duration)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, entry)
+	case http.MethodDelete:
+		if err := s.ctrl.Unsnooze(r.URL.Query().Get("date")); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, s.ctrl.SnoozedDates())
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// AckResponse is the body of POST /api/ack
+type AckResponse struct {
+	Acknowledged bool `json:"acknowledged"` // False if no call was pending
+}
+
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, AckResponse{Acknowledged: s.ctrl.Acknowledge()})
+}
+
+// BookedRequest is the body of POST /api/booked
+type BookedRequest struct {
+	Note string `json:"note"` // e.g. where and when, optional
+}
+
+func (s *Server) handleBooked(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.ctrl.BookedState())
+	case http.MethodPost:
+		var req BookedRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid JSON body")
+				return
+			}
+		}
+		state, err := s.ctrl.MarkBooked(req.Note)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	case http.MethodDelete:
+		if err := s.ctrl.Rearm(); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, s.ctrl.BookedState())
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// Page sizes of history listings
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 500
+)
+
+// HistoryPage is the body of GET /api/history
+type HistoryPage struct {
+	Total   int                   `json:"total"` // Checks matching the filter
+	Page    int                   `json:"page"`
+	PerPage int                   `json:"per_page"`
+	Checks  []scraper.CheckResult `json:"checks"` // Newest first
+}
+
+// SlotPage is the body of GET /api/slots
+type SlotPage struct {
+	Total   int                  `json:"total"` // Slots matching the filter
+	Page    int                  `json:"page"`
+	PerPage int                  `json:"per_page"`
+	Slots   []history.SlotRecord `json:"slots"` // Latest first
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	f, page, perPage, err := parseListing(r, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	checks, total, err := s.ctrl.CheckHistory(f, (page-1)*perPage, perPage)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, HistoryPage{Total: total, Page: page, PerPage: perPage, Checks: checks})
+}
+
+func (s *Server) handleSlots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	f, page, perPage, err := parseListing(r, true)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	slots, total, err := s.ctrl.SlotHistory(f, (page-1)*perPage, perPage)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, SlotPage{Total: total, Page: page, PerPage: perPage, Slots: slots})
+}
+
+// handleReplay serves the table as the last check at or before the time
+// given by at (YYYY-MM-DDTHH:MM in Japan time) saw it
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	v := r.URL.Query().Get("at")
+	at, err := time.ParseInLocation("2006-01-02T15:04", v, config.JST)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid at %q: expected YYYY-MM-DDTHH:MM", v))
+		return
+	}
+	checks, _, err := s.ctrl.CheckHistory(history.Filter{To: at.Add(time.Nanosecond)}, 0, 1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(checks) == 0 {
+		writeError(w, http.StatusNotFound, "no check recorded before "+at.Format("2006-01-02 15:04"))
+		return
+	}
+	writeJSON(w, http.StatusOK, history.NewMatrix(checks[0]))
+}
+
+// parseListing reads the filter and page of a history listing from the
+// query: status, location, category, from and to (YYYY-MM-DD in Japan
+// time, both included), page (from 1) and per_page
+func parseListing(r *http.Request, forSlots bool) (history.Filter, int, int, error) {
+	q := r.URL.Query()
+	f := history.Filter{
+		Status:   q.Get("status"),
+		Location: q.Get("location"),
+		Category: q.Get("category"),
+	}
+	if err := f.Validate(forSlots); err != nil {
+		return history.Filter{}, 0, 0, err
+	}
+	if v := q.Get("from"); v != "" {
+		from, err := time.ParseInLocation("2006-01-02", v, config.JST)
+		if err != nil {
+			return history.Filter{}, 0, 0, fmt.Errorf("invalid from %q: expected YYYY-MM-DD", v)
+		}
+		f.From = from
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.ParseInLocation("2006-01-02", v, config.JST)
+		if err != nil {
+			return history.Filter{}, 0, 0, fmt.Errorf("invalid to %q: expected YYYY-MM-DD", v)
+		}
+		f.To = to.AddDate(0, 0, 1)
+	}
+
+	page, perPage := 1, DefaultPerPage
+	if v := q.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return history.Filter{}, 0, 0, fmt.Errorf("invalid page %q", v)
+		}
+		page = n
+	}
+	if v := q.Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxPerPage {
+			return history.Filter{}, 0, 0, fmt.Errorf("invalid per_page %q: expected 1 to %d", v, MaxPerPage)
+		}
+		perPage = n
+	}
+	return f, page, perPage, nil
+}
+
+// ErrorResponse is the body of failed API requests
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, ErrorResponse{Error: msg})
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// SetToken requires token on requests over TCP, as a bearer token or the
+// basic auth password (any user name), which browsers prompt for. The unix
+// socket is protected by its permissions and probes stay open for container
+// health checks. It must be called before serving.
+func (s *Server) SetToken(token string) {
+	if token == "" {
+		return
+	}
+	next := s.server.Handler
+	s.server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if overUnix(r) || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || authorized(r, token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="policeScrapper", charset="UTF-8"`)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+	})
+}
+
+// overUnix reports whether the request came through the unix socket
+func overUnix(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
+// authorized reports whether the request carries the token
+func authorized(r *http.Request, token string) bool {
+	given := ""
+	if _, password, ok := r.BasicAuth(); ok {
+		given = password
+	} else if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		given = strings.TrimSpace(bearer)
+	}
+	return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
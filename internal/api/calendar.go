@@ -0,0 +1,116 @@
+package api
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"policeScrapper/pkg/clock"
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/ical"
+	"policeScrapper/pkg/scraper"
+)
+
+// calendarRefresh is how often calendar apps are asked to fetch the feed
+// again. Google Calendar takes hours whatever it's told.
+const calendarRefresh = 15 * time.Minute
+
+// calendarHandler serves the slots of the last check as an iCalendar feed,
+// to subscribe to in Google or Apple Calendar: an event per time window of
+// each slot, or a whole-day one when the windows weren't read
+func calendarHandler(src StatusSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		st := src.Status()
+		cal := ical.Calendar{Name: "Slot watcher", Refresh: calendarRefresh, Stamp: st.LastCheck}
+		if cal.Stamp.IsZero() {
+			cal.Stamp = time.Now()
+		}
+		if st.LastResult != nil {
+			now := clock.Now()
+			for _, slot := range st.LastResult.Slots {
+				cal.Events = append(cal.Events, slotEvents(slot, st.LastCheck, now)...)
+			}
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		if err := cal.Write(w); err != nil {
+			log.Printf("Error writing calendar: %v", err)
+		}
+	}
+}
+
+// slotEvents returns the events of a slot found at checkedAt
+func slotEvents(slot scraper.Slot, checkedAt, now time.Time) []ical.Event {
+	day, err := scraper.ParseDate(slot.Date, now)
+	if err != nil {
+		return nil
+	}
+	summary := "Slot at " + slot.Location
+	description := slot.Category
+	if !checkedAt.IsZero() {
+		description += fmt.Sprintf("\nAvailable at %s JST", checkedAt.In(config.JST).Format("01/02 15:04"))
+	}
+
+	var events []ical.Event
+	for _, window := range slot.Times {
+		start, end, ok := parseWindow(day, window)
+		if !ok {
+			continue
+		}
+		events = append(events, ical.Event{
+			UID:         eventUID(slot, window),
+			Start:       start,
+			End:         end,
+			Summary:     summary,
+			Description: description,
+		})
+	}
+	if len(events) == 0 {
+		events = append(events, ical.Event{
+			UID:         eventUID(slot, ""),
+			Start:       day,
+			End:         day.AddDate(0, 0, 1),
+			AllDay:      true,
+			Summary:     summary,
+			Description: description,
+		})
+	}
+	return events
+}
+
+// parseWindow parses a time window like 09:00-10:00 of day, in JST
+func parseWindow(day time.Time, window string) (start, end time.Time, ok bool) {
+	from, to, found := strings.Cut(window, "-")
+	if !found {
+		return time.Time{}, time.Time{}, false
+	}
+	at := func(hhmm string) (time.Time, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(hhmm))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, config.JST), nil
+	}
+	start, err := at(from)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	end, err = at(to)
+	if err != nil || !end.After(start) {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
+
+// eventUID identifies the event of a slot's window across feeds, so
+// calendars update it rather than duplicate it
+func eventUID(slot scraper.Slot, window string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{slot.Location, slot.Category, slot.Date, window}, "\x00")))
+	return fmt.Sprintf("%x@policeScrapper", sum[:12])
+}
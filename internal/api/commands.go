@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/notify"
+)
+
+const botHelp = `使えるコマンド:
+status (状況): 最終チェックと空き枠
+check now (チェック): 今すぐチェック
+pause (停止) / resume (再開): 定期チェックの停止・再開
+pause 2h (停止 2h): 2時間だけ停止
+set location 府中 (場所 府中): 監視する試験場を変更
+snooze 08/02 (スヌーズ 08/02): その日の通知を24時間止める（48h なども指定可）
+ack (確認): 空き枠を確認、電話を取り消し
+booked (予約済み) / rearm (再開通知): 通知の停止・再開`
+
+// commands runs the chat commands of the bots, the same whether they come
+// from LINE, Slack or Discord
+type commands struct {
+	ctrl  Controller
+	names notify.LocationNames
+}
+
+// run runs a chat command received via a bot and returns the reply
+func (c *commands) run(text, via string) string {
+	text = strings.TrimSpace(text)
+	lower := strings.ToLower(text)
+	if location, ok := cutCommand(text, "set location", "場所"); ok {
+		return c.setLocation(location)
+	}
+	if arg, ok := cutCommand(text, "pause", "停止"); ok && arg != "" {
+		return c.pauseFor(arg)
+	}
+	if arg, ok := cutCommand(text, "snooze", "スヌーズ"); ok {
+		return c.snooze(arg)
+	}
+	if note, ok := cutCommand(text, "booked", "予約済み"); ok {
+		if _, err := c.ctrl.MarkBooked(note); err != nil {
+			return "❌ " + err.Error()
+		}
+		return "📌 予約済みにしました。空き枠の通知を止めます（rearm で再開）"
+	}
+
+	switch lower {
+	case "status", "状況":
+		return c.statusText(time.Now())
+	case "check now", "check", "チェック":
+		// A check takes longer than the bots wait for a reply, found slots
+		// are notified as usual
+		go func() {
+			if _, err := c.ctrl.CheckNow(context.Background()); err != nil {
+				log.Printf("⚠️ Check requested over %s failed: %v", via, err)
+			}
+		}()
+		return "🔍 チェックを開始しました。空き枠があれば通知します"
+	case "pause", "停止":
+		c.ctrl.Pause()
+		return "⏸ 定期チェックを停止しました"
+	case "resume", "再開":
+		c.ctrl.Resume()
+		return "▶ 定期チェックを再開しました"
+	case "ack", "確認":
+		if !c.ctrl.Acknowledge() {
+			return "確認待ちの空き枠はありません"
+		}
+		return "👍 確認しました。電話は取り消しました"
+	case "rearm", "再開通知":
+		if err := c.ctrl.Rearm(); err != nil {
+			return "❌ " + err.Error()
+		}
+		return "🔔 空き枠の通知を再開しました"
+	default:
+		return botHelp
+	}
+}
+
+// cutCommand returns the argument of a command with one of the given names
+func cutCommand(text string, names ...string) (string, bool) {
+	for _, name := range names {
+		if len(text) >= len(name) && strings.EqualFold(text[:len(name)], name) {
+			rest := text[len(name):]
+			if rest == "" || rest[0] == ' ' || strings.HasPrefix(rest, "　") {
+				return strings.TrimSpace(strings.TrimPrefix(rest, "　")), true
+			}
+		}
+	}
+	return "", false
+}
+
+// pauseFor pauses scheduled checks for a duration like 2h or 30m
+func (c *commands) pauseFor(arg string) string {
+	d, err := time.ParseDuration(arg)
+	if err != nil || d <= 0 {
+		return "期間を指定してください（例: pause 2h）"
+	}
+	until, err := c.ctrl.PauseFor(d)
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+	return fmt.Sprintf("⏸ %s まで定期チェックを停止します", until.In(config.JST).Format("01/02 15:04"))
+}
+
+// snooze stops notifications about a date, for a day unless a duration
+// follows it, e.g. 08/02 48h
+func (c *commands) snooze(arg string) string {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "日付を指定してください（例: snooze 08/02）"
+	}
+	duration := 24 * time.Hour
+	if len(fields) == 2 {
+		d, err := time.ParseDuration(fields[1])
+		if err != nil || d <= 0 {
+			return "期間を指定してください（例: snooze 08/02 48h）"
+		}
+		duration = d
+	}
+	e, err := c.ctrl.Snooze(fields[0], duration)
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+	return fmt.Sprintf("💤 %s の通知を %s まで止めます", e.Date, e.Until.In(config.JST).Format("01/02 15:04"))
+}
+
+// setLocation watches the given location, for the categories watched now.
+// Each category keeps the settings of its target (critical, interval and
+// depth), the first one if it's watched at several locations.
+func (c *commands) setLocation(name string) string {
+	location := c.resolveLocation(name)
+	if location == "" {
+		return "試験場を指定してください（例: set location 府中）"
+	}
+
+	var targets []config.Target
+	seen := make(map[string]bool)
+	for _, t := range c.ctrl.Targets() {
+		if t.Category == "" {
+			// Every category of the location, covering the others
+			t.Location = location
+			targets = []config.Target{t}
+			break
+		}
+		if seen[t.Category] {
+			continue
+		}
+		seen[t.Category] = true
+		t.Location = location
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		targets = []config.Target{{Location: location}}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Category < targets[j].Category })
+	if err := c.ctrl.SetTargets(targets); err != nil {
+		return "❌ " + err.Error()
+	}
+	return fmt.Sprintf("🎯 %s を監視します（ctl targets reset で設定に戻ります）", location)
+}
+
+// resolveLocation returns the site's name of a location given in part, in
+// Japanese or romanized, e.g. 府中 or fuchu for 府中試験場. Unknown names
+// are taken as they are.
+func (c *commands) resolveLocation(name string) string {
+	lower := strings.ToLower(name)
+	var matches []string
+	for location, romanized := range c.names {
+		if strings.Contains(location, name) || strings.Contains(strings.ToLower(romanized), lower) {
+			matches = append(matches, location)
+		}
+	}
+	if name == "" || len(matches) != 1 {
+		return name
+	}
+	return matches[0]
+}
+
+// statusText summarizes the daemon status for a chat reply
+func (c *commands) statusText(now time.Time) string {
+	st := c.ctrl.Status()
+	var sb strings.Builder
+	if st.LastCheck.IsZero() {
+		sb.WriteString("まだチェックしていません")
+	} else {
+		fmt.Fprintf(&sb, "最終チェック: %s", st.LastCheck.In(config.JST).Format("01/02 15:04"))
+	}
+	switch {
+	case st.Paused && st.PausedUntil != nil:
+		fmt.Fprintf(&sb, "\n⏸ %s まで定期チェック停止中", st.PausedUntil.In(config.JST).Format("01/02 15:04"))
+	case st.Paused:
+		sb.WriteString("\n⏸ 定期チェック停止中")
+	case !st.NextCheck.IsZero() && st.NextCheck.After(now):
+		fmt.Fprintf(&sb, "\n次回: %s", st.NextCheck.In(config.JST).Format("15:04"))
+	}
+	if st.Booked {
+		sb.WriteString("\n📌 予約済み（通知停止中）")
+	}
+	if st.LastError != "" {
+		fmt.Fprintf(&sb, "\n⚠️ エラー %d回連続: %s", st.ConsecutiveErrors, st.LastError)
+	}
+	if st.LastResult != nil {
+		slots := st.LastResult.Slots
+		if len(slots) == 0 {
+			sb.WriteString("\n空き枠なし")
+		} else {
+			fmt.Fprintf(&sb, "\n🎉 空き枠 %d件", len(slots))
+			for i, slot := range slots {
+				if i == 10 {
+					fmt.Fprintf(&sb, "\n他%d件", len(slots)-10)
+					break
+				}
+				fmt.Fprintf(&sb, "\n📅 %s %s", slot.When(), slot.Location)
+			}
+		}
+	}
+	var targets []string
+	for _, t := range st.Targets {
+		targets = append(targets, t.Location)
+	}
+	fmt.Fprintf(&sb, "\n🎯 %s", strings.Join(targets, ", "))
+	return sb.String()
+}
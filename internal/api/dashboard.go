@@ -0,0 +1,23 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// dashboardFiles is the web dashboard, a static page polling /api/status
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// dashboardHandler serves the web dashboard at the root of the control API,
+// for a glance at the last check, the slots of each target, the errors and
+// the recent notifications without reading logs
+func dashboardHandler() http.Handler {
+	files, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		panic(err) // The directory is embedded, it exists
+	}
+	return http.FileServer(http.FS(files))
+}
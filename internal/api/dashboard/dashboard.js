@@ -0,0 +1,245 @@
+// Dashboard of the control API: renders /api/status again after every check
+// pushed on /api/events, polling while the event stream is down
+"use strict";
+
+const refreshEvery = 30 * 1000;
+const logLines = 200;
+
+// live is whether the event stream is connected
+let live = false;
+
+function el(tag, text, className) {
+	const e = document.createElement(tag);
+	if (text !== undefined) {
+		e.textContent = text;
+	}
+	if (className) {
+		e.className = className;
+	}
+	return e;
+}
+
+function targetName(t) {
+	return t.location + (t.category ? " (" + t.category + ")" : "");
+}
+
+// matches tells whether a slot is one of the target's, a target without a
+// category matching every category of its location, and a /regexp/ one
+// those matching (close enough to Go's syntax for display)
+function matches(slot, t) {
+	if (slot.location !== t.location || !t.category) {
+		return slot.location === t.location;
+	}
+	if (t.category.length >= 2 && t.category.startsWith("/") && t.category.endsWith("/")) {
+		try {
+			return new RegExp(t.category.slice(1, -1)).test(slot.category);
+		} catch (e) {
+			return false;
+		}
+	}
+	return slot.category === t.category;
+}
+
+function showStatus(st) {
+	const parts = [];
+	if (st.last_check && !st.last_check.startsWith("0001")) {
+		parts.push("Last checked " + new Date(st.last_check).toLocaleString() + ".");
+	} else {
+		parts.push("No check has completed yet.");
+	}
+	if (st.checking) {
+		parts.push("Checking now.");
+	} else if (st.paused) {
+		parts.push(st.paused_until ? "Paused until " + new Date(st.paused_until).toLocaleString() + "." : "Paused.");
+	} else if (st.next_check && !st.next_check.startsWith("0001")) {
+		parts.push("Next check " + new Date(st.next_check).toLocaleTimeString() + ".");
+	}
+	document.getElementById("status").textContent = parts.join(" ");
+}
+
+// showGrid renders the slots of the last check as a grid of the targets by
+// date, in the order the site lists the dates
+function showGrid(st) {
+	const slots = (st.last_result && st.last_result.slots) || [];
+	const summary = document.getElementById("slots");
+	summary.textContent = slots.length ? slots.length + " slot(s) available." : "No slots available.";
+	summary.className = slots.length ? "found" : "none";
+
+	const grid = document.getElementById("grid");
+	const dates = [...new Set(slots.map(s => s.date))];
+	if (!dates.length) {
+		grid.replaceChildren();
+		return;
+	}
+	const head = el("tr");
+	head.append(el("th", "Target", "target"), ...dates.map(d => el("th", d)));
+	const targets = (st.targets || []).map(t => ({location: t.location, category: t.category}));
+	const rows = targets.map(t => {
+		const row = el("tr");
+		row.append(el("th", targetName(t), "target"));
+		for (const date of dates) {
+			const open = slots.filter(s => s.date === date && matches(s, t));
+			row.append(open.length ? el("td", open.flatMap(s => s.times || []).join(" ") || "✓", "open") : el("td", ""));
+		}
+		return row;
+	});
+	grid.replaceChildren(head, ...rows);
+}
+
+function showHealth(st) {
+	document.getElementById("health").replaceChildren(...(st.health || []).map(h => {
+		const li = el("li", targetName(h) + ": ");
+		li.append(el("span", h.state, h.state), " " + h.score + "%");
+		if (h.reason) {
+			li.append(", " + h.reason);
+		}
+		return li;
+	}));
+}
+
+function showErrors(st) {
+	const counts = st.error_counts || {};
+	const parts = [(counts.timeout || 0) + " timeouts and " + (counts.error || 0) + " errors since start."];
+	if (st.consecutive_errors > 0) {
+		parts.push(st.consecutive_errors + " failed check(s) in a row, last: " + st.last_error);
+	}
+	if (st.backoff) {
+		parts.push("Retrying in " + st.backoff + ".");
+	}
+	const errors = document.getElementById("errors");
+	errors.textContent = parts.join(" ");
+	errors.className = st.consecutive_errors > 0 ? "broken" : "";
+}
+
+function showNotifications(st) {
+	const list = document.getElementById("notifications");
+	const notifications = st.notifications || [];
+	if (!notifications.length) {
+		list.replaceChildren(el("li", "None since the scraper started.", "none"));
+		return;
+	}
+	list.replaceChildren(...notifications.map(n => el("li",
+		new Date(n.at).toLocaleString() + ": " + n.slots.map(s => s.date + " " + targetName(s)).join(", "))));
+}
+
+// showSchedule lists the upcoming checks with why they're due, then the
+// targets whose next check is later than the next check
+function showSchedule(sc) {
+	const checks = [sc.next, ...(sc.upcoming || [])];
+	const items = checks.map(c => el("li", new Date(c.at).toLocaleTimeString() + ": " + c.reason));
+	if (sc.next.reason === "paused" && !(sc.upcoming || []).length) {
+		items.splice(0, items.length, el("li", "Paused, no check until resumed.", "none"));
+	}
+	for (const t of sc.targets || []) {
+		if (t.next !== sc.next.at) {
+			const at = t.next.startsWith("0001") ? "-" : new Date(t.next).toLocaleString();
+			items.push(el("li", targetName(t) + ": " + at + ", " + t.reason, "stale"));
+		}
+	}
+	document.getElementById("schedule").replaceChildren(...items);
+}
+
+// showReplay renders the table as a past check saw it: each row's cells by
+// status, then the dates it had open
+function showReplay(m) {
+	const warnings = (m.warnings || []).length;
+	document.getElementById("replay").textContent = "As of the check at " + new Date(m.checked_at).toLocaleString() +
+		(warnings ? ", with " + warnings + " warning(s)." : ".");
+	const head = el("tr");
+	head.append(el("th", "Target", "target"), el("th", "Available"), el("th", "Full"), el("th", "Closed"),
+		...m.dates.map(d => el("th", d)));
+	const rows = m.rows.map(r => {
+		const row = el("tr");
+		const counts = r.counts || {};
+		row.append(el("th", targetName(r), "target"), el("td", counts.available || 0),
+			el("td", counts.full || 0), el("td", counts.closed || 0));
+		for (const date of m.dates) {
+			const times = (r.open || {})[date];
+			row.append(times ? el("td", times.join(" ") || "✓", "open") : el("td", ""));
+		}
+		return row;
+	});
+	document.getElementById("replay-grid").replaceChildren(head, ...rows);
+}
+
+async function replay(e) {
+	e.preventDefault();
+	const at = document.getElementById("replay-at").value;
+	try {
+		showReplay(await load("api/replay?at=" + encodeURIComponent(at)));
+	} catch (err) {
+		document.getElementById("replay").textContent = "Failed to replay: " + err.message;
+		document.getElementById("replay-grid").replaceChildren();
+	}
+}
+
+async function load(path) {
+	const resp = await fetch(path);
+	const body = await resp.json();
+	if (!resp.ok) {
+		throw new Error(body.error || resp.status);
+	}
+	return body;
+}
+
+async function refresh() {
+	try {
+		const [st, sc] = await Promise.all([load("api/status"), load("api/schedule")]);
+		showStatus(st);
+		showSchedule(sc);
+		showGrid(st);
+		showHealth(st);
+		showErrors(st);
+		showNotifications(st);
+		document.getElementById("error").textContent = "";
+	} catch (e) {
+		document.getElementById("error").textContent = "Failed to load the status: " + e.message;
+	}
+}
+
+// showCheck puts the slot count of the last check in the title, so it shows
+// on the tab too
+function showCheck(check) {
+	document.title = (check.slots ? "(" + check.slots + ") " : "") + "Slot watcher";
+}
+
+function showLog(line) {
+	const log = document.getElementById("log");
+	const follow = log.scrollTop + log.clientHeight >= log.scrollHeight - 5;
+	log.append(line + "\n");
+	while (log.childNodes.length > logLines) {
+		log.firstChild.remove();
+	}
+	if (follow) {
+		log.scrollTop = log.scrollHeight;
+	}
+}
+
+function listen() {
+	if (!window.EventSource) {
+		return;
+	}
+	const events = new EventSource("api/events");
+	events.onopen = () => {
+		live = true;
+		refresh();
+	};
+	events.onerror = () => {
+		// The browser reconnects by itself, poll meanwhile
+		live = false;
+	};
+	events.addEventListener("check", e => {
+		showCheck(JSON.parse(e.data));
+		refresh();
+	});
+	events.addEventListener("log", e => showLog(JSON.parse(e.data)));
+}
+
+document.getElementById("replay-form").addEventListener("submit", replay);
+refresh();
+listen();
+setInterval(() => {
+	if (!live) {
+		refresh();
+	}
+}, refreshEvery);
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"policeScrapper/pkg/discord"
+	"policeScrapper/pkg/notify"
+)
+
+// DiscordBot serves the interactions endpoint of a Discord application, so
+// allowed users control the scraper with its slash command, e.g. /slots
+// status. Requests are authenticated with the application's public key.
+type DiscordBot struct {
+	cmds      commands
+	publicKey ed25519.PublicKey
+	allowed   map[string]bool
+}
+
+// NewDiscordBot creates the interactions endpoint of the Discord application
+// with the given public key, answering the given Discord user IDs
+func NewDiscordBot(ctrl Controller, publicKey ed25519.PublicKey, allowedUsers []string, names notify.LocationNames) *DiscordBot {
+	allowed := make(map[string]bool, len(allowedUsers))
+	for _, id := range allowedUsers {
+		allowed[id] = true
+	}
+	return &DiscordBot{
+		cmds:      commands{ctrl: ctrl, names: names},
+		publicKey: publicKey,
+		allowed:   allowed,
+	}
+}
+
+func (b *DiscordBot) register(mux *http.ServeMux) {
+	mux.HandleFunc("/discord/interactions", b.handleInteraction)
+}
+
+func (b *DiscordBot) handleInteraction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+	if !discord.VerifyRequest(b.publicKey, body, r.Header.Get("X-Signature-Timestamp"), r.Header.Get("X-Signature-Ed25519")) {
+		log.Printf("⚠️ Discord interaction with an invalid signature")
+		writeError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+	var in discord.Interaction
+	if err := json.Unmarshal(body, &in); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	switch in.Type {
+	case discord.InteractionPing:
+		// Sent when the endpoint is saved in the developer portal
+		writeJSON(w, http.StatusOK, discord.Response{Type: discord.ResponsePong})
+	case discord.InteractionApplicationCommand:
+		if user := in.UserID(); !b.allowed[user] {
+			log.Printf("⚠️ Discord command from unknown user %s", user)
+			writeJSON(w, http.StatusOK, discord.Reply("このユーザーはコマンドを使えません"))
+			return
+		}
+		log.Printf("💬 Discord command: /%s %s", in.Data.Name, in.Text())
+		writeJSON(w, http.StatusOK, discord.Reply(b.cmds.run(in.Text(), "Discord")))
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported interaction type")
+	}
+}
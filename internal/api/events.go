@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// eventKeepAlive is how often an idle event stream gets a comment, so
+// proxies don't close it
+const eventKeepAlive = 30 * time.Second
+
+// eventBuffer is how many events a slow listener may lag behind before
+// missing some
+const eventBuffer = 64
+
+// event is a Server-Sent Event
+type event struct {
+	kind string
+	data []byte
+}
+
+// CheckEvent is the data of a check event, sent after every check
+type CheckEvent struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Slots     int       `json:"slots"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Events pushes check results and log lines to the listeners of
+// /api/events, e.g. the dashboard, as Server-Sent Events: "check" events
+// with a CheckEvent after every check and "log" events with each log line
+type Events struct {
+	mu        sync.Mutex
+	listeners map[chan event]bool
+	closed    bool
+}
+
+// NewEvents creates an event stream without listeners
+func NewEvents() *Events {
+	return &Events{listeners: make(map[chan event]bool)}
+}
+
+// CheckDone publishes a check event, it has the signature of the daemon's
+// CheckDone hook
+func (e *Events) CheckDone(result scraper.CheckResult, err error) {
+	ev := CheckEvent{CheckedAt: result.CheckedAt, Slots: len(result.Slots)}
+	if err != nil {
+		ev.CheckedAt, ev.Error = time.Now(), err.Error()
+	}
+	data, jsonErr := json.Marshal(ev)
+	if jsonErr != nil {
+		log.Printf("Error encoding check event: %v", jsonErr)
+		return
+	}
+	e.publish(event{kind: "check", data: data})
+}
+
+// Write publishes a log line, so the log can be sent to the listeners too.
+// It never blocks logging.
+func (e *Events) Write(p []byte) (int, error) {
+	data, err := json.Marshal(strings.TrimRight(string(p), "\n"))
+	if err != nil {
+		return 0, err
+	}
+	e.publish(event{kind: "log", data: data})
+	return len(p), nil
+}
+
+// publish sends an event to every listener, skipping those lagging behind
+func (e *Events) publish(ev event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for ch := range e.listeners {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// close ends every stream, so shutting down doesn't wait for listeners
+func (e *Events) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	for ch := range e.listeners {
+		close(ch)
+		delete(e.listeners, ch)
+	}
+}
+
+func (e *Events) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	ch := make(chan event, eventBuffer)
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+	e.listeners[ch] = true
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		if e.listeners[ch] {
+			delete(e.listeners, ch)
+		}
+		e.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.kind, ev.data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
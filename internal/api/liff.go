@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"policeScrapper/pkg/line"
+)
+
+// LIFF serves a LINE mini-app where allowed users view the current
+// availability and trigger checks from inside LINE. Requests carry the
+// user's LIFF ID token, which is verified with LINE.
+type LIFF struct {
+	ctrl      Controller
+	liffID    string
+	channelID string
+	allowed   map[string]bool
+
+	// verify returns the LINE user ID of an ID token
+	verify func(ctx context.Context, idToken, channelID string) (string, error)
+}
+
+// NewLIFF creates the mini-app of the LIFF app liffID, owned by the LINE
+// Login channel channelID, for the given LINE user IDs
+func NewLIFF(ctrl Controller, liffID, channelID string, allowedUsers []string) *LIFF {
+	allowed := make(map[string]bool, len(allowedUsers))
+	for _, id := range allowedUsers {
+		allowed[id] = true
+	}
+	return &LIFF{
+		ctrl:      ctrl,
+		liffID:    liffID,
+		channelID: channelID,
+		allowed:   allowed,
+		verify:    line.VerifyIDToken,
+	}
+}
+
+func (l *LIFF) register(mux *http.ServeMux) {
+	mux.HandleFunc("/liff/", l.handlePage)
+	mux.HandleFunc("/liff/api/status", l.authorized(l.handleStatus))
+	mux.HandleFunc("/liff/api/check", l.authorized(l.handleCheck))
+}
+
+// authorized rejects requests without the ID token of an allowed user
+func (l *LIFF) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || idToken == "" {
+			writeError(w, http.StatusUnauthorized, "missing ID token")
+			return
+		}
+		userID, err := l.verify(r.Context(), idToken, l.channelID)
+		if err != nil {
+			log.Printf("⚠️ LIFF request rejected: %v", err)
+			writeError(w, http.StatusUnauthorized, "invalid ID token")
+			return
+		}
+		if !l.allowed[userID] {
+			log.Printf("⚠️ LIFF request from unknown user %s", userID)
+			writeError(w, http.StatusForbidden, "not allowed")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (l *LIFF) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/liff/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := liffPage.Execute(w, l.liffID); err != nil {
+		log.Printf("Error rendering LIFF page: %v", err)
+	}
+}
+
+func (l *LIFF) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, l.ctrl.Status())
+}
+
+func (l *LIFF) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	result, err := l.ctrl.CheckNow(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+var liffPage = template.Must(template.New("liff").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>空き枠チェック</title>
+<script src="https://static.line-scdn.net/liff/edge/2/sdk.js"></script>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+button { background: #1DB446; color: #fff; border: 0; border-radius: 4px; padding: .8em 1.5em; font-size: 1em; }
+button:disabled { background: #999; }
+.error { color: #c00; }
+.healthy { color: #1DB446; }
+.stale { color: #C77700; }
+.broken { color: #c00; }
+</style>
+</head>
+<body>
+<h1>空き枠チェック</h1>
+<p id="status">読み込み中…</p>
+<ul id="slots"></ul>
+<ul id="health"></ul>
+<button id="check" disabled>今すぐチェック</button>
+<p id="error" class="error"></p>
+<script>
+const liffID = {{.}};
+
+async function call(method, path) {
+	const resp = await fetch(path, {
+		method: method,
+		headers: { "Authorization": "Bearer " + liff.getIDToken() },
+	});
+	const body = await resp.json();
+	if (!resp.ok) {
+		throw new Error(body.error || resp.status);
+	}
+	return body;
+}
+
+function show(result, checkedAt) {
+	const slots = (result && result.slots) || [];
+	document.getElementById("status").textContent = checkedAt
+		? "最終チェック: " + new Date(checkedAt).toLocaleString() + " / 空き枠 " + slots.length + "件"
+		: "まだチェックしていません";
+	const list = document.getElementById("slots");
+	list.replaceChildren(...slots.map(s => {
+		const li = document.createElement("li");
+		li.textContent = s.date + " " + s.location + " " + s.category;
+		return li;
+	}));
+}
+
+const healthLabels = { healthy: "正常", stale: "要確認", broken: "異常" };
+
+function showHealth(health) {
+	const list = document.getElementById("health");
+	list.replaceChildren(...(health || []).map(h => {
+		const li = document.createElement("li");
+		const state = document.createElement("span");
+		state.className = h.state;
+		state.textContent = healthLabels[h.state] || h.state;
+		li.append(h.location + (h.category ? " " + h.category : "") + ": ", state);
+		if (h.reason) {
+			li.append(" (" + h.reason + ")");
+		}
+		return li;
+	}));
+}
+
+async function refresh() {
+	const st = await call("GET", "/liff/api/status");
+	show(st.last_result, st.last_result ? st.last_check : null);
+	showHealth(st.health);
+}
+
+async function main() {
+	await liff.init({ liffId: liffID });
+	if (!liff.isLoggedIn()) {
+		liff.login();
+		return;
+	}
+	const button = document.getElementById("check");
+	button.addEventListener("click", async () => {
+		button.disabled = true;
+		document.getElementById("error").textContent = "";
+		try {
+			const result = await call("POST", "/liff/api/check");
+			show(result, result.checked_at);
+			showHealth((await call("GET", "/liff/api/status")).health);
+		} catch (e) {
+			document.getElementById("error").textContent = "チェック失敗: " + e.message;
+		}
+		button.disabled = false;
+	});
+	await refresh();
+	button.disabled = false;
+}
+
+main().catch(e => { document.getElementById("error").textContent = e.message; });
+</script>
+</body>
+</html>
+`))
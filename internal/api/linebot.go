@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"policeScrapper/pkg/line"
+	"policeScrapper/pkg/notify"
+)
+
+// maxWebhookBody bounds the webhook requests read, LINE batches few events
+const maxWebhookBody = 1 << 20
+
+// Replier answers LINE webhook events
+type Replier interface {
+	Reply(replyToken, text string) error
+}
+
+// LineBot serves the LINE Messaging API webhook, so allowed users control
+// the scraper by chatting with the bot. Requests are authenticated with the
+// channel secret.
+type LineBot struct {
+	cmds    commands
+	secret  string
+	replier Replier
+	allowed map[string]bool
+}
+
+// NewLineBot creates the webhook of the Messaging API channel with the given
+// secret, answering the given LINE user IDs. Location names, romanized or
+// not, are used to recognize locations in commands.
+func NewLineBot(ctrl Controller, channelSecret string, replier Replier, allowedUsers []string, names notify.LocationNames) *LineBot {
+	allowed := make(map[string]bool, len(allowedUsers))
+	for _, id := range allowedUsers {
+		allowed[id] = true
+	}
+	return &LineBot{
+		cmds:    commands{ctrl: ctrl, names: names},
+		secret:  channelSecret,
+		replier: replier,
+		allowed: allowed,
+	}
+}
+
+func (b *LineBot) register(mux *http.ServeMux) {
+	mux.HandleFunc("/line/webhook", b.handleWebhook)
+}
+
+func (b *LineBot) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+	if !line.VerifySignature(b.secret, body, r.Header.Get("X-Line-Signature")) {
+		log.Printf("⚠️ LINE webhook request with an invalid signature")
+		writeError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+	var wb line.WebhookBody
+	if err := json.Unmarshal(body, &wb); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	for _, event := range wb.Events {
+		if event.Type != "message" || event.Message.Type != "text" || event.ReplyToken == "" {
+			continue
+		}
+		if !b.allowed[event.Source.UserID] {
+			log.Printf("⚠️ LINE command from unknown user %s", event.Source.UserID)
+			continue
+		}
+		log.Printf("💬 LINE command: %s", event.Message.Text)
+		if err := b.replier.Reply(event.ReplyToken, b.cmds.run(event.Message.Text, "LINE")); err != nil {
+			log.Printf("⚠️ LINE reply failed: %v", err)
+		}
+	}
+	writeJSON(w, http.StatusOK, struct{}{})
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"policeScrapper/internal/daemon"
+)
+
+// ProbeSource is the part of the daemon answering container probes
+type ProbeSource interface {
+	Probe() daemon.Probe
+}
+
+// registerProbes serves /healthz, failing once the daemon is wedged so the
+// container is restarted, and /readyz, failing until a recent check
+// succeeded. Both answer with the probe as JSON, 503 when failing.
+func registerProbes(mux *http.ServeMux, src ProbeSource) {
+	probe := func(ok func(daemon.Probe) bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+				return
+			}
+			p := src.Probe()
+			status := http.StatusOK
+			if !ok(p) {
+				status = http.StatusServiceUnavailable
+			}
+			writeJSON(w, status, p)
+		}
+	}
+	mux.HandleFunc("/healthz", probe(func(p daemon.Probe) bool { return p.Live }))
+	mux.HandleFunc("/readyz", probe(func(p daemon.Probe) bool { return p.Ready }))
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"policeScrapper/internal/daemon"
+	"policeScrapper/pkg/health"
+)
+
+// StatusSource is the part of the daemon shown on the public status page
+type StatusSource interface {
+	Status() daemon.Status
+	ProbeSource
+}
+
+// NewPublic creates a server for the read-only status page. It serves
+// nothing but the page, the calendar feed, the container probes, and the
+// LIFF mini-app and LINE, Slack and Discord bot endpoints if set, so it can
+// be shared without exposing the control API.
+func NewPublic(src StatusSource, liff *LIFF, bot *LineBot, slackBot *SlackBot, discordBot *DiscordBot) *Server {
+	mux := http.NewServeMux()
+	if liff != nil {
+		liff.register(mux)
+	}
+	if bot != nil {
+		bot.register(mux)
+	}
+	if slackBot != nil {
+		slackBot.register(mux)
+	}
+	if discordBot != nil {
+		discordBot.register(mux)
+	}
+	registerProbes(mux, src)
+	mux.HandleFunc("/calendar.ics", calendarHandler(src))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := statusPage.Execute(w, newPublicStatus(src.Status(), time.Now())); err != nil {
+			log.Printf("Error rendering status page: %v", err)
+		}
+	})
+
+	return &Server{
+		name: "Status page",
+		server: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// publicStatus is what the status page shows, nothing else of the daemon
+// state is exposed
+type publicStatus struct {
+	Checked   bool
+	Ago       string
+	Paused    bool
+	Slots     int
+	Locations []locationSlots
+	Health    []health.Target
+}
+
+type locationSlots struct {
+	Location string
+	Dates    []string
+}
+
+func newPublicStatus(st daemon.Status, now time.Time) publicStatus {
+	p := publicStatus{Paused: st.Paused, Health: st.Health}
+	if st.LastCheck.IsZero() {
+		return p
+	}
+	p.Checked = true
+	p.Ago = ago(now.Sub(st.LastCheck))
+
+	if st.LastResult == nil {
+		return p
+	}
+	p.Slots = len(st.LastResult.Slots)
+	byLocation := make(map[string][]string)
+	for _, slot := range st.LastResult.Slots {
+		byLocation[slot.Location] = append(byLocation[slot.Location], slot.Date)
+	}
+	for location, dates := range byLocation {
+		p.Locations = append(p.Locations, locationSlots{Location: location, Dates: dates})
+	}
+	sort.Slice(p.Locations, func(i, j int) bool { return p.Locations[i].Location < p.Locations[j].Location })
+	return p
+}
+
+// ago renders a duration as "just now", "5 minutes ago" or "3 hours ago"
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < 2*time.Minute:
+		return "1 minute ago"
+	case d < time.Hour:
+		return fmt.Sprintf("%d minutes ago", int(d.Minutes()))
+	case d < 2*time.Hour:
+		return "1 hour ago"
+	default:
+		return fmt.Sprintf("%d hours ago", int(d.Hours()))
+	}
+}
+
+var statusPage = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>Slot watcher status</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; }
+.none { color: #666; }
+.found { color: #1DB446; }
+.healthy { color: #1DB446; }
+.stale { color: #C77700; }
+.broken { color: #C00; }
+</style>
+</head>
+<body>
+<h1>Slot watcher status</h1>
+{{if .Checked}}
+<p>Last checked {{.Ago}}.{{if .Paused}} Checks are paused.{{end}}</p>
+{{if .Slots}}
+<p class="found">Currently {{.Slots}} slot(s) available:</p>
+<ul>
+{{range .Locations}}<li>{{.Location}}: {{range $i, $d := .Dates}}{{if $i}}, {{end}}{{$d}}{{end}}</li>
+{{end}}</ul>
+{{else}}
+<p class="none">Currently no slots available.</p>
+{{end}}
+{{else}}
+<p class="none">No check has completed yet.</p>
+{{end}}
+{{if .Health}}
+<h2>Targets</h2>
+<ul>
+{{range .Health}}<li>{{.Location}}{{if .Category}} ({{.Category}}){{end}}: <span class="{{.State}}">{{.State}}</span>{{if .Reason}}, {{.Reason}}{{end}}</li>
+{{end}}</ul>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/slack"
+)
+
+// SlackBot serves the Slack slash command, e.g. /slots status, so allowed
+// users control the scraper from Slack. Requests are authenticated with the
+// app's signing secret.
+type SlackBot struct {
+	cmds    commands
+	secret  string
+	allowed map[string]bool
+}
+
+// NewSlackBot creates the slash command endpoint of the Slack app with the
+// given signing secret, answering the given Slack user IDs
+func NewSlackBot(ctrl Controller, signingSecret string, allowedUsers []string, names notify.LocationNames) *SlackBot {
+	allowed := make(map[string]bool, len(allowedUsers))
+	for _, id := range allowedUsers {
+		allowed[id] = true
+	}
+	return &SlackBot{
+		cmds:    commands{ctrl: ctrl, names: names},
+		secret:  signingSecret,
+		allowed: allowed,
+	}
+}
+
+func (b *SlackBot) register(mux *http.ServeMux) {
+	mux.HandleFunc("/slack/commands", b.handleCommand)
+}
+
+func (b *SlackBot) handleCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+	if !slack.VerifyRequest(b.secret, body, r.Header.Get("X-Slack-Request-Timestamp"), r.Header.Get("X-Slack-Signature"), time.Now()) {
+		log.Printf("⚠️ Slack command with an invalid signature")
+		writeError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid form body")
+		return
+	}
+
+	// Slack shows the reply of a command, so unknown users are told off
+	// rather than ignored
+	reply := slack.Response{ResponseType: "ephemeral"}
+	if user := form.Get("user_id"); !b.allowed[user] {
+		log.Printf("⚠️ Slack command from unknown user %s", user)
+		reply.Text = "このユーザーはコマンドを使えません"
+	} else {
+		log.Printf("💬 Slack command: %s %s", form.Get("command"), form.Get("text"))
+		reply.Text = b.cmds.run(form.Get("text"), "Slack")
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"policeScrapper/internal/daemon"
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/health"
+	"policeScrapper/pkg/scraper"
+)
+
+// TargetStatus is the status of one target, for external watchdogs such as
+// Uptime Kuma, which alert on its HTTP status or a keyword like
+// "state":"healthy"
+type TargetStatus struct {
+	ID                string         `json:"id"`
+	Location          string         `json:"location"`
+	Category          string         `json:"category,omitempty"`
+	Critical          bool           `json:"critical,omitempty"`
+	State             string         `json:"state"`            // healthy, stale or broken
+	Score             int            `json:"score"`            // Percentage of the recent checks that read the target
+	Reason            string         `json:"reason,omitempty"` // Why it isn't healthy
+	LastCheck         time.Time      `json:"last_check"`
+	LastRead          time.Time      `json:"last_read,omitempty"` // Last check that read the target's row
+	NextCheck         time.Time      `json:"next_check"`
+	RetryAt           time.Time      `json:"retry_at,omitempty"` // Next check while it's left out of checks
+	Slots             []scraper.Slot `json:"slots"`              // The target's slots in the last check
+	LastError         string         `json:"last_error,omitempty"`
+	ConsecutiveErrors int            `json:"consecutive_errors"` // Checks of all targets failed in a row
+}
+
+// newTargetStatuses returns the status of every target
+func newTargetStatuses(st daemon.Status) []TargetStatus {
+	statuses := make([]TargetStatus, 0, len(st.Targets))
+	for _, t := range st.Targets {
+		ts := TargetStatus{
+			ID:                t.ID(),
+			Location:          t.Location,
+			Category:          t.Category,
+			Critical:          t.Critical,
+			State:             health.Stale,
+			Reason:            "not checked yet",
+			LastCheck:         st.LastCheck,
+			NextCheck:         st.NextCheck,
+			Slots:             []scraper.Slot{},
+			ConsecutiveErrors: st.ConsecutiveErrors,
+		}
+		for _, h := range st.Health {
+			if h.Location == t.Location && h.Category == t.Category {
+				ts.State, ts.Score, ts.Reason = h.State, h.Score, h.Reason
+				ts.LastRead, ts.RetryAt = h.LastRead, h.RetryAt
+				break
+			}
+		}
+		if st.LastResult != nil {
+			ts.Slots = targetSlots(t, st.LastResult.Slots)
+		}
+		if st.ConsecutiveErrors > 0 {
+			ts.LastError = st.LastError
+		}
+		statuses = append(statuses, ts)
+	}
+	return statuses
+}
+
+// targetSlots returns the target's slots
+func targetSlots(t config.Target, slots []scraper.Slot) []scraper.Slot {
+	matched := []scraper.Slot{}
+	for _, slot := range slots {
+		if t.Matches(slot.Location, slot.Category) {
+			matched = append(matched, slot)
+		}
+	}
+	return matched
+}
+
+// handleTargetStatus serves /api/v1/targets, the status of every target, and
+// /api/v1/targets/{id}/status, that of one target: 200 while it's healthy,
+// 503 otherwise, so plain HTTP monitors alert on it
+func (s *Server) handleTargetStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	statuses := newTargetStatuses(s.ctrl.Status())
+	if r.URL.Path == "/api/v1/targets" {
+		writeJSON(w, http.StatusOK, statuses)
+		return
+	}
+
+	id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/targets/"), "/status")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	for _, ts := range statuses {
+		if ts.ID != id {
+			continue
+		}
+		status := http.StatusOK
+		if ts.State != health.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, ts)
+		return
+	}
+	writeError(w, http.StatusNotFound, "unknown target "+id+", see /api/v1/targets")
+}
@@ -0,0 +1,93 @@
+package browser
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ArtifactsKept is how many checks keep their page artifacts
+const ArtifactsKept = 20
+
+// artifactTimeout bounds capturing the artifacts, even of a hung page
+const artifactTimeout = 10 * time.Second
+
+// saveArtifacts writes the page's HTML and a screenshot, as the check that
+// started at start left it, to a directory of its own under the artifact
+// directory, and drops the oldest beyond ArtifactsKept. It returns the
+// directory, or "" if not saved. Artifacts are for debugging, so failing to
+// save them is only logged.
+func (b *Browser) saveArtifacts(tab context.Context, start time.Time) string {
+	if b.opts.ArtifactDir == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(tab, artifactTimeout)
+	defer cancel()
+
+	var html string
+	var screenshot []byte
+	if err := chromedp.Run(ctx,
+		chromedp.OuterHTML(`html`, &html, chromedp.ByQuery),
+		chromedp.CaptureScreenshot(&screenshot),
+	); err != nil {
+		log.Printf("⚠️ Failed to capture check artifacts: %v", err)
+		return ""
+	}
+
+	dir := filepath.Join(b.opts.ArtifactDir, start.Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		log.Printf("⚠️ Failed to save check artifacts: %v", err)
+		return ""
+	}
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), []byte(html), 0600); err != nil {
+		log.Printf("⚠️ Failed to save check artifacts: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "screenshot.png"), screenshot, 0600); err != nil {
+		log.Printf("⚠️ Failed to save check artifacts: %v", err)
+	}
+	pruneArtifacts(b.opts.ArtifactDir, ArtifactsKept)
+	return dir
+}
+
+// ArtifactDirs returns the artifact directories of the last n checks,
+// oldest first
+func ArtifactDirs(root string, n int) []string {
+	dirs := listArtifacts(root)
+	if len(dirs) > n {
+		dirs = dirs[len(dirs)-n:]
+	}
+	return dirs
+}
+
+// pruneArtifacts removes all but the last keep artifact directories
+func pruneArtifacts(root string, keep int) {
+	dirs := listArtifacts(root)
+	for len(dirs) > keep {
+		if err := os.RemoveAll(dirs[0]); err != nil {
+			log.Printf("⚠️ Failed to remove old check artifacts: %v", err)
+		}
+		dirs = dirs[1:]
+	}
+}
+
+// listArtifacts returns the artifact directories under root, oldest first
+func listArtifacts(root string) []string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, filepath.Join(root, e.Name()))
+		}
+	}
+	// Names are timestamps, so they sort by time
+	sort.Strings(dirs)
+	return dirs
+}
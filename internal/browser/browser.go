@@ -2,65 +2,389 @@ package browser
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
-	"errors"
-	"encoding/base64"
 
+	"policeScrapper/pkg/clock"
 	"policeScrapper/pkg/config"
 	"policeScrapper/pkg/scraper"
 
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
 )
 
+// checkTimeout is the deadline of a single check. The watchdog gives up on
+// a check after twice this long.
+const checkTimeout = 60 * time.Second
+
+// ErrHung is returned when the watchdog aborts a check that stopped responding.
+// It is a timeout, so it matches context.DeadlineExceeded.
+var ErrHung = fmt.Errorf("check hung and was aborted by the watchdog: %w", context.DeadlineExceeded)
+
 // Browser handles the Chrome automation
 type Browser struct {
-	allocCtx    context.Context
-	cancelAlloc context.CancelFunc
-	target      config.Target
-	maxPages    int
+	targets []config.Target
+	opts    Options
+
+	mu            sync.Mutex
+	next          *Options // Options of the next check, see SetOptions
+	allocCtx      context.Context
+	cancelAlloc   context.CancelFunc
+	generation    int             // Of the allocator, a new one is started by coolDown and restart
+	browserCtx    context.Context // Chrome kept between checks, in warm mode
+	cancelBrowser context.CancelFunc
+	active        context.Context   // Browser context of the running check
+	expansions    map[string]string // Categories each template target covered last, see logExpansions
+	tableText     string            // Raw text of the table on the first page of the last check
+}
+
+// Browser modes, trading memory between checks for startup latency
+const (
+	// ModeCold starts Chrome for every check and shuts it down after,
+	// dropping its profile too, so nothing is left between checks
+	ModeCold = "cold"
+	// ModeWarm keeps Chrome running between checks, each check opening a
+	// new tab, which saves the startup time but keeps its memory
+	ModeWarm = "warm"
+)
+
+// Options configures the browser
+type Options struct {
+	URL       string        // Reservation page listing the slots
+	MaxPages  int           // Maximum number of pages to check, unless a target's depth says otherwise
+	PageDelay time.Duration // Waited before reading each page, to go easy on the site
+	Proxy     string        // Proxy server for all traffic, e.g. socks5://127.0.0.1:1080
+	Locale    string        // Browser locale and Accept-Language, e.g. ja-JP
+	Mode      string        // ModeWarm (default) or ModeCold
+	SlotTimes bool          // Open each available cell to read its time windows
+	Headful   bool          // Show the browser window, to record or replay flows
+	Width     int           // Window width, 1920 if zero
+	Height    int           // Window height, 1080 if zero
+	Device    string        // Mobile device to emulate, e.g. "iPhone 13", see LookupDevice
+
+	// ArtifactDir keeps the page and a screenshot of the last checks, for
+	// debugging, if set
+	ArtifactDir string
 }
 
-// New creates a new browser instance
-func New(target config.Target, maxPages int) *Browser {
+// New creates a new browser instance reporting slots for all targets
+func New(targets []config.Target, opts Options) *Browser {
+	allocCtx, cancelAlloc := newAllocator(opts)
+
+	return &Browser{
+		allocCtx:    allocCtx,
+		cancelAlloc: cancelAlloc,
+		targets:     targets,
+		opts:        opts,
+		expansions:  make(map[string]string),
+	}
+}
+
+// newAllocator starts a new Chrome allocator. Chrome uses a profile
+// directory tagged with our pid so orphaned processes can be reaped.
+func newAllocator(o Options) (context.Context, context.CancelFunc) {
+	userDataDir, err := newUserDataDir()
+	if err != nil {
+		log.Printf("❌ Failed to create Chrome profile directory: %v", err)
+	}
+
+	width, height := o.Width, o.Height
+	if width == 0 || height == 0 {
+		width, height = 1920, 1080
+	}
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.WindowSize(1920, 1080),
+		chromedp.WindowSize(width, height),
 		chromedp.NoSandbox,
 		chromedp.Flag("disable-web-security", true),
 		chromedp.Flag("disable-site-isolation-trials", true),
 		chromedp.Flag("disable-features", "SameSiteByDefaultCookies,CookiesWithoutSameSiteMustBeSecure"),
 		chromedp.Headless,
 	)
+	if o.Headful {
+		opts = append(opts, chromedp.Flag("headless", false))
+	}
+	if userDataDir != "" {
+		opts = append(opts, chromedp.UserDataDir(userDataDir))
+	}
+	if o.Proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(o.Proxy))
+	}
+	if o.Locale != "" {
+		opts = append(opts,
+			chromedp.Flag("lang", o.Locale),
+			chromedp.Env("LANG="+strings.ReplaceAll(o.Locale, "-", "_")+".UTF-8"),
+		)
+	}
 
-	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	return allocCtx, func() {
+		cancel()
+		if userDataDir != "" {
+			if err := os.RemoveAll(userDataDir); err != nil {
+				log.Printf("❌ Failed to remove Chrome profile directory: %v", err)
+			}
+		}
+	}
+}
 
-	return &Browser{
-		allocCtx:    allocCtx,
-		cancelAlloc: cancelAlloc,
-		target:      target,
-		maxPages:    maxPages,
+// BrowserState returns "checking" while a check runs, "idle" while a warm
+// Chrome waits for the next check, or "stopped" between cold checks
+func (b *Browser) BrowserState() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.active != nil:
+		return "checking"
+	case b.browserCtx != nil:
+		return "idle"
+	default:
+		return "stopped"
 	}
 }
 
+// TableText returns the raw text of the table on the first page of the
+// last check, empty if it couldn't be read
+func (b *Browser) TableText() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tableText
+}
+
+// readTableText keeps the raw text of the table on the page, or of the
+// page if there's no table, for TableText
+func (b *Browser) readTableText(ctx context.Context) {
+	var text string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(
+		`((document.querySelector('table.time--table') || document.body || {}).innerText || "").trim()`, &text)); err != nil {
+		log.Printf("⚠️ Failed to read the table text: %v", err)
+	}
+	b.mu.Lock()
+	b.tableText = text
+	b.mu.Unlock()
+}
+
+// PageDelay returns the delay waited before reading each page
+func (b *Browser) PageDelay() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.next != nil {
+		return b.next.PageDelay
+	}
+	return b.opts.PageDelay
+}
+
+// SetOptions changes how the table is read from the next check on: the
+// pages, the page delay, slot times and the device emulated. The running
+// Chrome and its allocator are kept, so the options it's started with
+// (proxy, locale, window size, mode) don't change until a restart.
+func (b *Browser) SetOptions(opts Options) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	next := b.opts
+	if b.next != nil {
+		next = *b.next
+	}
+	next.MaxPages, next.PageDelay, next.SlotTimes, next.Device = opts.MaxPages, opts.PageDelay, opts.SlotTimes, opts.Device
+	b.next = &next
+}
+
 // Close closes the browser allocator
 func (b *Browser) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancelBrowser != nil {
+		b.cancelBrowser()
+		b.browserCtx, b.cancelBrowser = nil, nil
+	}
+	b.cancelAlloc()
+}
+
+// parentContext returns the context the tab of a check is created from:
+// the allocator, which starts a new Chrome exiting with the tab, or in warm
+// mode the Chrome kept running, started if needed. The caller holds b.mu.
+func (b *Browser) parentContext() (context.Context, error) {
+	if b.opts.Mode == ModeCold {
+		return b.allocCtx, nil
+	}
+	if b.browserCtx == nil {
+		ctx, cancel := chromedp.NewContext(b.allocCtx)
+		// Running nothing starts Chrome, so tabs share it instead of each
+		// starting their own
+		if err := chromedp.Run(ctx); err != nil {
+			cancel()
+			return nil, err
+		}
+		b.browserCtx, b.cancelBrowser = ctx, cancel
+	}
+	return b.browserCtx, nil
+}
+
+// coolDown drops the Chrome profile after a check in cold mode, Chrome
+// itself has exited with the check's tab. The next check gets a new one.
+// A check abandoned by the watchdog cools down late, when the allocator of
+// the given generation was already replaced, and leaves the new one alone.
+func (b *Browser) coolDown(generation int) {
+	if b.opts.Mode != ModeCold {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.generation != generation {
+		return
+	}
 	b.cancelAlloc()
+	b.allocCtx, b.cancelAlloc = newAllocator(b.opts)
+	b.generation++
+}
+
+// CheckAvailability checks for available slots of all targets and reports
+// how the check went
+func (b *Browser) CheckAvailability() (scraper.CheckResult, error) {
+	b.mu.Lock()
+	targets := b.targets
+	b.mu.Unlock()
+	return b.CheckTargets(targets)
+}
+
+// CheckTargets checks for available slots of the given targets only. A
+// watchdog aborts the check if chromedp stops responding, killing Chrome
+// and starting a new allocator.
+func (b *Browser) CheckTargets(targets []config.Target) (scraper.CheckResult, error) {
+	type outcome struct {
+		result scraper.CheckResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := b.checkAvailability(targets)
+		done <- outcome{result: result, err: err}
+	}()
+
+	watchdog := time.NewTimer(2 * checkTimeout)
+	defer watchdog.Stop()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-watchdog.C:
+		log.Printf("🐕 Watchdog: check still running after %s, restarting Chrome", 2*checkTimeout)
+		b.restart()
+		return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepWatchdog, Err: ErrHung}
+	}
+}
+
+// CheckEach checks the targets like CheckTargets. When the check fails
+// past loading the page, where one target could be at fault, e.g. reading
+// a page or moving on to a target's later weeks, it checks each target on
+// its own, so a failing target doesn't fail the others. It returns the
+// result of the targets read and the error of each of the others by ID.
+func (b *Browser) CheckEach(targets []config.Target) (scraper.CheckResult, map[string]error) {
+	startTime := time.Now()
+	result, err := b.CheckTargets(targets)
+	if err == nil {
+		return result, nil
+	}
+	errs := make(map[string]error, len(targets))
+	step := scraper.ErrorStep(err)
+	if len(targets) < 2 || step == scraper.StepLaunch || step == scraper.StepSetup ||
+		step == scraper.StepNavigate || step == scraper.StepWatchdog {
+		// Chrome or the site is at fault, every target fails alike
+		for _, t := range targets {
+			errs[t.ID()] = err
+		}
+		return scraper.CheckResult{}, errs
+	}
+
+	log.Printf("⚠️ Check failed, checking each of the %d targets on its own: %v", len(targets), err)
+	result = scraper.NewCheckResult(startTime, nil)
+	result.StatusCounts = make(map[string]int)
+	for _, t := range targets {
+		r, err := b.CheckTargets([]config.Target{t})
+		if err != nil {
+			errs[t.ID()] = err
+			continue
+		}
+		for status, n := range r.StatusCounts {
+			result.StatusCounts[status] += n
+		}
+		result.Rows = mergeRows(result.Rows, r.Rows)
+		result.Slots = append(result.Slots, r.Slots...)
+		result.Warnings = append(result.Warnings, r.Warnings...)
+		result.PagesChecked += r.PagesChecked
+	}
+	result.Duration = time.Since(startTime)
+	return result, errs
+}
+
+// restart force-kills the current Chrome process and starts a new allocator
+func (b *Browser) restart() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.active != nil {
+		if c := chromedp.FromContext(b.active); c != nil && c.Browser != nil {
+			if p := c.Browser.Process(); p != nil {
+				if err := p.Kill(); err != nil {
+					log.Printf("❌ Failed to kill Chrome (pid %d): %v", p.Pid, err)
+				}
+			}
+		}
+		b.active = nil
+	}
+
+	// Cancelling waits for Chrome to exit, don't let a stuck process block us
+	go b.cancelAlloc()
+	b.browserCtx, b.cancelBrowser = nil, nil
+	b.allocCtx, b.cancelAlloc = newAllocator(b.opts)
+	b.generation++
 }
 
-// CheckAvailability checks for available slots
-func (b *Browser) CheckAvailability() ([]scraper.Slot, error) {
+// checkAvailability runs a single check of the targets
+func (b *Browser) checkAvailability(targets []config.Target) (result scraper.CheckResult, err error) {
 	startTime := time.Now()
+	var artifacts string // Saved as the tab closes, see saveArtifacts
 	defer func() {
+		// A panic fails the check rather than passing for one without slots
 		if r := recover(); r != nil {
 			log.Printf("❌ Panic: %v", r)
+			err = &scraper.StepError{
+				Step: scraper.StepPanic,
+				Err:  &scraper.PanicError{Value: r, Stack: debug.Stack()},
+				Page: result.PagesChecked,
+			}
+			result = scraper.CheckResult{}
+		}
+		var stepErr *scraper.StepError
+		if errors.As(err, &stepErr) {
+			stepErr.Artifacts = artifacts
 		}
 	}()
 
-	// Create a new context for this check
+	b.mu.Lock()
+	if b.next != nil {
+		// Checks run one at a time, none reads the options now
+		b.opts, b.next = *b.next, nil
+	}
+	parent, err := b.parentContext()
+	generation := b.generation
+	b.tableText = ""
+	b.mu.Unlock()
+	if err != nil {
+		return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepLaunch, Err: fmt.Errorf("❌ Failed to start Chrome: %w", err)}
+	}
+	defer b.coolDown(generation)
+
+	// Create a new tab for this check
 	ctx, cancel := chromedp.NewContext(
-		b.allocCtx,
+		parent,
 		chromedp.WithLogf(func(format string, args ...interface{}) {
 			msg := fmt.Sprintf(format, args...)
 			if (strings.Contains(msg, "error") || strings.Contains(msg, "failed")) &&
@@ -71,14 +395,41 @@ func (b *Browser) CheckAvailability() ([]scraper.Slot, error) {
 		}),
 	)
 	defer cancel()
+	// Running nothing opens the tab, starting Chrome in cold mode, so a
+	// Chrome that can't start is told apart from later failures
+	if err := chromedp.Run(ctx); err != nil {
+		return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepLaunch, Err: fmt.Errorf("❌ Failed to start Chrome: %w", err)}
+	}
+
+	b.mu.Lock()
+	b.active = ctx
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		if b.active == ctx {
+			b.active = nil
+		}
+		b.mu.Unlock()
+	}()
+
+	// Runs before the tab is closed, whether the check succeeded or not
+	defer func() { artifacts = b.saveArtifacts(ctx, startTime) }()
 
 	// Add timeout for this check
-	ctx, cancel = context.WithTimeout(ctx, 60*time.Second)
+	ctx, cancel = context.WithTimeout(ctx, checkTimeout)
 	defer cancel()
 
+	// Force the locale so the site renders the Japanese table layout the
+	// slot script expects, whatever the host locale is
+	if err := chromedp.Run(ctx, b.localeActions()); err != nil {
+		return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepSetup, Err: fmt.Errorf("❌ Failed to set locale: %w", err)}
+	}
+	if err := chromedp.Run(ctx, b.deviceActions()); err != nil {
+		return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepSetup, Err: fmt.Errorf("❌ Failed to emulate %s: %w", b.opts.Device, err)}
+	}
+
 	// Add retry logic for initial page load with exponential backoff
 	maxRetries := 3
-	var err error
 	for retry := 0; retry < maxRetries; retry++ {
 		if retry > 0 {
 			backoffDuration := time.Duration(retry*retry) * time.Second
@@ -86,69 +437,96 @@ func (b *Browser) CheckAvailability() ([]scraper.Slot, error) {
 			time.Sleep(backoffDuration)
 		}
 
-		var buf []byte
-
-	   if err := chromedp.Run(ctx,
-		    chromedp.Navigate(config.BaseURL),
+		if err := chromedp.Run(ctx,
+			chromedp.Navigate(b.opts.URL),
 			chromedp.Click(`input[type="checkbox"]`),
-		    chromedp.Sleep(5 * time.Second),
+			chromedp.Sleep(5*time.Second),
 			chromedp.WaitVisible(`table.time--table`, chromedp.ByQuery),
 		); err != nil {
-			return nil, fmt.Errorf("❌ Failed to click button: %v", err)
+			return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepNavigate, Err: fmt.Errorf("❌ Failed to click button: %w", err)}
 		}
-		
+
 		err = chromedp.Run(ctx,
-			chromedp.Navigate(config.BaseURL),
-		    chromedp.Sleep(5 * time.Second),
+			chromedp.Navigate(b.opts.URL),
+			chromedp.Sleep(5*time.Second),
 			chromedp.WaitVisible(`table.time--table`, chromedp.ByQuery),
-		    chromedp.CaptureScreenshot(&buf),
 		)
-		fmt.Println("DEBUG -- Screenshot base64:")
-		fmt.Println(base64.StdEncoding.EncodeToString(buf))
 
-		
 		if err == nil {
 			break
 		}
 	}
 	if err != nil {
-		
-		if errors.Is(err, context.DeadlineExceeded) {
-		        log.Println("Request timed out!")
-		    }
-
-		return nil, fmt.Errorf("❌ Failed to load page after %d retries: %v", maxRetries, err)
+		return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepNavigate, Err: fmt.Errorf("❌ Failed to load page after %d retries: %w", maxRetries, err)}
 	}
 
-	// Keep track of how many pages we've checked
-	pagesChecked := 0
+	result = scraper.NewCheckResult(startTime, nil)
+	result.StatusCounts = make(map[string]int)
 
-	for pagesChecked < b.maxPages {
+	// Depth windows and header years go by the corrected wall clock
+	now := clock.Now()
+	maxPages := maxPages(targets, now, b.opts.MaxPages)
+	scriptTargets, templates := expandable(targets)
+	var lastDate time.Time // Last header date, to check the next page's follow on
+	timed := 0             // Slots whose times were read, up to maxTimedSlots
+	for result.PagesChecked < maxPages {
 		// Wait for the table and SVG elements to load
 		if err := chromedp.Run(ctx,
 			chromedp.WaitVisible(`table.time--table`, chromedp.ByQuery),
 			chromedp.WaitVisible(`svg[aria-label="予約可能"], svg[aria-label="空き無"], svg[aria-label="時間外"]`, chromedp.ByQuery),
-			chromedp.Sleep(500*time.Millisecond),
+			chromedp.Sleep(b.opts.PageDelay),
 		); err != nil {
-			return nil, fmt.Errorf("❌ Failed to find elements: %v", err)
+			return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepTableWait, Err: fmt.Errorf("❌ Failed to find elements: %w", err), Page: result.PagesChecked + 1}
 		}
 
 		// Try to find available slots using JavaScript
-		var availableSlots []scraper.Slot
-		slotScript := b.createSlotScript()
-
-		if err := chromedp.Run(ctx, chromedp.Evaluate(slotScript, &availableSlots)); err != nil {
-			log.Printf("❌ Error checking slots: %v", err)
+		var page pageResult
+		slotScript := createSlotScript(scriptTargets)
+
+		result.PagesChecked++
+		// A page that can't be read fails the check rather than passing for
+		// one without slots
+		if err := chromedp.Run(ctx, chromedp.Evaluate(slotScript, &page)); err != nil {
+			return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepParse, Err: fmt.Errorf("❌ Failed to read slots: %w", err), Page: result.PagesChecked}
+		}
+		if result.PagesChecked == 1 {
+			b.readTableText(ctx)
+		}
+		// Pages beyond a target's depth only count for the deeper targets
+		within := targetsWithin(targets, result.PagesChecked, now, b.opts.MaxPages)
+		if templates || len(within) < len(targets) {
+			filterPage(&page, within)
+		}
+		for status, n := range page.Counts {
+			result.StatusCounts[status] += n
+		}
+		result.Rows = mergeRows(result.Rows, page.Rows)
+		var orderWarnings []scraper.Warning
+		lastDate, orderWarnings = scraper.CheckDateOrder(page.Dates, lastDate, now)
+		page.Warnings = append(page.Warnings, orderWarnings...)
+		for _, w := range page.Warnings {
+			w.Page = result.PagesChecked
+			result.Warnings = append(result.Warnings, w)
 		}
 
-		if len(availableSlots) > 0 {
-			duration := time.Since(startTime)
-			log.Printf("🎯 Found %d slots: %s (checked %d pages in %.1fs)",
-				len(availableSlots),
-				strings.Join(scraper.SlotDates(availableSlots), ", "),
-				pagesChecked+1,
-				duration.Seconds())
-			return availableSlots, nil // Return immediately when slots are found
+		// Every page is read, as a target's slots can be on a later page
+		// than another's
+		if len(page.Slots) > 0 {
+			left := false
+			if b.opts.SlotTimes {
+				left = b.readSlotTimes(ctx, &page, &result, max(0, maxTimedSlots-timed))
+				timed += len(page.Slots)
+			}
+			result.Slots = append(result.Slots, page.Slots...)
+			if left && result.PagesChecked < maxPages {
+				// Going back from a detail page may show the first weeks again
+				result.Warnings = append(result.Warnings, scraper.Warning{
+					Code:    scraper.WarnPagesSkipped,
+					Message: fmt.Sprintf("Stopped after page %d, as reading slot times left the table", result.PagesChecked),
+					Page:    result.PagesChecked,
+				})
+				break
+			}
 		}
 
 		// Try to click the "2週後" button if it's enabled
@@ -156,10 +534,10 @@ func (b *Browser) CheckAvailability() ([]scraper.Slot, error) {
 		if err := chromedp.Run(ctx,
 			chromedp.Evaluate(`!document.querySelector('input[value="2週後＞"]').disabled`, &nextButtonEnabled),
 		); err != nil {
-			return nil, fmt.Errorf("❌ Failed to check button: %v", err)
+			return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepPagination, Err: fmt.Errorf("❌ Failed to check button: %w", err), Page: result.PagesChecked}
 		}
 
-		if !nextButtonEnabled {
+		if !nextButtonEnabled || result.PagesChecked >= maxPages {
 			break
 		}
 
@@ -167,30 +545,326 @@ func (b *Browser) CheckAvailability() ([]scraper.Slot, error) {
 			chromedp.Click(`input[value="2週後＞"]`),
 			chromedp.WaitVisible(`table.time--table`, chromedp.ByQuery),
 		); err != nil {
-			return nil, fmt.Errorf("❌ Failed to click button: %v", err)
+			return scraper.CheckResult{}, &scraper.StepError{Step: scraper.StepPagination, Err: fmt.Errorf("❌ Failed to click button: %w", err)}
+		}
+	}
+
+	if templates {
+		b.logExpansions(targets, result.Rows)
+	}
+	result.Duration = time.Since(startTime)
+	return result, nil
+}
+
+// expandable returns the targets for the slot script, which only matches
+// categories exactly: template targets cover their whole location, and
+// whether the categories found match their pattern is left to filterPage.
+// It tells whether there are templates.
+func expandable(targets []config.Target) ([]config.Target, bool) {
+	script := make([]config.Target, len(targets))
+	templates := false
+	for i, t := range targets {
+		if t.IsTemplate() {
+			t.Category = ""
+			templates = true
+		}
+		script[i] = t
+	}
+	return script, templates
+}
+
+// filterPage keeps the slots and rows of a page matching the targets, with
+// the cells counted by status again from the rows kept
+func filterPage(page *pageResult, targets []config.Target) {
+	matches := func(location, category string) bool {
+		for _, t := range targets {
+			if t.Matches(location, category) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var slots []scraper.Slot
+	var cells []cellRef
+	for i, slot := range page.Slots {
+		if !matches(slot.Location, slot.Category) {
+			continue
+		}
+		slots = append(slots, slot)
+		if i < len(page.Cells) {
+			cells = append(cells, page.Cells[i])
+		}
+	}
+	page.Slots, page.Cells = slots, cells
+
+	var rows []scraper.RowCounts
+	counts := make(map[string]int)
+	for _, row := range page.Rows {
+		if !matches(row.Location, row.Category) {
+			continue
+		}
+		rows = append(rows, row)
+		for status, n := range row.Counts {
+			counts[status] += n
+		}
+	}
+	page.Rows, page.Counts = rows, counts
+}
+
+// logExpansions logs the categories of the table each template target
+// covers, whenever they change, e.g. when the site adds a category
+func (b *Browser) logExpansions(targets []config.Target, rows []scraper.RowCounts) {
+	for _, t := range targets {
+		if !t.IsTemplate() {
+			continue
+		}
+		var categories []string
+		for _, row := range rows {
+			if t.Matches(row.Location, row.Category) {
+				categories = append(categories, row.Category)
+			}
+		}
+		covered := strings.Join(categories, ", ")
+
+		key := t.Location + " " + t.Category
+		b.mu.Lock()
+		previous, seen := b.expansions[key]
+		b.expansions[key] = covered
+		b.mu.Unlock()
+		if seen && previous == covered {
+			continue
+		}
+		if covered == "" {
+			log.Printf("⚠️ %s %s matches no category of the table", t.Location, t.Category)
+		} else {
+			log.Printf("🎯 %s %s covers %s", t.Location, t.Category, covered)
+		}
+	}
+}
+
+// readSlotTimes opens the detail of each available cell of the page to
+// read the slot's time windows, going back to the table after each. It's
+// best effort: a slot whose times can't be read keeps just its date, with
+// a warning, and the check still reports it. It reads at most limit slots
+// and reports whether a detail page of its own was left to go back to the
+// table.
+func (b *Browser) readSlotTimes(ctx context.Context, page *pageResult, result *scraper.CheckResult, limit int) (left bool) {
+	for i := range page.Slots {
+		slot := &page.Slots[i]
+		if i >= len(page.Cells) || i >= limit {
+			result.Warnings = append(result.Warnings, scraper.Warning{
+				Code:    scraper.WarnTimesMissing,
+				Message: fmt.Sprintf("Skipped reading times of %d more slots", len(page.Slots)-i),
+				Page:    result.PagesChecked,
+			})
+			return left
+		}
+		cell := page.Cells[i]
+
+		var clicked, onTable bool
+		err := chromedp.Run(ctx,
+			chromedp.Evaluate(fmt.Sprintf(clickCellScript, cell.Row, cell.Column), &clicked),
+		)
+		if err == nil && clicked {
+			err = chromedp.Run(ctx,
+				chromedp.Sleep(2*time.Second),
+				chromedp.WaitReady(`body`, chromedp.ByQuery),
+				chromedp.Evaluate(slotTimesScript, &slot.Times),
+				chromedp.Evaluate(`!!document.querySelector('table.time--table')`, &onTable),
+			)
+		}
+		if err != nil || !clicked {
+			reason := "cell is gone"
+			if err != nil {
+				reason = err.Error()
+			}
+			result.Warnings = append(result.Warnings, scraper.Warning{
+				Code:    scraper.WarnTimesMissing,
+				Message: fmt.Sprintf("Could not open the slot on %s: %s", slot.Date, reason),
+				Page:    result.PagesChecked,
+				Column:  cell.Column,
+			})
+			return left
+		}
+		if len(slot.Times) == 0 {
+			result.Warnings = append(result.Warnings, scraper.Warning{
+				Code:    scraper.WarnTimesMissing,
+				Message: fmt.Sprintf("No time windows found for the slot on %s", slot.Date),
+				Page:    result.PagesChecked,
+				Column:  cell.Column,
+			})
+		}
+
+		// The detail is either a page of its own or shown over the table
+		if !onTable {
+			left = true
+			if err := chromedp.Run(ctx,
+				chromedp.NavigateBack(),
+				chromedp.WaitVisible(`table.time--table`, chromedp.ByQuery),
+				chromedp.Sleep(b.opts.PageDelay),
+			); err != nil {
+				result.Warnings = append(result.Warnings, scraper.Warning{
+					Code:    scraper.WarnTimesMissing,
+					Message: fmt.Sprintf("Could not go back to the table: %v", err),
+					Page:    result.PagesChecked,
+				})
+				return left
+			}
+		}
+	}
+	return left
+}
+
+// maxTimedSlots caps how many slots of a check get their times read, as
+// each one is a round trip to the site
+const maxTimedSlots = 5
+
+// clickCellScript clicks the table cell at the given row and column,
+// reporting false if it's no longer an available cell
+const clickCellScript = `(() => {
+	const table = document.querySelector('table.time--table');
+	const row = table && table.querySelectorAll('tr')[%d];
+	const cell = row && row.cells[%d];
+	if (!cell || !cell.classList.contains('enable')) {
+		return false;
+	}
+	(cell.querySelector('a, button, input') || cell).click();
+	return true;
+})()`
+
+// slotTimesScript reads the time windows, e.g. 09:00-10:00, from a slot's
+// detail. It prefers the rows marked available, falling back to every
+// time window shown if the detail has no marks.
+const slotTimesScript = `(() => {
+	const pattern = /(\d{1,2}:\d{2})\s*[-~～〜－]\s*(\d{1,2}:\d{2})/g;
+	const marked = Array.from(document.querySelectorAll('svg[aria-label="予約可能"]'))
+		.map(svg => svg.closest('tr, li'))
+		.filter(el => el && !el.closest('table.time--table'));
+	const scopes = marked.length > 0 ? marked : [document.body];
+	const times = [];
+	for (const el of scopes) {
+		for (const m of (el.innerText || '').matchAll(pattern)) {
+			const span = m[1] + "-" + m[2];
+			if (!times.includes(span)) {
+				times.push(span);
+			}
+		}
+	}
+	return times;
+})()`
+
+// mergeRows adds the cell counts of a page's rows to those of the pages
+// before, matching rows by location and category
+func mergeRows(rows, page []scraper.RowCounts) []scraper.RowCounts {
+	for _, p := range page {
+		i := 0
+		for i < len(rows) && (rows[i].Location != p.Location || rows[i].Category != p.Category) {
+			i++
+		}
+		if i == len(rows) {
+			rows = append(rows, scraper.RowCounts{Location: p.Location, Category: p.Category, Counts: make(map[string]int)})
+		}
+		for status, n := range p.Counts {
+			rows[i].Counts[status] += n
+		}
+	}
+	return rows
+}
+
+// SetTargets changes the targets reported from the next check on
+func (b *Browser) SetTargets(targets []config.Target) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.targets = targets
+}
+
+// maxPages returns how many pages to check at t: the deepest of the
+// targets' depths at that time, as all targets share the table's pages.
+// Deeper pages are then filtered with targetsWithin.
+func maxPages(targets []config.Target, t time.Time, fallback int) int {
+	pages := 0
+	for _, target := range targets {
+		pages = max(pages, target.MaxPagesAt(t, fallback))
+	}
+	if pages == 0 {
+		pages = fallback
+	}
+	return pages
+}
+
+// targetsWithin returns the targets whose depth at t reaches page
+func targetsWithin(targets []config.Target, page int, t time.Time, fallback int) []config.Target {
+	var within []config.Target
+	for _, target := range targets {
+		if target.MaxPagesAt(t, fallback) >= page {
+			within = append(within, target)
 		}
+	}
+	return within
+}
 
-		pagesChecked++
+// localeActions overrides the locale and Accept-Language of the tab
+func (b *Browser) localeActions() chromedp.Tasks {
+	if b.opts.Locale == "" {
+		return nil
+	}
+	lang, _, _ := strings.Cut(b.opts.Locale, "-")
+	acceptLanguage := b.opts.Locale
+	if lang != b.opts.Locale {
+		acceptLanguage += "," + lang + ";q=0.9"
+	}
+	return chromedp.Tasks{
+		network.Enable(),
+		network.SetExtraHTTPHeaders(network.Headers{"Accept-Language": acceptLanguage}),
+		emulation.SetLocaleOverride().WithLocale(b.opts.Locale),
 	}
+}
+
+// pageResult is what the slot script reports for one page of the table
+type pageResult struct {
+	Slots    []scraper.Slot       `json:"slots"`
+	Cells    []cellRef            `json:"cells"` // Table cell of each slot
+	Counts   map[string]int       `json:"counts"`
+	Rows     []scraper.RowCounts  `json:"rows"`
+	Dates    []scraper.HeaderDate `json:"dates"` // Header dates in column order
+	Warnings []scraper.Warning    `json:"warnings"`
+}
 
-	duration := time.Since(startTime)
-	log.Printf("✓ No slots found (checked %d pages in %.1fs)", pagesChecked+1, duration.Seconds())
-	return nil, nil
+// cellRef locates a cell of the availability table
+type cellRef struct {
+	Row    int `json:"row"`
+	Column int `json:"column"`
 }
 
-// createSlotScript creates the JavaScript to find available slots
-func (b *Browser) createSlotScript() string {
+// createSlotScript creates the JavaScript to find available slots. It returns
+// the slots along with per-status cell counts and warnings for the page.
+func createSlotScript(targets []config.Target) string {
+	// Go's JSON encoding of the targets is a valid JavaScript literal
+	targetsJSON, err := json.Marshal(targets)
+	if err != nil {
+		targetsJSON = []byte("[]")
+	}
 	return fmt.Sprintf(`
-		function findAvailableSlots() {
+		function findAvailableSlots(targets) {
 			const slots = [];
+			const slotCells = [];
+			const counts = {};
+			const targetRows = [];
+			const dates = [];
+			const warnings = [];
+			const result = { slots, cells: slotCells, counts, rows: targetRows, dates, warnings };
 			const table = document.querySelector('table.time--table');
-			if (!table) return slots;
+			if (!table) {
+				warnings.push({code: "table_missing", message: "Could not find availability table"});
+				return result;
+			}
 
 			// Get the date header row first and parse all dates
 			const headerRow = table.querySelector('tr#height_headday');
 			if (!headerRow) {
-				console.log("Could not find header row");
-				return slots;
+				warnings.push({code: "header_missing", message: "Could not find header row"});
+				return result;
 			}
 
 			// Create a map of column index to date
@@ -202,11 +876,15 @@ func (b *Browser) createSlotScript() string {
 					const dateMatch = fullText.match(/(\d{2}\/\d{2})/);
 					const dayMatch = fullText.match(/\((.*?)\)/);
 					
+					if (!dateMatch && /\d/.test(fullText)) {
+						warnings.push({code: "date_parse_failed", message: "Header date parse failed for column " + index + ": " + JSON.stringify(fullText), column: index});
+					}
 					if (dateMatch) {
 						const dateText = dateMatch[1];
 						const dayText = dayMatch ? dayMatch[1] : '';
 						console.log("Column " + index + ": Date = " + dateText + ", Day = " + dayText);
 						dateMap.set(index, dateText);
+						dates.push({column: index, date: dateText});
 					}
 				}
 			});
@@ -223,23 +901,36 @@ func (b *Browser) createSlotScript() string {
 				// Get location and category first
 				const locationCell = row.querySelector('th a');
 				const location = locationCell ? locationCell.textContent.trim() : '';
-				if (location !== %q) {
-					console.log("Skipping non-target location: " + location);
-					return;
-				}
-
 				const categoryCell = row.querySelector('th.main_color');
 				const category = categoryCell ? categoryCell.textContent.trim() : '';
-				if (category !== %q) {
-					console.log("Skipping non-target category: " + category);
+
+				// An empty target category matches every category of the location
+				const isTarget = targets.some(t =>
+					t.location === location && (!t.category || t.category === category));
+				if (!isTarget) {
+					console.log("Skipping non-target row: " + location + " - " + category);
 					return;
 				}
 
 				console.log("Processing row " + rowIndex + " for " + location + " - " + category);
+				const rowCounts = {};
+				targetRows.push({location, category, counts: rowCounts});
 
 				// Get all cells in this row
 				const cells = Array.from(row.cells);
 				cells.forEach((cell, cellIndex) => {
+					// Count every cell of the target row by its status mark
+					const statusSVG = cell.querySelector('svg[aria-label]');
+					if (statusSVG) {
+						const label = statusSVG.getAttribute('aria-label');
+						const status = {"予約可能": "available", "空き無": "full", "時間外": "closed"}[label] || "unknown";
+						counts[status] = (counts[status] || 0) + 1;
+						rowCounts[status] = (rowCounts[status] || 0) + 1;
+						if (status === "unknown") {
+							warnings.push({code: "status_unknown", message: "Unknown status mark " + JSON.stringify(label) + " in column " + cellIndex, column: cellIndex});
+						}
+					}
+
 					// Skip if this is not a selectable cell
 					if (!cell.classList.contains('tdSelect') || !cell.classList.contains('enable')) {
 						console.log("Column " + cellIndex + ": Not a selectable cell");
@@ -256,7 +947,7 @@ func (b *Browser) createSlotScript() string {
 					// Get the date from our map
 					const dateText = dateMap.get(cellIndex);
 					if (!dateText) {
-						console.log("Column " + cellIndex + ": No date found in map");
+						warnings.push({code: "date_missing", message: "No date found for available cell in column " + cellIndex, column: cellIndex});
 						return;
 					}
 
@@ -269,15 +960,17 @@ func (b *Browser) createSlotScript() string {
 					});
 
 					slots.push({
-						Location: location,
-						Category: category,
-						Date: dateText
+						location: location,
+						category: category,
+						date: dateText,
+						available: true
 					});
+					slotCells.push({row: rowIndex, column: cellIndex});
 				});
 			});
 
-			return slots;
+			return result;
 		}
-		findAvailableSlots();
-	`, b.target.Location, b.target.Category)
+		findAvailableSlots(%s);
+	`, targetsJSON)
 }
@@ -0,0 +1,34 @@
+package browser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/device"
+)
+
+// LookupDevice returns the emulation profile of a device by its name, e.g.
+// "iPhone 13" or "Pixel 5 landscape", ignoring case
+func LookupDevice(name string) (device.Info, error) {
+	for d := device.Reset + 1; d <= device.MotoG4landscape; d++ {
+		if strings.EqualFold(d.String(), name) {
+			return d.Device(), nil
+		}
+	}
+	return device.Info{}, fmt.Errorf("unknown device %q: expected a name like \"iPhone 13\" or \"Pixel 5\"", name)
+}
+
+// deviceActions emulate the configured mobile device, if any: its viewport,
+// user agent and touch screen
+func (b *Browser) deviceActions() chromedp.Tasks {
+	if b.opts.Device == "" {
+		return nil
+	}
+	info, err := LookupDevice(b.opts.Device)
+	if err != nil {
+		// Checked when the configuration is loaded
+		return nil
+	}
+	return chromedp.Tasks{chromedp.Emulate(info)}
+}
@@ -0,0 +1,81 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// userDataDirPrefix marks the Chrome profiles we create so that processes
+// left behind by a crashed run can be told apart from other Chrome instances
+const userDataDirPrefix = "policeScrapper-chrome-"
+
+// newUserDataDir creates a Chrome profile directory tagged with our pid
+func newUserDataDir() (string, error) {
+	return os.MkdirTemp("", fmt.Sprintf("%s%d-", userDataDirPrefix, os.Getpid()))
+}
+
+// ownerPid returns the pid of the scraper that created a profile directory
+func ownerPid(dir string) (int, bool) {
+	name := strings.TrimPrefix(filepath.Base(dir), userDataDirPrefix)
+	pidText, _, ok := strings.Cut(name, "-")
+	if !ok {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(pidText)
+	return pid, err == nil
+}
+
+// isOrphan reports whether a profile directory belongs to a scraper that is
+// no longer running
+func isOrphan(dir string) bool {
+	pid, ok := ownerPid(dir)
+	if !ok || pid == os.Getpid() {
+		return false
+	}
+	return !processAlive(pid)
+}
+
+// ReapOrphans kills Chrome processes left behind by previous runs and removes
+// their profile directories
+func ReapOrphans() {
+	killed, err := killOrphans()
+	if err != nil {
+		log.Printf("❌ Failed to look for orphaned Chrome processes: %v", err)
+	}
+	if killed > 0 {
+		log.Printf("🧹 Killed %d orphaned Chrome process(es)", killed)
+	}
+
+	dirs, err := filepath.Glob(filepath.Join(os.TempDir(), userDataDirPrefix+"*"))
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		if isOrphan(dir) {
+			if err := os.RemoveAll(dir); err != nil {
+				log.Printf("❌ Failed to remove orphaned Chrome profile %s: %v", dir, err)
+			}
+		}
+	}
+}
+
+// RunReaper reaps orphans now and then every interval until ctx is cancelled
+func RunReaper(ctx context.Context, interval time.Duration) {
+	ReapOrphans()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ReapOrphans()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package browser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// killOrphans kills Chrome processes whose profile belongs to a dead scraper
+func killOrphans() (int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, err
+	}
+
+	killed := 0
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join("/proc", e.Name(), "cmdline"))
+		if err != nil {
+			continue // Process exited or isn't ours to read
+		}
+		dir, ok := userDataDirArg(cmdline)
+		if !ok || !isOrphan(dir) {
+			continue
+		}
+		if err := syscall.Kill(pid, syscall.SIGKILL); err == nil {
+			killed++
+		}
+	}
+	return killed, nil
+}
+
+// userDataDirArg returns our --user-data-dir from a NUL-separated command line
+func userDataDirArg(cmdline []byte) (string, bool) {
+	for _, arg := range bytes.Split(cmdline, []byte{0}) {
+		dir, ok := strings.CutPrefix(string(arg), "--user-data-dir=")
+		if ok && strings.HasPrefix(filepath.Base(dir), userDataDirPrefix) {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// processAlive reports whether a process with the given pid exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package browser
+
+// killOrphans is only implemented on Linux, where the scraper is deployed
+func killOrphans() (int, error) {
+	return 0, nil
+}
+
+// processAlive assumes other processes are alive, so their profiles are kept
+func processAlive(pid int) bool {
+	return true
+}
@@ -0,0 +1,225 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/flow"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// recordBinding is the function the recorder script reports actions to
+const recordBinding = "__recordAction"
+
+// Record opens a visible Chrome at opts.URL and records the user clicking
+// through the site, e.g. the reservation form, until stop is closed or the
+// window is closed. Each action is logged as it's recorded.
+func Record(opts Options, stop <-chan struct{}) (flow.Script, error) {
+	opts.Headful = true
+	allocCtx, cancelAlloc := newAllocator(opts)
+	defer cancelAlloc()
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	var mu sync.Mutex
+	script := flow.Script{RecordedAt: time.Now()}
+	add := func(a flow.Action) {
+		mu.Lock()
+		defer mu.Unlock()
+		// The page an action led to, not the one navigated to or reloaded
+		if n := len(script.Actions); a.Type == flow.ActionLoad && n > 0 &&
+			(script.Actions[n-1].Type == flow.ActionNavigate || script.Actions[n-1].Type == flow.ActionLoad) &&
+			script.Actions[n-1].URL == a.URL {
+			return
+		}
+		script.Actions = append(script.Actions, a)
+		log.Printf("⏺ %s", a)
+	}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *runtime.EventBindingCalled:
+			if ev.Name != recordBinding {
+				return
+			}
+			var a flow.Action
+			if err := json.Unmarshal([]byte(ev.Payload), &a); err != nil {
+				log.Printf("⚠️ Failed to record action: %v", err)
+				return
+			}
+			add(a)
+		case *page.EventFrameNavigated:
+			if ev.Frame.ParentID == "" {
+				add(flow.Action{Type: flow.ActionLoad, URL: ev.Frame.URL})
+			}
+		}
+	})
+
+	add(flow.Action{Type: flow.ActionNavigate, URL: opts.URL})
+	b := &Browser{opts: opts}
+	if err := chromedp.Run(ctx,
+		b.localeActions(),
+		b.deviceActions(),
+		runtime.AddBinding(recordBinding),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			_, err := page.AddScriptToEvaluateOnNewDocument(recorderScript).Do(ctx)
+			return err
+		}),
+		chromedp.Navigate(opts.URL),
+	); err != nil {
+		return script, fmt.Errorf("failed to open %s: %w", opts.URL, err)
+	}
+
+	select {
+	case <-stop:
+	case <-ctx.Done():
+		// The window was closed
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return script, nil
+}
+
+// Replay opens a visible Chrome and runs the actions of a recorded script,
+// to see whether it still works with the site, until stop is closed or the
+// window is closed. Actions are logged as they run, and an action that
+// fails stops the replay, leaving the page as it was for inspection.
+func Replay(opts Options, script flow.Script, stop <-chan struct{}) error {
+	opts.Headful = true
+	allocCtx, cancelAlloc := newAllocator(opts)
+	defer cancelAlloc()
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+
+	b := &Browser{opts: opts}
+	if err := chromedp.Run(ctx, b.localeActions(), b.deviceActions()); err != nil {
+		return err
+	}
+	var replayErr error
+	for i, a := range script.Actions {
+		task, err := flowTask(a)
+		if err == nil {
+			log.Printf("▶ %d/%d %s", i+1, len(script.Actions), a)
+			err = chromedp.Run(ctx, task)
+		}
+		if err != nil {
+			replayErr = fmt.Errorf("action %d (%s): %w", i+1, a, err)
+			log.Printf("❌ %v", replayErr)
+			break
+		}
+	}
+
+	select {
+	case <-stop:
+	case <-ctx.Done():
+	}
+	return replayErr
+}
+
+// flowTask turns a recorded action into the chromedp action replaying it
+func flowTask(a flow.Action) (chromedp.Action, error) {
+	switch a.Type {
+	case flow.ActionNavigate:
+		return chromedp.Navigate(a.URL), nil
+	case flow.ActionClick:
+		return chromedp.Click(a.Selector, chromedp.ByQuery), nil
+	case flow.ActionInput, flow.ActionSelect:
+		if a.Secret && a.Value == "" {
+			return nil, fmt.Errorf("secret value isn't recorded, fill it in the script")
+		}
+		return chromedp.SetValue(a.Selector, a.Value, chromedp.ByQuery), nil
+	case flow.ActionLoad:
+		return chromedp.WaitReady(`body`, chromedp.ByQuery), nil
+	default:
+		return nil, fmt.Errorf("unknown action type %q", a.Type)
+	}
+}
+
+// recorderScript runs in every page and reports the user's clicks and the
+// fields they fill in, with a selector for each element: its id, else its
+// name (and value, for radio buttons and checkboxes) or the value of a
+// button, else its path from the closest element with an id.
+const recorderScript = `(() => {
+	if (window.__recorderInstalled) {
+		return;
+	}
+	window.__recorderInstalled = true;
+
+	const unique = sel => document.querySelectorAll(sel).length === 1;
+
+	function selectorOf(el) {
+		if (el.id) {
+			return "#" + CSS.escape(el.id);
+		}
+		const tag = el.tagName.toLowerCase();
+		const name = el.getAttribute("name");
+		if (name) {
+			let sel = tag + "[name=" + JSON.stringify(name) + "]";
+			if ((el.type === "radio" || el.type === "checkbox") && el.value) {
+				sel += "[value=" + JSON.stringify(el.value) + "]";
+			}
+			if (unique(sel)) {
+				return sel;
+			}
+		}
+		if (tag === "input" && (el.type === "submit" || el.type === "button") && el.value) {
+			const sel = "input[value=" + JSON.stringify(el.value) + "]";
+			if (unique(sel)) {
+				return sel;
+			}
+		}
+		const parts = [];
+		for (let e = el; e && e !== document.documentElement; e = e.parentElement) {
+			if (e.id) {
+				parts.unshift("#" + CSS.escape(e.id));
+				break;
+			}
+			let part = e.tagName.toLowerCase();
+			const same = e.parentElement ? Array.from(e.parentElement.children).filter(s => s.tagName === e.tagName) : [];
+			if (same.length > 1) {
+				part += ":nth-of-type(" + (same.indexOf(e) + 1) + ")";
+			}
+			parts.unshift(part);
+		}
+		return parts.join(" > ");
+	}
+
+	function send(action) {
+		if (typeof window.` + recordBinding + ` === "function") {
+			window.` + recordBinding + `(JSON.stringify(action));
+		}
+	}
+
+	// Fields typed in are recorded once changed, the rest when clicked
+	const typed = el => el.tagName === "SELECT" || el.tagName === "TEXTAREA" ||
+		(el.tagName === "INPUT" && !["submit", "button", "checkbox", "radio", "image", "reset"].includes(el.type));
+
+	document.addEventListener("click", e => {
+		const el = e.target.closest("a, button, input, select, textarea, label, td, [onclick], [role=button]") || e.target;
+		if (typed(el)) {
+			return;
+		}
+		const label = (el.innerText || el.value || el.getAttribute("aria-label") || "").trim().slice(0, 50);
+		send({type: "click", selector: selectorOf(el), label: label});
+	}, true);
+
+	document.addEventListener("change", e => {
+		const el = e.target;
+		if (!typed(el)) {
+			return;
+		}
+		if (el.tagName === "SELECT") {
+			const option = el.options[el.selectedIndex];
+			send({type: "select", selector: selectorOf(el), value: el.value, label: option ? option.text.trim() : ""});
+			return;
+		}
+		const secret = el.type === "password";
+		send({type: "input", selector: selectorOf(el), value: secret ? "" : el.value, secret: secret});
+	}, true);
+})();`
@@ -0,0 +1,385 @@
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"policeScrapper/internal/daemon"
+	"policeScrapper/pkg/booked"
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/scraper"
+	"policeScrapper/pkg/snooze"
+)
+
+const usage = `Usage: scraper ctl <command>
+
+Commands:
+  status    Show the daemon status
+  schedule  Show the upcoming checks and why they're due then
+  check     Run a check immediately and print the result
+  reset-backoff
+            Forget the backoff of failed checks and of targets left out,
+            and check immediately
+  sprint [interval] [duration]
+            Check more often for a while (default every 2m for 3h)
+  sprint off
+            End the sprint early
+  pause     Pause scheduled checks
+  resume    Resume scheduled checks
+  targets   List monitored targets
+  targets set <[!]location[=category][@interval]>[,...]
+            Monitor other targets (! marks a target critical, @2m
+            checks it every 2 minutes instead of the interval)
+  targets add <[!]location[=category][@interval]>
+            Monitor one more target
+  targets remove <location[=category]>
+            Stop monitoring a target
+  targets reset
+            Go back to the configured targets. Changed targets are
+            otherwise kept across restarts.
+  config    Print the effective configuration, secrets redacted
+  snooze <MM/DD> [duration]
+            Don't notify about a date for a while (default 24h)
+  unsnooze <MM/DD>
+            Notify about a date again
+  snoozes   List snoozed dates
+  ack       Acknowledge found slots, cancelling the escalation call
+  booked [note]
+            Stop notifying about slots, you've booked elsewhere
+  rearm     Notify about slots again after "booked"
+`
+
+// Client talks to a running daemon over its control socket
+type Client struct {
+	http *http.Client
+}
+
+// NewClient creates a client for the daemon listening on socketPath
+func NewClient(socketPath string) *Client {
+	return &Client{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+			// A check can take several minutes when the site is slow
+			Timeout: 5 * time.Minute,
+		},
+	}
+}
+
+// Run executes a ctl command and returns the process exit code
+func Run(socketPath string, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	c := NewClient(socketPath)
+	var err error
+	switch args[0] {
+	case "status":
+		var s daemon.Status
+		if err = c.do(http.MethodGet, "/api/status", &s); err == nil {
+			printStatus(s)
+		}
+	case "schedule":
+		var s daemon.Schedule
+		if err = c.do(http.MethodGet, "/api/schedule", &s); err == nil {
+			printSchedule(s)
+		}
+	case "check":
+		var r scraper.CheckResult
+		if err = c.do(http.MethodPost, "/api/check", &r); err == nil {
+			printResult(r)
+		}
+	case "reset-backoff":
+		var r scraper.CheckResult
+		if err = c.do(http.MethodPost, "/api/reset-backoff", &r); err == nil {
+			printResult(r)
+		}
+	case "pause", "resume":
+		var s daemon.Status
+		if err = c.do(http.MethodPost, "/api/"+args[0], &s); err == nil {
+			printStatus(s)
+		}
+	case "sprint":
+		var s daemon.Status
+		if len(args) > 1 && args[1] == "off" {
+			err = c.do(http.MethodDelete, "/api/sprint", &s)
+		} else {
+			req := map[string]string{}
+			if len(args) > 1 {
+				req["interval"] = args[1]
+			}
+			if len(args) > 2 {
+				req["duration"] = args[2]
+			}
+			err = c.doJSON(http.MethodPost, "/api/sprint", req, &s)
+		}
+		if err == nil {
+			printStatus(s)
+		}
+	case "targets":
+		var targets []config.Target
+		var change []config.Target
+		if len(args) > 2 {
+			if change, err = config.ParseTargets(strings.Join(args[2:], " ")); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 2
+			}
+		}
+		switch {
+		case len(args) == 1:
+			err = c.do(http.MethodGet, "/api/targets", &targets)
+		case args[1] == "set" && len(change) > 0:
+			err = c.doJSON(http.MethodPut, "/api/targets", change, &targets)
+		case args[1] == "add" && len(change) == 1:
+			err = c.doJSON(http.MethodPost, "/api/targets", change[0], &targets)
+		case args[1] == "remove" && len(change) == 1:
+			query := url.Values{"location": {change[0].Location}, "category": {change[0].Category}}
+			err = c.do(http.MethodDelete, "/api/targets?"+query.Encode(), &targets)
+		case args[1] == "reset" && len(args) == 2:
+			err = c.do(http.MethodPost, "/api/targets/reset", &targets)
+		default:
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		if err == nil {
+			for _, t := range targets {
+				line := t.Location + "\t" + t.Category
+				if t.Critical {
+					line += "\tcritical"
+				}
+				if t.Interval > 0 {
+					line += "\tevery " + t.Interval.String()
+				}
+				fmt.Println(line)
+			}
+		}
+	case "config":
+		var cfg json.RawMessage
+		if err = c.do(http.MethodGet, "/api/config", &cfg); err == nil {
+			var out bytes.Buffer
+			if err = json.Indent(&out, cfg, "", "  "); err == nil {
+				fmt.Println(out.String())
+			}
+		}
+	case "snooze":
+		if len(args) < 2 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		req := map[string]string{"date": args[1]}
+		if len(args) > 2 {
+			req["duration"] = args[2]
+		}
+		var e snooze.Entry
+		if err = c.doJSON(http.MethodPost, "/api/snoozes", req, &e); err == nil {
+			fmt.Printf("Snoozed %s until %s\n", e.Date, formatTime(e.Until))
+		}
+	case "unsnooze":
+		if len(args) < 2 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		var entries []snooze.Entry
+		err = c.do(http.MethodDelete, "/api/snoozes?date="+url.QueryEscape(args[1]), &entries)
+	case "snoozes":
+		var entries []snooze.Entry
+		if err = c.do(http.MethodGet, "/api/snoozes", &entries); err == nil {
+			for _, e := range entries {
+				fmt.Printf("%s\tuntil %s\n", e.Date, formatTime(e.Until))
+			}
+		}
+	case "booked":
+		req := map[string]string{"note": strings.Join(args[1:], " ")}
+		var s booked.State
+		if err = c.doJSON(http.MethodPost, "/api/booked", req, &s); err == nil {
+			fmt.Println("Marked as booked, slots won't be notified until \"scraper ctl rearm\"")
+		}
+	case "rearm":
+		var s booked.State
+		if err = c.do(http.MethodDelete, "/api/booked", &s); err == nil {
+			fmt.Println("Re-armed, slots will be notified again")
+		}
+	case "ack":
+		var ack struct {
+			Acknowledged bool `json:"acknowledged"`
+		}
+		if err = c.do(http.MethodPost, "/api/ack", &ack); err == nil {
+			if ack.Acknowledged {
+				fmt.Println("Acknowledged, the call is cancelled")
+			} else {
+				fmt.Println("No call was pending")
+			}
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// do sends a request to the daemon and decodes the JSON response into out
+func (c *Client) do(method, path string, out interface{}) error {
+	return c.doJSON(method, path, nil, out)
+}
+
+// doJSON sends in as JSON body and decodes the JSON response into out
+func (c *Client) doJSON(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, "http://daemon"+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach daemon (is it running?): %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(respBody, &e) == nil && e.Error != "" {
+			return fmt.Errorf("%s", e.Error)
+		}
+		return fmt.Errorf("request failed with status: %d", resp.StatusCode)
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+func printStatus(s daemon.Status) {
+	state := "running"
+	if s.Paused {
+		state = "paused"
+	} else if s.Standby {
+		state = "standby"
+	}
+	if s.Checking {
+		state += " (checking)"
+	}
+	if s.Booked {
+		state += " (booked, not notifying)"
+	}
+	if s.AwaitingAck {
+		state += " (awaiting ack)"
+	}
+	fmt.Printf("State:       %s\n", state)
+	if s.SprintUntil != nil {
+		fmt.Printf("Interval:    %s (sprint until %s)\n", s.Interval, s.SprintUntil.Local().Format("15:04"))
+	} else {
+		fmt.Printf("Interval:    %s\n", s.Interval)
+	}
+	if s.PageDelay != "" {
+		fmt.Printf("Page delay:  %s\n", s.PageDelay)
+	}
+	fmt.Printf("Last check:  %s\n", formatTime(s.LastCheck))
+	fmt.Printf("Next check:  %s\n", formatTime(s.NextCheck))
+	if s.LastError != "" {
+		fmt.Printf("Last error:  %s (consecutive errors: %d)\n", s.LastError, s.ConsecutiveErrors)
+	}
+	if s.Backoff != "" {
+		fmt.Printf("Backoff:     %s before retrying (reset with \"ctl reset-backoff\")\n", s.Backoff)
+	}
+	if len(s.ErrorCounts) > 0 {
+		fmt.Printf("Failures:    %d timeouts, %d errors\n", s.ErrorCounts[scraper.ClassTimeout], s.ErrorCounts[scraper.ClassError])
+	}
+	if s.LastResult != nil {
+		fmt.Printf("Last slots:  %s\n", formatSlots(s.LastResult.Slots))
+	}
+	for i, h := range s.Health {
+		label := ""
+		if i == 0 {
+			label = "Targets:"
+		}
+		line := fmt.Sprintf("%-12s %-8s %3d%%  %s", label, h.State, h.Score, h.Location)
+		if h.Category != "" {
+			line += " (" + h.Category + ")"
+		}
+		if h.Reason != "" {
+			line += " - " + h.Reason
+		}
+		fmt.Println(line)
+	}
+	for name, extra := range s.Extras {
+		data, err := json.Marshal(extra)
+		if err != nil {
+			continue
+		}
+		fmt.Printf("%-12s %s\n", name+":", data)
+	}
+}
+
+func printResult(r scraper.CheckResult) {
+	fmt.Printf("Checked at:  %s\n", formatTime(r.CheckedAt))
+	fmt.Printf("Result:      %s\n", r.Summary())
+	for _, w := range r.Warnings {
+		fmt.Printf("Warning:     %s\n", w)
+	}
+}
+
+func printSchedule(s daemon.Schedule) {
+	if s.Next.Reason == daemon.ReasonPaused && len(s.Upcoming) == 0 {
+		fmt.Println("Paused, no check until resumed")
+	} else {
+		fmt.Printf("%s  %s\n", formatTime(s.Next.At), s.Next.Reason)
+		for _, c := range s.Upcoming {
+			fmt.Printf("%s  %s\n", formatTime(c.At), c.Reason)
+		}
+	}
+	fmt.Println()
+	for _, t := range s.Targets {
+		name := t.Location
+		if t.Category != "" {
+			name += " (" + t.Category + ")"
+		}
+		fmt.Printf("%s  %s: %s\n", formatTime(t.Next), name, t.Reason)
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func formatSlots(slots []scraper.Slot) string {
+	if len(slots) == 0 {
+		return "none"
+	}
+	return strings.Join(scraper.SlotDates(slots), ", ")
+}
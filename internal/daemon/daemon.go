@@ -0,0 +1,1729 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/adaptive"
+	"policeScrapper/pkg/booked"
+	"policeScrapper/pkg/changes"
+	"policeScrapper/pkg/clock"
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/cooldown"
+	"policeScrapper/pkg/counters"
+	"policeScrapper/pkg/health"
+	"policeScrapper/pkg/history"
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/pause"
+	"policeScrapper/pkg/scraper"
+	"policeScrapper/pkg/sentry"
+	"policeScrapper/pkg/snooze"
+	"policeScrapper/pkg/store"
+	"policeScrapper/pkg/tablewatch"
+	"policeScrapper/pkg/targetlist"
+)
+
+// Checker performs a single availability check
+type Checker interface {
+	CheckAvailability() (scraper.CheckResult, error)
+}
+
+// Throttled is implemented by checkers that wait between page loads
+type Throttled interface {
+	PageDelay() time.Duration
+}
+
+// Browsing is implemented by checkers driving a browser, to report what
+// it's doing
+type Browsing interface {
+	BrowserState() string
+}
+
+// Selective is implemented by checkers that can check some of the targets
+// only, so scheduled checks leave out the targets whose circuit is open
+type Selective interface {
+	CheckTargets(targets []config.Target) (scraper.CheckResult, error)
+}
+
+// Isolating is implemented by checkers that tell which targets a failed
+// check failed for, so a failing target doesn't fail the others with it
+type Isolating interface {
+	// CheckEach checks the targets, returning the result of those read and
+	// the error of each of the others by Target.ID()
+	CheckEach(targets []config.Target) (scraper.CheckResult, map[string]error)
+}
+
+// TableReader is implemented by checkers keeping the raw text of the table
+// they last read, to show how it changed when the parser stops finding rows
+type TableReader interface {
+	TableText() string
+}
+
+// Retargetable is implemented by checkers whose targets can change while
+// running
+type Retargetable interface {
+	SetTargets(targets []config.Target)
+}
+
+// Notifier delivers found slots to users
+type Notifier interface {
+	NotifyAvailableSlots(slots []scraper.Slot) error
+}
+
+// staleChecks is how many intervals may pass without reading a target
+// before it's stale
+const staleChecks = 3
+
+// wedgedAfter is how long a check may run, or a scheduled check be
+// overdue, before the daemon is considered wedged. The browser's watchdog
+// aborts hung checks well before.
+const wedgedAfter = 5 * time.Minute
+
+// maxNotifications is how many recent notifications Status lists
+const maxNotifications = 20
+
+// standbyPoll is how often a standby instance looks whether it must take over
+const standbyPoll = time.Minute
+
+// Sprint limits, so a typo can't hammer the site or never revert
+const (
+	MinSprintInterval = config.MinInterval
+	MaxSprintDuration = 12 * time.Hour
+)
+
+// ErrStopped is returned when a check is requested after the daemon stopped
+var ErrStopped = errors.New("daemon is not running")
+
+// ErrMaintenance is returned by CheckOnce during the site's maintenance
+var ErrMaintenance = errors.New("site maintenance")
+
+// Status is a snapshot of the daemon state
+type Status struct {
+	Paused            bool                   `json:"paused"`
+	PausedUntil       *time.Time             `json:"paused_until,omitempty"` // End of a timed pause
+	Standby           bool                   `json:"standby,omitempty"`      // Another instance is checking
+	AwaitingAck       bool                   `json:"awaiting_ack,omitempty"` // Found slots will be escalated unless acknowledged
+	Booked            bool                   `json:"booked,omitempty"`       // Booked elsewhere, slots aren't notified
+	Checking          bool                   `json:"checking"`
+	Interval          string                 `json:"interval"`               // Interval in effect, the sprint's during a sprint
+	SprintUntil       *time.Time             `json:"sprint_until,omitempty"` // End of the sprint, if one is running
+	PageDelay         string                 `json:"page_delay,omitempty"`
+	LastCheck         time.Time              `json:"last_check"`
+	NextCheck         time.Time              `json:"next_check"`
+	LastResult        *scraper.CheckResult   `json:"last_result,omitempty"`
+	LastError         string                 `json:"last_error,omitempty"`       // Of the target failing longest, as the next three
+	LastErrorClass    string                 `json:"last_error_class,omitempty"` // "timeout" or "error"
+	LastErrorStep     string                 `json:"last_error_step,omitempty"`
+	ConsecutiveErrors int                    `json:"consecutive_errors"`
+	Backoff           string                 `json:"backoff,omitempty"` // Wait before retrying while checks fail
+	ErrorCounts       map[string]int         `json:"error_counts"`      // Failed checks by class since start
+	Totals            *counters.Totals       `json:"totals,omitempty"`  // Checks counted across restarts
+	Targets           []config.Target        `json:"targets"`
+	Health            []health.Target        `json:"health"`           // Health of each target, from the recent checks
+	Notifications     []Notification         `json:"notifications"`    // Recent slot notifications since start, newest first
+	Extras            map[string]interface{} `json:"extras,omitempty"` // Sections from StatusExtras
+}
+
+// Notification is a notification of found slots
+type Notification struct {
+	At    time.Time      `json:"at"`
+	Slots []scraper.Slot `json:"slots"`
+}
+
+// Daemon runs periodic availability checks and allows controlling them
+type Daemon struct {
+	checker    Checker
+	notifier   Notifier
+	targets    []config.Target
+	configured []config.Target // Targets given to New or SetConfigured, see ResetTargets
+	interval   time.Duration
+
+	// retarget serializes target changes, which read the targets to change
+	retarget sync.Mutex
+
+	// AfterCheck is called after every successful scheduled check, if set
+	AfterCheck func()
+
+	// Alerter receives an "unhealthy" alert once AlertThreshold checks in a
+	// row have failed, and a recovery message when checks succeed again
+	Alerter        notify.Alerter
+	AlertThreshold int
+
+	// StatusExtras add named sections to Status, e.g. notification quotas
+	StatusExtras map[string]func() interface{}
+
+	// Standby reports whether another instance is checking, in which case
+	// scheduled checks are skipped to keep the load on the site constant
+	Standby func() bool
+
+	// AlertWarnings sends check warnings through the Alerter whenever the
+	// kinds of warnings change, to debug parsing problems
+	AlertWarnings bool
+
+	// History records every successful check, if set
+	History *history.Log
+
+	// Store records every check, failed ones too, if set, in a database
+	Store store.Store
+
+	// Counters keep the check totals across restarts, if set
+	Counters *counters.Store
+
+	// Snoozes lists dates not to notify about, if set
+	Snoozes *snooze.List
+
+	// Changes follows the slots open from check to check, if set, for
+	// OnlyNew and NotifyGone
+	Changes *changes.Feed
+
+	// OnlyNew limits notifications to the slots that appeared since the
+	// previous check, rather than every slot open. It needs Changes.
+	OnlyNew bool
+
+	// NotifyGone sends a follow-up when notified slots are gone, so users
+	// stop trying to book them. It needs Changes.
+	NotifyGone bool
+
+	// Cooldown skips slots already notified within its cooldown, if set,
+	// so a slot that stays open isn't notified every check
+	Cooldown *cooldown.List
+
+	// Booked stops all slot notifications once the user has booked, if set.
+	// Checks and history go on.
+	Booked *booked.Flag
+
+	// TargetList persists the targets changed at runtime, if set, so they
+	// survive restarts, see RestoreTargets
+	TargetList *targetlist.List
+
+	// PauseState persists pauses, if set, so they survive restarts, see
+	// RestorePause
+	PauseState *pause.Flag
+
+	// Escalation calls about found slots that aren't acknowledged, if set
+	Escalation *notify.Escalation
+
+	// TableWatch alerts when checks keep finding no rows in the table, with
+	// how its raw text changed since the last check that did, if set
+	TableWatch *tablewatch.Watch
+
+	// Tracker reports panicking checks, and checks failing AlertThreshold
+	// times in a row, to Sentry, if set
+	Tracker *sentry.Client
+
+	// CheckDone is called after every check, failed or not, if set
+	CheckDone func(result scraper.CheckResult, err error)
+
+	// Polling sets the interval by time of day from when slots appeared,
+	// in place of the configured one, if set
+	Polling *adaptive.Polling
+
+	// Maintenance are the daily windows the site is down, during which
+	// scheduled checks wait for their end. SetMaintenance changes them
+	// while running.
+	Maintenance config.Windows
+
+	// Quiet are hours during which only critical slots are notified, on
+	// every channel. Slots found are still logged and recorded. SetQuiet
+	// changes them while running.
+	Quiet notify.QuietHours
+
+	lastWarningCodes string // Codes of the warnings last alerted, by alertWarnings
+	chromeDown       bool   // The last check couldn't start Chrome, see alertLaunch
+	notifyDown       bool   // The last slot notification failed, see alertNotify
+
+	health *health.Tracker
+
+	mu             sync.Mutex
+	paused         bool
+	pausedUntil    time.Time // Zero unless paused for a while
+	checking       bool
+	checkStarted   time.Time
+	lastCheck      time.Time
+	nextCheck      time.Time
+	nextReason     string // Why the next check is due then, see Schedule
+	lastResult     *scraper.CheckResult
+	open           []scraper.Slot      // Slots open at the last check of their target, see carriedSlots
+	lastFailed     bool                // The last check failed for every target it checked
+	failures       map[string]*failure // Failed checks of each failing target by ID, see dueAt
+	errorCounts    map[string]int
+	sprintInterval time.Duration
+	sprintUntil    time.Time            // Zero unless sprinting
+	lastChecked    map[string]time.Time // End of the last check of each target by ID, see dueAt
+	notifications  []Notification       // Newest first, at most maxNotifications
+
+	trigger chan chan checkReply
+	wake    chan struct{} // Reschedules the next check after the interval changed
+	done    chan struct{}
+}
+
+// failure is a target's run of failed checks, backing off its retries
+type failure struct {
+	count       int   // Failed checks in a row
+	backoffFrom int   // count when the backoff was last reset
+	err         error // Error of the last one
+}
+
+type checkReply struct {
+	result scraper.CheckResult
+	err    error
+}
+
+// New creates a new daemon
+func New(checker Checker, notifier Notifier, targets []config.Target, interval time.Duration) *Daemon {
+	return &Daemon{
+		checker:     checker,
+		notifier:    notifier,
+		targets:     targets,
+		configured:  targets,
+		interval:    interval,
+		failures:    make(map[string]*failure),
+		errorCounts: make(map[string]int),
+		lastChecked: make(map[string]time.Time),
+		health:      health.NewTracker(),
+		trigger:     make(chan chan checkReply),
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+}
+
+// Run checks for slots until ctx is cancelled
+func (d *Daemon) Run(ctx context.Context) {
+	defer close(d.done)
+	wait, reason := time.Duration(0), ReasonStartup
+	for {
+		d.mu.Lock()
+		d.nextCheck = time.Now().Add(wait)
+		d.nextReason = reason
+		d.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case reply := <-d.trigger:
+			timer.Stop()
+			result, err := d.check(true)
+			reply <- checkReply{result: result, err: err}
+		case <-d.wake:
+			// Keep the time of the last check, with the new interval
+			timer.Stop()
+			d.mu.Lock()
+			wait, retry := d.untilDue(time.Now())
+			reason = d.intervalReason(time.Now())
+			if retry {
+				reason = ReasonBackoff
+			}
+			d.mu.Unlock()
+			wait, reason = d.skipMaintenance(wait, reason)
+			continue
+		case <-timer.C:
+			if d.Standby != nil && d.Standby() {
+				// Another instance is checking, look again soon in case it stops
+				if d.AfterCheck != nil {
+					d.AfterCheck()
+				}
+				wait, reason = min(d.activeInterval(), standbyPoll), ReasonStandby
+				continue
+			}
+			paused := d.isPaused()
+			now := time.Now()
+			if end := d.maintenanceEnd(now); !paused && end.After(now) {
+				log.Printf("🔧 Site maintenance, not checking until %s", end.Format("15:04"))
+				wait, reason = end.Sub(now), ReasonMaintenance
+				continue
+			}
+			if !paused {
+				d.check(false)
+			}
+			if d.AfterCheck != nil && !d.lastErrored() {
+				d.AfterCheck()
+			}
+			if paused {
+				d.endSprintIfOver()
+				wait, reason = d.pausedWait(), ReasonPaused
+				continue
+			}
+		}
+
+		d.endSprintIfOver()
+		wait, reason = d.nextWait()
+		if reason == ReasonBackoff {
+			log.Printf("Waiting %d seconds before retry (consecutive errors: %d)", int(wait.Seconds()), d.consecutiveErrorCount())
+		} else {
+			log.Printf("✓ Check complete. Next check in %s at %s", wait, time.Now().Add(wait).Format("15:04:05"))
+		}
+	}
+}
+
+// CheckNow runs a check immediately, interrupting the wait for the next one
+func (d *Daemon) CheckNow(ctx context.Context) (scraper.CheckResult, error) {
+	reply := make(chan checkReply, 1)
+	select {
+	case d.trigger <- reply:
+	case <-d.done:
+		return scraper.CheckResult{}, ErrStopped
+	case <-ctx.Done():
+		return scraper.CheckResult{}, ctx.Err()
+	}
+	select {
+	case r := <-reply:
+		return r.result, r.err
+	case <-ctx.Done():
+		return scraper.CheckResult{}, ctx.Err()
+	}
+}
+
+// CheckOnce runs a single check in place of Run, notifying and recording
+// it like a scheduled one, for one-shot runs driven by cron. Like scheduled
+// checks, it doesn't check during the site's maintenance.
+func (d *Daemon) CheckOnce() (scraper.CheckResult, error) {
+	if now := time.Now(); d.maintenanceEnd(now).After(now) {
+		return scraper.CheckResult{}, ErrMaintenance
+	}
+	return d.check(true)
+}
+
+// ResetBackoff forgets the failed checks' backoff, closes the targets'
+// circuits and checks immediately, e.g. once a network problem is fixed. A
+// failure of this check backs off from the start again; failure alerts
+// still count every failed check.
+func (d *Daemon) ResetBackoff(ctx context.Context) (scraper.CheckResult, error) {
+	d.mu.Lock()
+	for _, f := range d.failures {
+		f.backoffFrom = f.count
+	}
+	d.mu.Unlock()
+	d.health.Reset()
+	log.Printf("↺ Backoff reset, checking now")
+	return d.CheckNow(ctx)
+}
+
+// SetInterval changes the configured interval, e.g. after the config is
+// reloaded. The next check is due the new interval after the last one.
+func (d *Daemon) SetInterval(interval time.Duration) {
+	d.mu.Lock()
+	d.interval = interval
+	d.mu.Unlock()
+	d.reschedule()
+}
+
+// Sprint checks every interval instead of the configured interval for the
+// given duration, e.g. on days cancellations are expected, then reverts on
+// its own. Starting and ending sprints are alerted, as a reminder.
+func (d *Daemon) Sprint(interval, duration time.Duration) (time.Time, error) {
+	if interval < MinSprintInterval {
+		return time.Time{}, fmt.Errorf("sprint interval must be at least %s", MinSprintInterval)
+	}
+	if duration <= 0 || duration > MaxSprintDuration {
+		return time.Time{}, fmt.Errorf("sprint duration must be between 0 and %s", MaxSprintDuration)
+	}
+
+	until := time.Now().Add(duration)
+	d.mu.Lock()
+	d.sprintInterval = interval
+	d.sprintUntil = until
+	d.mu.Unlock()
+	d.reschedule()
+	d.alert(fmt.Sprintf("🏃 Sprint: checking every %s until %s", interval, until.Format("15:04")))
+	return until, nil
+}
+
+// EndSprint reverts to the configured interval before the sprint is over.
+// It returns false if no sprint was running.
+func (d *Daemon) EndSprint() bool {
+	d.mu.Lock()
+	sprinting := !d.sprintUntil.IsZero()
+	d.sprintUntil = time.Time{}
+	d.mu.Unlock()
+	if !sprinting {
+		return false
+	}
+	d.reschedule()
+	d.alert(fmt.Sprintf("🏁 Sprint ended, checking every %s again", d.interval))
+	return true
+}
+
+// endSprintIfOver reverts to the configured interval once the sprint's time
+// is up
+func (d *Daemon) endSprintIfOver() {
+	d.mu.Lock()
+	over := !d.sprintUntil.IsZero() && !time.Now().Before(d.sprintUntil)
+	if over {
+		d.sprintUntil = time.Time{}
+	}
+	d.mu.Unlock()
+	if over {
+		d.alert(fmt.Sprintf("🏁 Sprint over, checking every %s again", d.interval))
+	}
+}
+
+// currentInterval returns the interval in effect. The caller holds d.mu.
+func (d *Daemon) currentInterval() time.Duration {
+	if !d.sprintUntil.IsZero() {
+		return d.sprintInterval
+	}
+	return d.baseInterval(time.Now())
+}
+
+// baseInterval returns the interval at t outside sprints, the one of the
+// time of day with Polling
+func (d *Daemon) baseInterval(t time.Time) time.Duration {
+	if d.Polling != nil {
+		return d.Polling.Interval(t)
+	}
+	return d.interval
+}
+
+// sprinting reports whether a sprint runs at t. The caller holds d.mu.
+func (d *Daemon) sprinting(t time.Time) bool {
+	return !d.sprintUntil.IsZero() && t.Before(d.sprintUntil)
+}
+
+// targetInterval returns how often a target is checked at t: its own
+// interval, or the one in effect. A sprint speeds up targets with their own
+// interval too, but never slows them down. The caller holds d.mu.
+func (d *Daemon) targetInterval(target config.Target, t time.Time) time.Duration {
+	sprinting := d.sprinting(t)
+	switch {
+	case target.Interval > 0 && sprinting:
+		return min(target.Interval, d.sprintInterval)
+	case target.Interval > 0:
+		return target.Interval
+	case sprinting:
+		return d.sprintInterval
+	}
+	return d.baseInterval(t)
+}
+
+// intervalOf returns how often a target is checked now. The caller holds
+// d.mu.
+func (d *Daemon) intervalOf(target config.Target) time.Duration {
+	return d.targetInterval(target, time.Now())
+}
+
+// tickInterval returns the shortest interval of the targets at t, how often
+// scheduled checks run. The caller holds d.mu.
+func (d *Daemon) tickInterval(t time.Time) time.Duration {
+	tick := d.targetInterval(config.Target{}, t)
+	for i, target := range d.targets {
+		if interval := d.targetInterval(target, t); i == 0 || interval < tick {
+			tick = interval
+		}
+	}
+	return tick
+}
+
+// dueAt returns when a target is due after the end of its last check, or
+// of the last check for targets not checked yet. A target whose checks
+// failed is retried after its backoff instead. The caller holds d.mu.
+func (d *Daemon) dueAt(target config.Target) time.Time {
+	last, ok := d.lastChecked[target.ID()]
+	if !ok {
+		last = d.lastCheck
+	}
+	if f := d.backingOff(target); f != nil {
+		return last.Add(backoff(f.count-f.backoffFrom, f.err))
+	}
+	return d.dueAfter(target, last)
+}
+
+// backingOff returns the failed checks of a target backing off, nil if it
+// isn't. The caller holds d.mu.
+func (d *Daemon) backingOff(target config.Target) *failure {
+	if f := d.failures[target.ID()]; f != nil && f.count > f.backoffFrom {
+		return f
+	}
+	return nil
+}
+
+// dueAfter returns when a target checked at last is due again: its interval
+// later, unless a shorter interval starting at an hour in between, e.g. a
+// hot hour of Polling, is due earlier. The caller holds d.mu.
+func (d *Daemon) dueAfter(target config.Target, last time.Time) time.Time {
+	due := last.Add(d.targetInterval(target, last))
+	// Hours start at the same time in Japan and UTC
+	for hour := last.Truncate(time.Hour).Add(time.Hour); hour.Before(due); hour = hour.Add(time.Hour) {
+		if at := last.Add(d.targetInterval(target, hour)); at.Before(due) {
+			if at.Before(hour) {
+				at = hour
+			}
+			return at
+		}
+	}
+	return due
+}
+
+// untilDue returns the wait until the next target is due, and whether it's
+// the retry of a target backing off. The caller holds d.mu.
+func (d *Daemon) untilDue(now time.Time) (time.Duration, bool) {
+	if len(d.targets) == 0 {
+		return max(0, d.lastCheck.Add(d.currentInterval()).Sub(now)), false
+	}
+	next, retry := d.dueAt(d.targets[0]), d.backingOff(d.targets[0]) != nil
+	for _, t := range d.targets[1:] {
+		if due := d.dueAt(t); due.Before(next) {
+			next, retry = due, d.backingOff(t) != nil
+		}
+	}
+	return max(0, next.Sub(now)), retry
+}
+
+// dueTargets returns the targets due at now, and those due within half the
+// shortest interval, so targets on close schedules are checked together.
+// Targets backing off are only retried once due, and don't bring the
+// others forward. The caller holds d.mu.
+func (d *Daemon) dueTargets(targets []config.Target, now time.Time) []config.Target {
+	horizon := now.Add(d.tickInterval(now) / 2)
+	due := make([]config.Target, 0, len(targets))
+	for _, t := range targets {
+		by := horizon
+		if d.backingOff(t) != nil {
+			by = now
+		}
+		if !d.dueAt(t).After(by) {
+			due = append(due, t)
+		}
+	}
+	return due
+}
+
+// activeInterval returns the interval in effect
+func (d *Daemon) activeInterval() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.currentInterval()
+}
+
+// reschedule makes Run compute the wait for the next check again
+func (d *Daemon) reschedule() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pause stops scheduled checks until Resume is called
+func (d *Daemon) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.paused = true
+	d.pausedUntil = time.Time{}
+	d.savePause(time.Time{})
+	log.Printf("⏸ Scraping paused")
+}
+
+// PauseFor stops scheduled checks for the given duration, after which they
+// resume on their own, unless Resume is called first. It returns when
+// checks resume.
+func (d *Daemon) PauseFor(duration time.Duration) (time.Time, error) {
+	if duration <= 0 {
+		return time.Time{}, fmt.Errorf("pause duration must be positive")
+	}
+	until := time.Now().Add(duration)
+	d.mu.Lock()
+	d.paused = true
+	d.pausedUntil = until
+	d.savePause(until)
+	d.mu.Unlock()
+	d.reschedule()
+	log.Printf("⏸ Scraping paused until %s", until.Format("01/02 15:04"))
+	return until, nil
+}
+
+// Resume restarts scheduled checks
+func (d *Daemon) Resume() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.paused = false
+	d.pausedUntil = time.Time{}
+	d.savePause(time.Time{})
+	log.Printf("▶ Scraping resumed")
+	d.reschedule()
+}
+
+// savePause records the pause state in PauseState, if set, logging a
+// failure: the pause still applies until the process exits. The caller
+// holds d.mu.
+func (d *Daemon) savePause(until time.Time) {
+	if d.PauseState == nil {
+		return
+	}
+	var err error
+	if d.paused {
+		err = d.PauseState.Set(until)
+	} else {
+		err = d.PauseState.Clear()
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to save the pause state: %v", err)
+	}
+}
+
+// RestorePause pauses again if PauseState kept a pause from a previous run
+// that isn't over, reporting whether it did. Call it before Run.
+func (d *Daemon) RestorePause() bool {
+	if d.PauseState == nil {
+		return false
+	}
+	state := d.PauseState.State()
+	if !state.Paused {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !state.Until.IsZero() && !time.Now().Before(state.Until) {
+		d.savePause(time.Time{}) // Over while stopped
+		return false
+	}
+	d.paused = true
+	d.pausedUntil = state.Until
+	return true
+}
+
+// pausedWait returns how long to wait while paused: the interval, or until
+// the end of a timed pause if sooner
+func (d *Daemon) pausedWait() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	wait := d.currentInterval()
+	if !d.pausedUntil.IsZero() {
+		wait = min(wait, max(0, time.Until(d.pausedUntil)))
+	}
+	return wait
+}
+
+// Targets returns the monitored targets
+func (d *Daemon) Targets() []config.Target {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.targets
+}
+
+// Errors of target changes
+var (
+	// ErrFixedTargets is returned when the checker's targets can't change
+	// while running
+	ErrFixedTargets = errors.New("targets can't be changed while running")
+
+	// ErrNoTargets is returned when a change would leave no target
+	ErrNoTargets = errors.New("no targets")
+
+	// ErrUnknownTarget is returned by RemoveTarget for a target that isn't
+	// monitored
+	ErrUnknownTarget = errors.New("not a monitored target")
+)
+
+// SetTargets changes the monitored targets from the next check on. They're
+// kept across restarts if TargetList is set, until ResetTargets.
+func (d *Daemon) SetTargets(targets []config.Target) error {
+	d.retarget.Lock()
+	defer d.retarget.Unlock()
+	return d.setTargets(targets, true)
+}
+
+// AddTarget monitors one more target, or replaces the target of the same
+// location and category, e.g. to mark it critical
+func (d *Daemon) AddTarget(target config.Target) error {
+	d.retarget.Lock()
+	defer d.retarget.Unlock()
+
+	targets := append([]config.Target(nil), d.Targets()...)
+	replaced := false
+	for i, t := range targets {
+		if t.Location == target.Location && t.Category == target.Category {
+			targets[i], replaced = target, true
+		}
+	}
+	if !replaced {
+		targets = append(targets, target)
+	}
+	return d.setTargets(targets, true)
+}
+
+// RemoveTarget stops monitoring the target of the location and category
+func (d *Daemon) RemoveTarget(location, category string) error {
+	d.retarget.Lock()
+	defer d.retarget.Unlock()
+
+	var targets []config.Target
+	for _, t := range d.Targets() {
+		if t.Location != location || t.Category != category {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == len(d.Targets()) {
+		return ErrUnknownTarget
+	}
+	return d.setTargets(targets, true)
+}
+
+// SetConfigured changes the configured targets, e.g. after the config is
+// reloaded, and monitors them unless the targets were changed while running:
+// those changes are kept until ResetTargets.
+func (d *Daemon) SetConfigured(targets []config.Target) error {
+	d.retarget.Lock()
+	defer d.retarget.Unlock()
+
+	if len(targets) == 0 {
+		return ErrNoTargets
+	}
+	current, configured := d.Targets(), d.configured
+	d.configured = targets
+	switch {
+	case !reflect.DeepEqual(current, configured):
+		log.Printf("🎯 Keeping the targets changed while running (%s), `ctl targets reset` goes to the configured ones", describeTargets(current))
+		return nil
+	case reflect.DeepEqual(current, targets):
+		return nil
+	}
+	return d.setTargets(targets, false)
+}
+
+// ResetTargets goes back to the configured targets, forgetting the changes
+func (d *Daemon) ResetTargets() error {
+	d.retarget.Lock()
+	defer d.retarget.Unlock()
+
+	if err := d.setTargets(d.configured, false); err != nil {
+		return err
+	}
+	if d.TargetList != nil {
+		if err := d.TargetList.Clear(); err != nil {
+			return fmt.Errorf("failed to forget the changed targets: %v", err)
+		}
+	}
+	return nil
+}
+
+// RestoreTargets monitors the targets TargetList kept from a previous run,
+// if any, reporting whether there were some. Call it before Run.
+func (d *Daemon) RestoreTargets() (bool, error) {
+	if d.TargetList == nil {
+		return false, nil
+	}
+	targets := d.TargetList.Targets()
+	if len(targets) == 0 {
+		return false, nil
+	}
+	d.retarget.Lock()
+	defer d.retarget.Unlock()
+	return true, d.setTargets(targets, false)
+}
+
+// setTargets applies the targets, saving them first if save is set. The
+// caller holds d.retarget.
+func (d *Daemon) setTargets(targets []config.Target, save bool) error {
+	r, ok := d.checker.(Retargetable)
+	if !ok {
+		return ErrFixedTargets
+	}
+	if len(targets) == 0 {
+		return ErrNoTargets
+	}
+	if save && d.TargetList != nil {
+		if err := d.TargetList.Set(targets); err != nil {
+			return fmt.Errorf("failed to save targets: %v", err)
+		}
+	}
+	r.SetTargets(targets)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.targets = targets
+	log.Printf("🎯 Targets changed to %s", describeTargets(targets))
+	return nil
+}
+
+// describeTargets renders targets as "location (category), ..."
+func describeTargets(targets []config.Target) string {
+	var parts []string
+	for _, t := range targets {
+		if t.Category == "" {
+			parts = append(parts, t.Location)
+		} else {
+			parts = append(parts, fmt.Sprintf("%s (%s)", t.Location, t.Category))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ErrNoSnoozes is returned by snooze methods when snoozing is not enabled
+var ErrNoSnoozes = errors.New("snoozing is not enabled")
+
+// Snooze stops notifications about a date for the given duration
+func (d *Daemon) Snooze(date string, duration time.Duration) (snooze.Entry, error) {
+	if d.Snoozes == nil {
+		return snooze.Entry{}, ErrNoSnoozes
+	}
+	e, err := d.Snoozes.Snooze(date, duration)
+	if err == nil {
+		log.Printf("💤 Snoozed %s until %s", date, e.Until.Format("01/02 15:04"))
+	}
+	return e, err
+}
+
+// Unsnooze resumes notifications about a date
+func (d *Daemon) Unsnooze(date string) error {
+	if d.Snoozes == nil {
+		return ErrNoSnoozes
+	}
+	if err := d.Snoozes.Unsnooze(date); err != nil {
+		return err
+	}
+	log.Printf("⏰ Unsnoozed %s", date)
+	return nil
+}
+
+// ErrNoBooked is returned by booking methods when the booked flag is not
+// enabled
+var ErrNoBooked = errors.New("marking as booked is not enabled")
+
+// MarkBooked stops slot notifications, as the user has booked elsewhere
+func (d *Daemon) MarkBooked(note string) (booked.State, error) {
+	if d.Booked == nil {
+		return booked.State{}, ErrNoBooked
+	}
+	s, err := d.Booked.Set(note)
+	if err != nil {
+		return s, err
+	}
+	if d.Escalation != nil {
+		d.Escalation.Acknowledge()
+	}
+	log.Printf("📕 Marked as booked, slots won't be notified until re-armed")
+	return s, nil
+}
+
+// Rearm resumes slot notifications after MarkBooked
+func (d *Daemon) Rearm() error {
+	if d.Booked == nil {
+		return ErrNoBooked
+	}
+	if err := d.Booked.Clear(); err != nil {
+		return err
+	}
+	if d.Cooldown != nil {
+		if err := d.Cooldown.Reset(); err != nil {
+			log.Printf("❌ Failed to reset notification cooldowns: %v", err)
+		}
+	}
+	if d.Changes != nil {
+		if err := d.Changes.Reset(); err != nil {
+			log.Printf("❌ Failed to reset open slots: %v", err)
+		}
+	}
+	log.Printf("📖 Re-armed, slots will be notified again")
+	return nil
+}
+
+// BookedState returns whether the user is marked as booked
+func (d *Daemon) BookedState() booked.State {
+	if d.Booked == nil {
+		return booked.State{}
+	}
+	return d.Booked.State()
+}
+
+// Acknowledge confirms found slots were seen, cancelling the escalation
+// call. It returns false if no call was pending.
+func (d *Daemon) Acknowledge() bool {
+	if d.Escalation == nil || !d.Escalation.Acknowledge() {
+		return false
+	}
+	log.Printf("👍 Slots acknowledged, escalation cancelled")
+	return true
+}
+
+// ErrNoHistory is returned by history queries when the history is not
+// recorded
+var ErrNoHistory = errors.New("the check history is not recorded")
+
+// CheckHistory returns a page of the recorded checks matching the filter,
+// newest first, and the total number of matches. They come from the store
+// if kept, shared by the instances using the same database, else from the
+// local history.
+func (d *Daemon) CheckHistory(f history.Filter, offset, limit int) ([]scraper.CheckResult, int, error) {
+	if d.Store != nil {
+		return d.Store.Checks(f, offset, limit)
+	}
+	if d.History == nil {
+		return nil, 0, ErrNoHistory
+	}
+	return d.History.Checks(f, offset, limit)
+}
+
+// SlotHistory returns a page of the slots seen by recorded checks matching
+// the filter, latest first, and the total number of matches, from the store
+// if kept like CheckHistory
+func (d *Daemon) SlotHistory(f history.Filter, offset, limit int) ([]history.SlotRecord, int, error) {
+	if d.Store != nil {
+		return d.Store.Slots(f, offset, limit)
+	}
+	if d.History == nil {
+		return nil, 0, ErrNoHistory
+	}
+	return d.History.Slots(f, offset, limit)
+}
+
+// SnoozedDates returns the active snoozes
+func (d *Daemon) SnoozedDates() []snooze.Entry {
+	if d.Snoozes == nil {
+		return []snooze.Entry{}
+	}
+	return d.Snoozes.Entries()
+}
+
+// Status returns a snapshot of the daemon state
+func (d *Daemon) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := Status{
+		Paused:        d.paused,
+		Standby:       d.Standby != nil && d.Standby(),
+		AwaitingAck:   d.Escalation != nil && d.Escalation.Pending(),
+		Booked:        d.Booked != nil && d.Booked.State().Booked,
+		Checking:      d.checking,
+		Interval:      d.currentInterval().String(),
+		LastCheck:     d.lastCheck,
+		NextCheck:     d.nextCheck,
+		LastResult:    d.lastResult,
+		ErrorCounts:   make(map[string]int, len(d.errorCounts)),
+		Targets:       d.targets,
+		Health:        d.health.Targets(d.targets, d.staleAfter, time.Now()),
+		Notifications: append([]Notification{}, d.notifications...),
+	}
+	for class, n := range d.errorCounts {
+		s.ErrorCounts[class] = n
+	}
+	if d.Counters != nil {
+		totals := d.Counters.Totals()
+		s.Totals = &totals
+	}
+	if !d.sprintUntil.IsZero() {
+		until := d.sprintUntil
+		s.SprintUntil = &until
+	}
+	if d.paused && !d.pausedUntil.IsZero() {
+		until := d.pausedUntil
+		s.PausedUntil = &until
+	}
+	if f := d.worstFailure(); f != nil {
+		s.ConsecutiveErrors = f.count
+		if n := f.count - f.backoffFrom; n > 0 {
+			s.Backoff = backoff(n, f.err).String()
+		}
+		s.LastError = f.err.Error()
+		s.LastErrorClass = scraper.ErrorClass(f.err)
+		s.LastErrorStep = scraper.ErrorStep(f.err)
+	}
+	if t, ok := d.checker.(Throttled); ok {
+		s.PageDelay = t.PageDelay().String()
+	}
+	if len(d.StatusExtras) > 0 {
+		s.Extras = make(map[string]interface{}, len(d.StatusExtras))
+		for name, extra := range d.StatusExtras {
+			s.Extras[name] = extra()
+		}
+	}
+	return s
+}
+
+// Probe is the liveness and readiness of the daemon, for container probes
+type Probe struct {
+	Live        bool      `json:"live"`  // Checks are running, or waiting as scheduled
+	Ready       bool      `json:"ready"` // A recent check succeeded, or another instance is checking
+	Reason      string    `json:"reason,omitempty"`
+	LastCheck   time.Time `json:"last_check"`
+	LastSuccess time.Time `json:"last_success"`
+	Browser     string    `json:"browser,omitempty"` // checking, idle or stopped
+}
+
+// Probe tells whether the daemon is live, not wedged inside a check or
+// its scheduling, and ready, with a successful check within the last few
+// intervals
+func (d *Daemon) Probe() Probe {
+	var browser string
+	if b, ok := d.checker.(Browsing); ok {
+		browser = b.BrowserState()
+	}
+	standby := d.Standby != nil && d.Standby()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	p := Probe{Live: true, LastCheck: d.lastCheck, Browser: browser}
+	if d.lastResult != nil {
+		p.LastSuccess = d.lastResult.CheckedAt
+	}
+	switch {
+	case d.checking && now.Sub(d.checkStarted) > wedgedAfter:
+		p.Live = false
+		p.Reason = fmt.Sprintf("check running for %s", now.Sub(d.checkStarted).Round(time.Second))
+	case !d.checking && !d.nextCheck.IsZero() && now.Sub(d.nextCheck) > wedgedAfter:
+		p.Live = false
+		p.Reason = fmt.Sprintf("scheduled check overdue by %s", now.Sub(d.nextCheck).Round(time.Second))
+	case standby || d.paused:
+		p.Ready = true
+	case p.LastSuccess.IsZero():
+		p.Reason = "no successful check yet"
+	case p.LastSuccess.Before(d.Maintenance.Sub(now, staleChecks*d.tickInterval(now)+wedgedAfter)):
+		p.Reason = fmt.Sprintf("no successful check since %s", p.LastSuccess.Format(time.RFC3339))
+	default:
+		p.Ready = true
+	}
+	return p
+}
+
+// count adds a check of the targets, failed or not, to their health and
+// the persisted totals
+func (d *Daemon) count(targets []config.Target, result scraper.CheckResult, err error, t time.Time) {
+	opened, closed := d.health.Record(targets, result, err, t)
+	for _, target := range opened {
+		d.alert(fmt.Sprintf("⚠️ %s not found in %d checks in a row, leaving it out of the checks and retrying it now and then",
+			describeTargets([]config.Target{target}), health.BrokenAfter))
+	}
+	for _, target := range closed {
+		d.alert(fmt.Sprintf("✓ %s found again, checking it as usual", describeTargets([]config.Target{target})))
+	}
+	if d.Counters != nil {
+		if err := d.Counters.Record(result, err, t); err != nil {
+			log.Printf("❌ Failed to save check totals: %v", err)
+		}
+	}
+}
+
+// recordCheck records a check in the store, if kept. Successful checks are
+// recorded with the slots open after them, as in the history.
+func (d *Daemon) recordCheck(result scraper.CheckResult, err error, t time.Time) {
+	if d.Store == nil {
+		return
+	}
+	if err != nil {
+		result = scraper.NewCheckResult(t, nil)
+	}
+	if err := d.Store.RecordCheck(result, err); err != nil {
+		log.Printf("❌ Failed to store check: %v", err)
+	}
+}
+
+// check runs a single check and notifies about found slots. It leaves out
+// the targets not due yet on their own interval, and those whose circuit is
+// open until their retry is due, unless all is set, e.g. for checks
+// requested by users.
+func (d *Daemon) check(all bool) (scraper.CheckResult, error) {
+	targets := d.Targets()
+	checked := targets
+	due := targets // Checked on their interval, even if their circuit leaves them out
+	selective, ok := d.checker.(Selective)
+	if ok && !all {
+		now := time.Now()
+		d.mu.Lock()
+		due = d.dueTargets(targets, now)
+		d.mu.Unlock()
+		checked = d.health.Due(due, now)
+		if len(checked) == 0 {
+			if len(due) == 0 {
+				log.Printf("⏭ No target is due yet, skipping the check")
+				return scraper.CheckResult{}, nil
+			}
+			log.Printf("⏭ Every target due is left out until its retry, skipping the check")
+			d.stamp(due, now)
+			return scraper.CheckResult{}, nil
+		}
+		if left := len(due) - len(checked); left > 0 {
+			log.Printf("⏭ Leaving out %d target(s) not found lately, until their retry", left)
+		}
+		if later := len(targets) - len(due); later > 0 {
+			log.Printf("⏭ %d target(s) not due yet on their own interval", later)
+		}
+	}
+
+	d.mu.Lock()
+	d.checking = true
+	d.checkStarted = time.Now()
+	d.mu.Unlock()
+
+	var result scraper.CheckResult
+	var errs map[string]error // By target ID
+	if isolating, ok := d.checker.(Isolating); ok && len(checked) > 1 {
+		result, errs = isolating.CheckEach(checked)
+	} else {
+		var err error
+		if len(checked) < len(targets) {
+			result, err = selective.CheckTargets(checked)
+		} else {
+			result, err = d.checker.CheckAvailability()
+		}
+		if err != nil {
+			errs = make(map[string]error, len(checked))
+			for _, t := range checked {
+				errs[t.ID()] = err
+			}
+		}
+	}
+	now := time.Now()
+
+	var failed, read []config.Target
+	for _, t := range checked {
+		if errs[t.ID()] != nil {
+			failed = append(failed, t)
+		} else {
+			read = append(read, t)
+		}
+	}
+	if len(failed) > 0 {
+		err := d.fail(failed, errs, len(read) == 0, now)
+		if len(read) == 0 {
+			d.count(checked, scraper.CheckResult{}, err, now)
+			d.recordCheck(scraper.CheckResult{}, err, now)
+			d.alertLaunch(err)
+			if d.CheckDone != nil {
+				d.CheckDone(scraper.CheckResult{}, err)
+			}
+			return scraper.CheckResult{}, err
+		}
+		// Failed checks don't open or close circuits
+		d.health.Record(failed, scraper.CheckResult{}, err, now)
+	}
+
+	d.mu.Lock()
+	d.checking = false
+	d.lastCheck = now
+	d.lastFailed = false
+	// Targets read are no longer failing
+	var recovered []config.Target
+	failures := 0
+	for _, t := range read {
+		if f := d.failures[t.ID()]; f != nil {
+			if d.AlertThreshold > 0 && f.count >= d.AlertThreshold {
+				recovered = append(recovered, t)
+				failures = max(failures, f.count)
+			}
+			delete(d.failures, t.ID())
+		}
+	}
+	markCritical(result.Slots, targets)
+	d.lastResult = &result
+	for _, t := range due {
+		d.lastChecked[t.ID()] = now
+	}
+	// Targets left out, or whose check failed, keep the slots they had
+	// open, so the change feed and the history don't take them for gone
+	open := result.Slots
+	if len(read) < len(targets) {
+		open = append(carriedSlots(d.open, targets, read), result.Slots...)
+	}
+	d.open = open
+	d.mu.Unlock()
+	recorded := result
+	recorded.Slots = open
+	d.count(read, result, nil, now)
+	d.recordCheck(recorded, nil, now)
+	d.alertLaunch(nil)
+
+	if len(recovered) > 0 {
+		d.alert(fmt.Sprintf("✓ Scraper recovered after %d failed checks of %s", failures, describeTargets(recovered)))
+	}
+
+	LogResult(result)
+	if d.CheckDone != nil {
+		d.CheckDone(result, nil)
+	}
+	if d.AlertWarnings {
+		d.alertWarnings(result.Warnings)
+	} else {
+		// Slot dates going back may be misdated, which is always alerted
+		var order []scraper.Warning
+		for _, w := range result.Warnings {
+			if w.Code == scraper.WarnDateOrder {
+				order = append(order, w)
+			}
+		}
+		d.alertWarnings(order)
+	}
+	if d.TableWatch != nil {
+		text := ""
+		if r, ok := d.checker.(TableReader); ok {
+			text = r.TableText()
+		}
+		if alert, err := d.TableWatch.Observe(len(result.Rows) > 0, text, now); err != nil {
+			log.Printf("❌ Failed to save the table snapshot: %v", err)
+		} else if alert != "" {
+			d.alert(alert)
+		}
+	}
+	if d.History != nil {
+		if err := d.History.Record(recorded); err != nil {
+			log.Printf("❌ Failed to record check history: %v", err)
+		}
+	}
+	slots := result.Slots
+	var gone []scraper.Slot
+	if d.Changes != nil {
+		change, err := d.Changes.Update(open)
+		if err != nil {
+			log.Printf("❌ Failed to save open slots: %v", err)
+		}
+		if d.OnlyNew {
+			// Carried slots were open before, so only checked ones appear
+			slots = change.Appeared
+			if kept := len(result.Slots) - len(slots); kept > 0 {
+				log.Printf("📌 %d slot(s) still open since the previous check", kept)
+			}
+		}
+		if d.NotifyGone {
+			gone = change.Gone
+		}
+	}
+	if d.Booked != nil && d.Booked.State().Booked {
+		if len(slots) > 0 {
+			log.Printf("📕 Booked elsewhere, not notifying about %d slot(s)", len(slots))
+		}
+		return result, nil
+	}
+	if d.Snoozes != nil {
+		n := len(slots)
+		slots = d.Snoozes.Filter(slots)
+		if skipped := n - len(slots); skipped > 0 {
+			log.Printf("💤 Skipping %d snoozed slot(s)", skipped)
+		}
+		gone = d.Snoozes.Filter(gone)
+	}
+	if d.quiet() {
+		n := len(slots)
+		slots, gone = criticalSlots(slots), criticalSlots(gone)
+		if skipped := n - len(slots); skipped > 0 {
+			log.Printf("🔕 Quiet hours, not notifying %d slot(s)", skipped)
+		}
+	}
+	if d.Cooldown != nil && len(slots) > 0 {
+		n := len(slots)
+		slots = d.Cooldown.Filter(slots) // Keeps critical slots
+		if skipped := n - len(slots); skipped > 0 {
+			log.Printf("🔕 Skipping %d slot(s) already notified", skipped)
+		}
+	}
+	if len(slots) > 0 {
+		err := d.notifier.NotifyAvailableSlots(slots)
+		if err != nil {
+			log.Printf("Error sending notification: %v", err)
+		} else {
+			d.reported(slots)
+		}
+		d.alertNotify(err)
+	}
+	if len(gone) > 0 {
+		log.Printf("👋 %d notified slot(s) gone", len(gone))
+		if a, ok := d.notifier.(notify.Alerter); ok {
+			if err := a.Alert(notify.GoneText(gone)); err != nil {
+				log.Printf("Error sending notification: %v", err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// fail records the failed checks of targets, each backing off on its own,
+// with the errors by target ID, and logs, alerts and reports them. all
+// tells whether every target checked failed. It returns the error of the
+// first target.
+func (d *Daemon) fail(failed []config.Target, errs map[string]error, all bool, t time.Time) error {
+	err := errs[failed[0].ID()]
+	d.mu.Lock()
+	d.checking = false
+	d.lastCheck = t
+	d.lastFailed = all
+	d.errorCounts[scraper.ErrorClass(err)]++
+	counts := make(map[string]int, len(failed))
+	for _, target := range failed {
+		f := d.failures[target.ID()]
+		if f == nil {
+			f = &failure{}
+			d.failures[target.ID()] = f
+		}
+		f.count++
+		f.err = errs[target.ID()]
+		counts[target.ID()] = f.count
+		d.lastChecked[target.ID()] = t
+	}
+	d.mu.Unlock()
+
+	// Targets failing alike are logged and alerted together, usually all
+	// of them as Chrome or the site is at fault
+	var groups [][]config.Target
+	for _, target := range failed {
+		i := 0
+		for i < len(groups) && errs[groups[i][0].ID()].Error() != errs[target.ID()].Error() {
+			i++
+		}
+		if i == len(groups) {
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], target)
+	}
+	for _, group := range groups {
+		err, failures, repeated := errs[group[0].ID()], 0, false
+		for _, target := range group {
+			failures = max(failures, counts[target.ID()])
+			repeated = repeated || (d.AlertThreshold > 0 && counts[target.ID()] == d.AlertThreshold)
+		}
+		about := ""
+		if !all {
+			about = " of " + describeTargets(group)
+		}
+		if scraper.ErrorClass(err) == scraper.ClassTimeout {
+			log.Printf("⏱ Check%s %v", about, err)
+		} else {
+			log.Printf("Error during check%s: %v", about, err)
+		}
+		if repeated {
+			d.alert(fmt.Sprintf("⚠️ Scraper unhealthy: %d checks in a row%s failed. Last error (%s): %v",
+				failures, about, scraper.ErrorClass(err), err))
+		}
+		if repeated || scraper.ErrorStep(err) == scraper.StepPanic {
+			d.report(group, err, failures)
+		}
+	}
+	return err
+}
+
+// stamp records targets as checked at t
+func (d *Daemon) stamp(targets []config.Target, t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, target := range targets {
+		d.lastChecked[target.ID()] = t
+	}
+}
+
+// staleAfter returns how long a target may go unread before it's stale,
+// a few of its intervals, plus the site's maintenance in between. The
+// caller holds d.mu.
+func (d *Daemon) staleAfter(t config.Target) time.Duration {
+	now := time.Now()
+	return now.Sub(d.Maintenance.Sub(now, staleChecks*d.intervalOf(t)))
+}
+
+// criticalSlots returns the slots of critical targets
+func criticalSlots(slots []scraper.Slot) []scraper.Slot {
+	var critical []scraper.Slot
+	for _, slot := range slots {
+		if slot.Critical {
+			critical = append(critical, slot)
+		}
+	}
+	return critical
+}
+
+// markCritical flags the slots of critical targets, which notifiers let
+// through quiet hours, cooldowns and digests
+func markCritical(slots []scraper.Slot, targets []config.Target) {
+	for i, slot := range slots {
+		for _, t := range targets {
+			if t.Critical && t.Matches(slot.Location, slot.Category) {
+				slots[i].Critical = true
+				break
+			}
+		}
+	}
+}
+
+// carriedSlots returns the slots of open that belong to targets but not
+// to the checked ones
+func carriedSlots(open []scraper.Slot, targets, checked []config.Target) []scraper.Slot {
+	matches := func(slot scraper.Slot, targets []config.Target) bool {
+		for _, t := range targets {
+			if t.Matches(slot.Location, slot.Category) {
+				return true
+			}
+		}
+		return false
+	}
+	var carried []scraper.Slot
+	for _, slot := range open {
+		if matches(slot, targets) && !matches(slot, checked) {
+			carried = append(carried, slot)
+		}
+	}
+	return carried
+}
+
+// reported records the slots as notified, for the cooldown, to tell when
+// they're gone and to list them in Status
+func (d *Daemon) reported(slots []scraper.Slot) {
+	d.mu.Lock()
+	d.notifications = append([]Notification{{At: time.Now(), Slots: slots}}, d.notifications...)
+	if len(d.notifications) > maxNotifications {
+		d.notifications = d.notifications[:maxNotifications]
+	}
+	d.mu.Unlock()
+	if d.Cooldown != nil {
+		if err := d.Cooldown.Record(slots); err != nil {
+			log.Printf("❌ Failed to record notified slots: %v", err)
+		}
+	}
+	if d.Changes != nil && d.NotifyGone {
+		if err := d.Changes.Reported(slots); err != nil {
+			log.Printf("❌ Failed to record notified slots: %v", err)
+		}
+	}
+}
+
+// alert sends an operational alert if an alerter is configured
+func (d *Daemon) alert(text string) {
+	log.Print(text)
+	if d.Alerter == nil {
+		return
+	}
+	if err := d.Alerter.Alert(text); err != nil {
+		log.Printf("Error sending alert: %v", err)
+	}
+}
+
+// report sends a failed check of the targets to Tracker, if set, with
+// where it failed and the page and screenshot it left
+func (d *Daemon) report(targets []config.Target, err error, failures int) {
+	if d.Tracker == nil {
+		return
+	}
+	step := scraper.ErrorStep(err)
+	if step == "" {
+		step = "check"
+	}
+	event := sentry.Event{
+		Message: err.Error(),
+		Type:    step,
+		Tags: map[string]string{
+			"step":    step,
+			"class":   scraper.ErrorClass(err),
+			"targets": describeTargets(targets),
+		},
+		Extra:       map[string]interface{}{"failures_in_a_row": failures},
+		Fingerprint: []string{"check", step},
+	}
+	var stepErr *scraper.StepError
+	if errors.As(err, &stepErr) {
+		if stepErr.Page > 0 {
+			event.Tags["page"] = strconv.Itoa(stepErr.Page)
+		}
+		if stepErr.Artifacts != "" {
+			event.Extra["artifacts"] = stepErr.Artifacts
+			event.Extra["screenshot"] = filepath.Join(stepErr.Artifacts, "screenshot.png")
+		}
+	}
+	var panicErr *scraper.PanicError
+	if errors.As(err, &panicErr) {
+		event.Extra["stack"] = string(panicErr.Stack)
+		// Panics group by their value, not by the step
+		event.Fingerprint = nil
+	}
+	if err := d.Tracker.Capture(event); err != nil {
+		log.Printf("Error reporting to Sentry: %v", err)
+	}
+}
+
+// alertLaunch alerts right away when Chrome can't start, e.g. a missing
+// binary or a cgroup limit, as no check can work until it's fixed and there
+// is no browserless way to read the table, and again once it starts
+func (d *Daemon) alertLaunch(err error) {
+	down := scraper.ErrorStep(err) == scraper.StepLaunch
+	if down == d.chromeDown {
+		return
+	}
+	// A check failing later than the launch means Chrome started
+	d.chromeDown = down
+	if down {
+		d.alert(fmt.Sprintf("🧯 Chrome can't start, no check can run until it does (retrying with backoff): %v", err))
+	} else {
+		d.alert("✓ Chrome starts again")
+	}
+}
+
+// alertNotify alerts when slots can't be notified, e.g. the LINE API
+// failing, and again once they can. The alert goes through Alerter, which
+// can have channels of its own.
+func (d *Daemon) alertNotify(err error) {
+	down := err != nil
+	if down == d.notifyDown {
+		return
+	}
+	d.notifyDown = down
+	if down {
+		d.alert(fmt.Sprintf("📵 Failed to notify slots: %v", err))
+	} else {
+		d.alert("✓ Slots are notified again")
+	}
+}
+
+// maxAlertedWarnings caps the warnings listed in one alert
+const maxAlertedWarnings = 10
+
+// alertWarnings alerts about warnings unless the same kinds were already
+// alerted, so a persistent problem is reported once rather than every check
+func (d *Daemon) alertWarnings(warnings []scraper.Warning) {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, w := range warnings {
+		if !seen[w.Code] {
+			seen[w.Code] = true
+			codes = append(codes, w.Code)
+		}
+	}
+	sort.Strings(codes)
+	key := strings.Join(codes, ",")
+	if key == d.lastWarningCodes {
+		return
+	}
+	d.lastWarningCodes = key
+	if key == "" {
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "⚠️ Check finished with %d warning(s):", len(warnings))
+	for i, w := range warnings {
+		if i == maxAlertedWarnings {
+			fmt.Fprintf(&sb, "\n…and %d more", len(warnings)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "\n- [%s] %s", w.Code, w)
+	}
+	d.alert(sb.String())
+}
+
+// LogResult logs the outcome of a check and its warnings
+func LogResult(result scraper.CheckResult) {
+	for _, w := range result.Warnings {
+		log.Printf("⚠️ %s", w)
+	}
+	if len(result.Slots) > 0 {
+		log.Printf("🎯 %s", result.Summary())
+	} else {
+		log.Printf("✓ %s", result.Summary())
+	}
+}
+
+// nextWait returns how long to wait before the next scheduled check, and
+// why
+func (d *Daemon) nextWait() (time.Duration, string) {
+	d.mu.Lock()
+	wait, retry := d.untilDue(time.Now())
+	reason := d.intervalReason(time.Now())
+	d.mu.Unlock()
+	if retry {
+		reason = ReasonBackoff
+	}
+	return d.skipMaintenance(wait, reason)
+}
+
+// skipMaintenance moves a check due after wait to the end of the site's
+// maintenance, if it falls within
+func (d *Daemon) skipMaintenance(wait time.Duration, reason string) (time.Duration, string) {
+	now := time.Now()
+	at := now.Add(wait)
+	if end := d.maintenanceEnd(at); end.After(at) {
+		return end.Sub(now), ReasonMaintenance
+	}
+	return wait, reason
+}
+
+// maintenanceEnd returns the end of the site's maintenance at t, or t if
+// the site isn't under maintenance then
+func (d *Daemon) maintenanceEnd(t time.Time) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.Maintenance.End(t)
+}
+
+// SetMaintenance changes the site's maintenance windows, e.g. after the
+// config is reloaded
+func (d *Daemon) SetMaintenance(w config.Windows) {
+	d.mu.Lock()
+	d.Maintenance = w
+	d.mu.Unlock()
+	d.reschedule()
+}
+
+// quiet reports whether it's quiet hours, when only critical slots are
+// notified
+func (d *Daemon) quiet() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.Quiet.Active(clock.Now())
+}
+
+// SetQuiet changes the quiet hours, e.g. after the config is reloaded
+func (d *Daemon) SetQuiet(q notify.QuietHours) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Quiet = q
+}
+
+// backoff returns the wait after n consecutive failed checks
+func backoff(n int, lastErr error) time.Duration {
+	var backoffDuration time.Duration
+	if scraper.ErrorClass(lastErr) == scraper.ClassTimeout {
+		// A slow or overloaded site needs time to recover, so timeouts
+		// back off from 30 seconds, doubling each time
+		backoffDuration = 30 * time.Second << min(n-1, 4)
+	} else {
+		// Exponential backoff for consecutive errors
+		backoffDuration = time.Duration(n*n) * time.Second
+	}
+	if backoffDuration > 5*time.Minute {
+		backoffDuration = 5 * time.Minute // Cap at 5 minutes
+	}
+	return backoffDuration
+}
+
+// isPaused reports whether scheduled checks are paused, ending a timed
+// pause that is over
+func (d *Daemon) isPaused() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.paused && !d.pausedUntil.IsZero() && !time.Now().Before(d.pausedUntil) {
+		d.paused = false
+		d.pausedUntil = time.Time{}
+		d.savePause(time.Time{})
+		log.Printf("▶ Scraping resumed, the pause is over")
+	}
+	return d.paused
+}
+
+func (d *Daemon) lastErrored() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastFailed
+}
+
+func (d *Daemon) consecutiveErrorCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if f := d.worstFailure(); f != nil {
+		return f.count
+	}
+	return 0
+}
+
+// worstFailure returns the failed checks of the target failing longest, nil
+// if none is failing. The caller holds d.mu.
+func (d *Daemon) worstFailure() *failure {
+	var worst *failure
+	for _, t := range d.targets {
+		if f := d.failures[t.ID()]; f != nil && (worst == nil || f.count > worst.count) {
+			worst = f
+		}
+	}
+	return worst
+}
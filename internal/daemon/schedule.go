@@ -0,0 +1,159 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"policeScrapper/pkg/config"
+)
+
+// Reasons of scheduled checks
+const (
+	ReasonStartup     = "startup"     // The first check
+	ReasonInterval    = "interval"    // The configured interval after the last check
+	ReasonSprint      = "sprint"      // The sprint interval, see Sprint
+	ReasonHot         = "hot"         // The hot interval of adaptive polling, see Polling
+	ReasonBackoff     = "backoff"     // A retry after failed checks
+	ReasonStandby     = "standby"     // Not a check: looking whether another instance stopped
+	ReasonPaused      = "paused"      // Not a check: looking whether the pause is over
+	ReasonResume      = "resume"      // The end of a timed pause
+	ReasonMaintenance = "maintenance" // The end of the site's maintenance, see Maintenance
+	ReasonChecking    = "checking"    // The check is running
+)
+
+// scheduleLength is how many checks Schedule projects after the next one
+const scheduleLength = 5
+
+// Schedule is when the next checks are due and why
+type Schedule struct {
+	Next     ScheduledCheck    `json:"next"`
+	Upcoming []ScheduledCheck  `json:"upcoming"` // The checks after the next one, if they succeed and nothing changes
+	Targets  []ScheduledTarget `json:"targets"`
+}
+
+// ScheduledCheck is a check due at some time
+type ScheduledCheck struct {
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason"`
+}
+
+// ScheduledTarget is the next check of a target, which is later than the
+// next check for targets left out until their retry
+type ScheduledTarget struct {
+	Location string    `json:"location"`
+	Category string    `json:"category,omitempty"`
+	Next     time.Time `json:"next"`
+	Reason   string    `json:"reason"`
+}
+
+// intervalReason returns why checks are due at t on the interval in effect.
+// The caller holds d.mu.
+func (d *Daemon) intervalReason(t time.Time) string {
+	switch {
+	case d.sprinting(t):
+		return ReasonSprint
+	case d.Polling != nil && d.Polling.Hot(t):
+		return ReasonHot
+	}
+	return ReasonInterval
+}
+
+// Schedule returns the next check and projects the following ones from the
+// targets' intervals, the sprint, the hot hours, the site's maintenance
+// and a timed pause, to see how they interact. Checks requested through CheckNow aren't scheduled.
+func (d *Daemon) Schedule() Schedule {
+	d.mu.Lock()
+	next := ScheduledCheck{At: d.nextCheck, Reason: d.nextReason}
+	if d.checking {
+		next = ScheduledCheck{At: d.checkStarted, Reason: ReasonChecking}
+	}
+	paused, pausedUntil, checking := d.paused, d.pausedUntil, d.checking
+	targets := d.targets
+	now := time.Now()
+	tick := d.tickInterval(now)
+	due := make([]time.Time, len(targets))
+	every := make([]time.Duration, len(targets))
+	for i, t := range targets {
+		due[i] = d.dueAt(t)
+		every[i] = d.targetInterval(t, now)
+	}
+	// The projection reads the sprint and the intervals at each step
+	defer d.mu.Unlock()
+
+	s := Schedule{Next: next, Upcoming: []ScheduledCheck{}}
+	if paused && !checking {
+		// Run still wakes up, but doesn't check
+		s.Next.Reason = ReasonPaused
+	}
+
+	// Each check takes the targets due by then, see dueTargets, which are
+	// due again their interval later
+	checked := make([][]time.Time, len(targets))
+	check := func(at time.Time) {
+		horizon := at.Add(d.tickInterval(at) / 2)
+		for i, t := range targets {
+			if due[i].After(horizon) {
+				continue
+			}
+			checked[i] = append(checked[i], at)
+			due[i] = d.dueAfter(t, at)
+		}
+	}
+	if !paused || !pausedUntil.IsZero() {
+		at := next.At
+		if paused {
+			at = pausedUntil
+			s.Upcoming = append(s.Upcoming, ScheduledCheck{At: at, Reason: ReasonResume})
+		}
+		for len(s.Upcoming) < scheduleLength {
+			check(at)
+			reason := d.intervalReason(at)
+			if len(targets) == 0 {
+				at = at.Add(d.tickInterval(at))
+			} else {
+				at = due[0]
+				for _, t := range due[1:] {
+					if t.Before(at) {
+						at = t
+					}
+				}
+			}
+			if end := d.Maintenance.End(at); end.After(at) {
+				at, reason = end, ReasonMaintenance
+			}
+			s.Upcoming = append(s.Upcoming, ScheduledCheck{At: at, Reason: reason})
+		}
+	}
+
+	// Targets whose circuit is open join the first check taking them once
+	// their retry is due
+	for i, h := range d.health.Targets(targets, func(config.Target) time.Duration { return 0 }, time.Now()) {
+		t := ScheduledTarget{Location: h.Location, Category: h.Category, Reason: "every check"}
+		if every[i] > tick || targets[i].Interval > 0 {
+			// On its own interval, or the configured one while others
+			// are checked more often
+			t.Reason = "every " + every[i].String()
+		}
+		if f := d.backingOff(targets[i]); f != nil {
+			t.Reason = fmt.Sprintf("retry after %d failed checks", f.count)
+		}
+		if !h.RetryAt.IsZero() {
+			t.Reason = "retry of a target not found lately"
+		}
+		for _, at := range checked[i] {
+			if !at.Before(h.RetryAt) {
+				t.Next = at
+				break
+			}
+		}
+		if t.Next.IsZero() && len(s.Upcoming) > 0 {
+			// Due after the checks projected
+			t.Next = due[i]
+			if h.RetryAt.After(t.Next) {
+				t.Next = h.RetryAt
+			}
+		}
+		s.Targets = append(s.Targets, t)
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+package adaptive
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/history"
+)
+
+// hotFactor is how many times the hourly average of slots must have
+// appeared in an hour for it to be hot
+const hotFactor = 2
+
+// updateEvery is how often the hot hours are computed again from the
+// history, which only changes slowly
+const updateEvery = 6 * time.Hour
+
+// Polling picks the interval between checks by hour of the day, Japan
+// time: the hot interval in the hours slots have appeared in most, e.g.
+// just after releases at midnight or 9:00, and the cold interval the rest
+// of the time. Until the history has minSlots slots, every hour is cold.
+type Polling struct {
+	stats    func() (history.SlotStats, error)
+	hot      time.Duration
+	cold     time.Duration
+	minSlots int
+
+	mu    sync.Mutex
+	hours [24]bool // Hot hours
+}
+
+// New creates a polling schedule from the slot statistics of stats, with
+// no hot hour until Update is called
+func New(stats func() (history.SlotStats, error), hot, cold time.Duration, minSlots int) *Polling {
+	return &Polling{stats: stats, hot: hot, cold: cold, minSlots: minSlots}
+}
+
+// Run updates the hot hours now and then periodically until ctx is cancelled
+func (p *Polling) Run(ctx context.Context) {
+	p.update()
+	ticker := time.NewTicker(updateEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.update()
+		}
+	}
+}
+
+// update updates the hot hours, logging failures and changes
+func (p *Polling) update() {
+	before := p.HotHours()
+	if err := p.Update(); err != nil {
+		log.Printf("❌ Failed to read the slot history for adaptive polling: %v", err)
+		return
+	}
+	if after := p.HotHours(); formatHours(after) != formatHours(before) {
+		if len(after) == 0 {
+			log.Printf("📈 No hour stands out in the slot history yet, checking every %s", p.cold)
+		} else {
+			log.Printf("📈 Checking every %s in the hours slots appear most (%s JST), every %s otherwise",
+				p.hot, formatHours(after), p.cold)
+		}
+	}
+}
+
+// Update computes the hot hours from the slot statistics
+func (p *Polling) Update() error {
+	stats, err := p.stats()
+	if err != nil {
+		return err
+	}
+	var hours [24]bool
+	if stats.Slots >= p.minSlots && stats.Slots > 0 {
+		for hour, n := range stats.ByHour {
+			hours[hour] = n*24 >= hotFactor*stats.Slots
+		}
+	}
+	p.mu.Lock()
+	p.hours = hours
+	p.mu.Unlock()
+	return nil
+}
+
+// Interval returns the interval at t
+func (p *Polling) Interval(t time.Time) time.Duration {
+	if p.Hot(t) {
+		return p.hot
+	}
+	return p.cold
+}
+
+// Hot reports whether t is in a hot hour
+func (p *Polling) Hot(t time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.hours[t.In(config.JST).Hour()]
+}
+
+// HotHours returns the hot hours in order
+func (p *Polling) HotHours() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var hours []int
+	for hour, hot := range p.hours {
+		if hot {
+			hours = append(hours, hour)
+		}
+	}
+	return hours
+}
+
+// formatHours lists hours as windows like depth windows, e.g. "00-02, 09-10"
+// for 0, 1 and 9
+func formatHours(hours []int) string {
+	var windows []string
+	for i := 0; i < len(hours); {
+		j := i + 1
+		for j < len(hours) && hours[j] == hours[j-1]+1 {
+			j++
+		}
+		windows = append(windows, fmt.Sprintf("%02d-%02d", hours[i], hours[j-1]+1))
+		i = j
+	}
+	return strings.Join(windows, ", ")
+}
@@ -0,0 +1,84 @@
+package booked
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State is whether the user has booked an appointment elsewhere
+type State struct {
+	Booked bool      `json:"booked"`
+	Since  time.Time `json:"since,omitempty"`
+	Note   string    `json:"note,omitempty"` // e.g. where and when
+}
+
+// Flag records that the user booked an appointment, so slots aren't
+// notified anymore. It's persisted to a JSON file so it survives restarts.
+type Flag struct {
+	path string
+
+	mu    sync.Mutex
+	state State
+}
+
+// Load reads the flag from path, starting unbooked if it doesn't exist
+func Load(path string) (*Flag, error) {
+	f := &Flag{path: path}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// Set marks the user as booked
+func (f *Flag) Set(note string) (State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = State{Booked: true, Since: time.Now(), Note: note}
+	return f.state, f.save()
+}
+
+// Clear re-arms notifications
+func (f *Flag) Clear() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = State{}
+	return f.save()
+}
+
+// State returns the current state
+func (f *Flag) State() State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+// save writes the state. Callers hold mu.
+func (f *Flag) save() error {
+	data, err := json.MarshalIndent(f.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0750); err != nil {
+		return err
+	}
+	// Replace the file atomically so backups never read it half-written
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
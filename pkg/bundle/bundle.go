@@ -0,0 +1,104 @@
+package bundle
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// File is a file copied into the bundle
+type File struct {
+	Name string // Path in the archive
+	Path string // Path on disk
+	Text bool   // Text to redact, e.g. a log file
+}
+
+// Bundle is what goes into a debug bundle, to attach to a bug report
+type Bundle struct {
+	Version string                // Build and platform information
+	Config  []byte                // Configuration in effect, redacted
+	Checks  []scraper.CheckResult // Last recorded checks
+	Files   []File                // Logs, page snapshots and screenshots
+
+	// Redact removes secrets from text files, if set
+	Redact func(text string) string
+}
+
+// Write zips the bundle to w. Files that don't exist are skipped and
+// listed in the manifest, so a partial bundle is still useful.
+func Write(w io.Writer, b Bundle) error {
+	zw := zip.NewWriter(w)
+
+	var included, missing []string
+	for _, f := range b.Files {
+		data, err := os.ReadFile(f.Path) // #nosec G304 - paths come from the scraper's own directories
+		if errors.Is(err, os.ErrNotExist) {
+			missing = append(missing, f.Name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", f.Path, err)
+		}
+		if f.Text && b.Redact != nil {
+			data = []byte(b.Redact(string(data)))
+		}
+		if err := writeFile(zw, f.Name, data); err != nil {
+			return err
+		}
+		included = append(included, f.Name)
+	}
+
+	checks, err := json.MarshalIndent(b.Checks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(zw, "checks.json", checks); err != nil {
+		return err
+	}
+	if err := writeFile(zw, "config.yaml", b.Config); err != nil {
+		return err
+	}
+	if err := writeFile(zw, "version.txt", []byte(b.Version)); err != nil {
+		return err
+	}
+	if err := writeFile(zw, "manifest.txt", []byte(manifest(b, included, missing))); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// manifest describes the bundle's contents
+func manifest(b Bundle, included, missing []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Debug bundle created %s\n\n", time.Now().Format(time.RFC3339))
+	sb.WriteString("version.txt   build and platform\n")
+	sb.WriteString("config.yaml   configuration in effect, secrets redacted\n")
+	fmt.Fprintf(&sb, "checks.json   last %d recorded checks\n", len(b.Checks))
+	for _, name := range included {
+		sb.WriteString(name + "\n")
+	}
+	if len(missing) > 0 {
+		sb.WriteString("\nNot found, so not included:\n")
+		for _, name := range missing {
+			sb.WriteString(name + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// writeFile adds a file to the archive
+func writeFile(zw *zip.Writer, name string, data []byte) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(data)
+	return err
+}
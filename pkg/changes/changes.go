@@ -0,0 +1,140 @@
+package changes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Change is how the open slots changed since the previous check
+type Change struct {
+	Appeared []scraper.Slot // Open now but not at the previous check
+	Gone     []scraper.Slot // Reported slots that aren't open anymore
+}
+
+// key identifies a slot, whatever its time windows
+type key struct {
+	location, category, date string
+}
+
+func keyOf(slot scraper.Slot) key {
+	return key{slot.Location, slot.Category, slot.Date}
+}
+
+// state is what the feed keeps between checks
+type state struct {
+	Open     []scraper.Slot `json:"open"`     // Slots open at the previous check
+	Reported []scraper.Slot `json:"reported"` // Slots notified and still open
+}
+
+// Feed turns the slots open at each check into changes. It keeps the slots
+// of the previous check, and those reported, in a JSON file so a restart
+// doesn't make every open slot look new.
+type Feed struct {
+	path string
+
+	mu    sync.Mutex
+	state state
+}
+
+// Load reads the previous slots from path, starting with none if it doesn't
+// exist
+func Load(path string) (*Feed, error) {
+	f := &Feed{path: path}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// Update compares the slots open now with the previous ones and keeps them
+// for the next check. Reported slots that are gone are forgotten, so
+// they're reported gone once. The change is returned even if saving fails.
+func (f *Feed) Update(slots []scraper.Slot) (Change, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var change Change
+	now := make(map[key]bool, len(slots))
+	for _, slot := range slots {
+		now[keyOf(slot)] = true
+	}
+	before := make(map[key]bool, len(f.state.Open))
+	for _, slot := range f.state.Open {
+		before[keyOf(slot)] = true
+	}
+	for _, slot := range slots {
+		if !before[keyOf(slot)] {
+			change.Appeared = append(change.Appeared, slot)
+		}
+	}
+	var reported []scraper.Slot
+	for _, slot := range f.state.Reported {
+		if now[keyOf(slot)] {
+			reported = append(reported, slot)
+		} else {
+			change.Gone = append(change.Gone, slot)
+		}
+	}
+
+	f.state.Open = append([]scraper.Slot(nil), slots...)
+	f.state.Reported = reported
+	return change, f.save()
+}
+
+// Reported records that the slots were notified, so they're reported gone
+// when they close
+func (f *Feed) Reported(slots []scraper.Slot) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	known := make(map[key]bool, len(f.state.Reported))
+	for _, slot := range f.state.Reported {
+		known[keyOf(slot)] = true
+	}
+	for _, slot := range slots {
+		if !known[keyOf(slot)] {
+			f.state.Reported = append(f.state.Reported, slot)
+			known[keyOf(slot)] = true
+		}
+	}
+	return f.save()
+}
+
+// Reset forgets the previous slots, so every open slot is new again
+func (f *Feed) Reset() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = state{}
+	return f.save()
+}
+
+// save writes the state. Callers hold mu.
+func (f *Feed) save() error {
+	data, err := json.MarshalIndent(f.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0750); err != nil {
+		return err
+	}
+	// Replace the file atomically so backups never read it half-written
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
@@ -0,0 +1,110 @@
+package clock
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// offset is how far behind the local clock is, applied by Now once a
+// Monitor finds it skewed
+var offset atomic.Int64
+
+// Now returns the current time, corrected by the skew a Monitor found.
+// Quiet hours, rules and depth windows use it, intervals don't need it.
+func Now() time.Time {
+	return time.Now().Add(time.Duration(offset.Load()))
+}
+
+// Alerter sends operational alerts, like notify.Alerter which can't be
+// imported here as notify tells the time with this package
+type Alerter interface {
+	Alert(text string) error
+}
+
+// Monitor compares the local clock with an NTP server, alerting when it's
+// off by more than maxSkew and correcting Now meanwhile. Cheap VPSes often
+// drift, and quiet hours and JST dates assume a correct clock.
+type Monitor struct {
+	server   string
+	interval time.Duration
+	maxSkew  time.Duration
+	alerter  Alerter
+
+	mu     sync.Mutex
+	skewed bool
+}
+
+// NewMonitor creates a monitor querying server, a host or host:port
+func NewMonitor(server string, interval, maxSkew time.Duration, alerter Alerter) *Monitor {
+	return &Monitor{server: server, interval: interval, maxSkew: maxSkew, alerter: alerter}
+}
+
+// Run checks the clock now and then every interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	m.Check(ctx)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check measures the skew, alerting when the clock becomes skewed or
+// correct again
+func (m *Monitor) Check(ctx context.Context) {
+	skew, err := Query(ctx, m.server)
+	if err != nil {
+		// Keep the last correction, an unreachable server says nothing of
+		// the clock
+		log.Printf("❌ Failed to query NTP server %s: %v", m.server, err)
+		return
+	}
+
+	skewed := skew.Abs() > m.maxSkew
+	m.mu.Lock()
+	wasSkewed := m.skewed
+	m.skewed = skewed
+	m.mu.Unlock()
+	if skewed {
+		offset.Store(int64(skew))
+	} else {
+		offset.Store(0)
+	}
+
+	switch {
+	case skewed && !wasSkewed:
+		m.alert(fmt.Sprintf("🕰️ Local clock is %s against %s, correcting quiet hours and dates until the system time is fixed (e.g. by enabling NTP)",
+			describe(skew), m.server))
+	case skewed:
+		log.Printf("🕰️ Local clock is %s against %s", describe(skew), m.server)
+	case wasSkewed:
+		m.alert(fmt.Sprintf("✓ Local clock is correct again, off by %s against %s", skew.Round(time.Millisecond), m.server))
+	}
+}
+
+func (m *Monitor) alert(text string) {
+	log.Print(text)
+	if m.alerter == nil {
+		return
+	}
+	if err := m.alerter.Alert(text); err != nil {
+		log.Printf("Error sending alert: %v", err)
+	}
+}
+
+// describe tells which way the clock is off, skew being what to add to it
+func describe(skew time.Duration) string {
+	if skew > 0 {
+		return skew.Round(time.Millisecond).String() + " behind"
+	}
+	return (-skew).Round(time.Millisecond).String() + " ahead"
+}
@@ -0,0 +1,87 @@
+package clock
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ntpEpochOffset is the seconds from the NTP epoch (1900) to the Unix one
+const ntpEpochOffset = 2208988800
+
+// ntpTimeout bounds a query when ctx has no deadline
+const ntpTimeout = 10 * time.Second
+
+// Query asks an NTP server, a host or host:port, for the time with SNTP
+// (RFC 4330) and returns the clock offset: what to add to the local time
+// to get the server's
+func Query(ctx context.Context, server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ntpTimeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // No leap indicator, version 4, client mode
+	sent := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTP(sent))
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	resp := make([]byte, 48)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return 0, err
+		}
+		received := time.Now()
+		// Ignore stray packets, a reply echoes our transmit time
+		if n < 48 || binary.BigEndian.Uint64(resp[24:]) != binary.BigEndian.Uint64(req[40:]) {
+			continue
+		}
+		if mode := resp[0] & 0x07; mode != 4 {
+			return 0, fmt.Errorf("unexpected NTP mode %d", mode)
+		}
+		if resp[0]>>6 == 3 {
+			return 0, errors.New("NTP server is not synchronized")
+		}
+		if resp[1] == 0 {
+			return 0, fmt.Errorf("NTP server refused the query (%q)", resp[12:16])
+		}
+		serverReceived := fromNTP(binary.BigEndian.Uint64(resp[32:]))
+		serverSent := fromNTP(binary.BigEndian.Uint64(resp[40:]))
+		return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+	}
+}
+
+// toNTP encodes t as an NTP timestamp: seconds since 1900 and a fraction
+func toNTP(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / 1e9
+	return seconds<<32 | fraction
+}
+
+// fromNTP decodes an NTP timestamp
+func fromNTP(ts uint64) time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	nanos := int64((ts & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(seconds, nanos)
+}
@@ -1,5 +1,21 @@
 package config
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 // Target configurations
 const (
 	// Real target
@@ -10,23 +26,833 @@ const (
 	TestLocation = "江東試験場"
 	TestCategory = "29の国･地域の方"
 
-	// Base URL for the reservation system
-	BaseURL = "http://www.keishicho-gto.metro.tokyo.lg.jp/keishicho-u/reserve/offerList_detail?tempSeq=445"
+	// Default path of the config file
+	DefaultConfigPath = "config.yaml"
+
+	// Default URL of the reservation system
+	DefaultBaseURL = "http://www.keishicho-gto.metro.tokyo.lg.jp/keishicho-u/reserve/offerList_detail?tempSeq=445"
+
+	// Default interval between scheduled checks
+	DefaultInterval = 15 * time.Minute
+
+	// Shortest interval between checks, of targets or sprints too, as a
+	// check takes several seconds anyway
+	MinInterval = 10 * time.Second
+
+	// Default path of the control API socket
+	DefaultSocketPath = "scraper.sock"
+
+	// Default number of pages to check (24 weeks)
+	DefaultMaxPages = 12
+
+	// Default delay before reading each page of the table
+	DefaultPageDelay = 500 * time.Millisecond
+
+	// Default browser locale, the site's Japanese layout is what we parse
+	DefaultLocale = "ja-JP"
+
+	// Default browser window size, WIDTHxHEIGHT
+	DefaultWindowSize = "1920x1080"
+
+	// Default service answering with our public IP. The egress check asking
+	// it is off unless its interval is set.
+	DefaultEgressIPURL = "https://api.ipify.org"
+
+	// Default NTP server and skew tolerated by the clock check, which is off
+	// unless its interval is set
+	DefaultNTPServer    = "pool.ntp.org"
+	DefaultClockMaxSkew = 30 * time.Second
+
+	// Default directory of persisted state
+	DefaultStateDir = "state"
+
+	// Default directory and interval of state backups, and how many to keep
+	DefaultBackupDir      = "backups"
+	DefaultBackupInterval = 24 * time.Hour
+	DefaultBackupKeep     = 14
+
+	// Default age of the recorded checks and webhook receipts kept
+	DefaultRetention = 180 * 24 * time.Hour
+
+	// Default lifetime of the leader lease of coordinated instances
+	DefaultLeaseTTL = time.Minute
+
+	// Default number of failed checks in a row before alerting
+	DefaultAlertThreshold = 5
+
+	// Default time checks may find no rows in the table before alerting
+	DefaultNoRowsAlert = 48 * time.Hour
+
+	// Default interval of digests of slots that aren't urgent
+	DefaultDigestInterval = time.Hour
+
+	// Default wait before notifying a slot that stays open again
+	DefaultNotifyCooldown = 2 * time.Hour
+
+	// Default number of slots in the history before adaptive polling picks
+	// hot hours
+	DefaultAdaptiveMin = 20
 )
 
-// Config holds the application configuration
+// Config holds the application configuration. The yaml tags name the keys
+// of the config file.
 type Config struct {
-	LineChannelToken string
-	LineUserID       string
-	IsTestMode       bool
-	NoNotify         bool
-	MaxPages         int // Maximum number of pages to check (24 weeks)
+	Profile          string            `yaml:"-"` // Profile of the config file in use, if any
+	LineChannelToken string            `yaml:"line_channel_token"`
+	LineUserID       string            `yaml:"line_user_id"`
+	LineSecret       string            `yaml:"line_channel_secret"`
+	IsTestMode       bool              `yaml:"test_mode"`
+	NoNotify         bool              `yaml:"no_notify"`
+	DesktopNotify    bool              `yaml:"desktop_notify"`  // Native notifications on the machine running the scraper
+	Targets          []Target          `yaml:"targets"`         // Locations and categories to watch, the real target if empty
+	BaseURL          string            `yaml:"base_url"`        // Reservation page listing the slots
+	Interval         time.Duration     `yaml:"interval"`        // Time between scheduled checks
+	AdaptiveHot      time.Duration     `yaml:"adaptive_hot"`    // Interval in the hours slots appear most, 0 disables adaptive polling
+	AdaptiveCold     time.Duration     `yaml:"adaptive_cold"`   // Interval in the other hours with adaptive polling, the interval if 0
+	AdaptiveMin      int               `yaml:"adaptive_min"`    // Slots in the history before adaptive polling picks hot hours
+	Maintenance      []string          `yaml:"maintenance"`     // Daily hours (JST) the site is down for maintenance, not checked, e.g. 02-04
+	MaxPages         int               `yaml:"max_pages"`       // Maximum number of pages to check (24 weeks)
+	PageDelay        time.Duration     `yaml:"page_delay"`      // Politeness delay before reading each page
+	SocketPath       string            `yaml:"socket"`          // Unix socket of the control API
+	StateDir         string            `yaml:"state_dir"`       // Directory of persisted state
+	StoreURL         string            `yaml:"store"`           // Store of checks: sqlite:<path> (default), postgres://..., memory: or none
+	BackupDir        string            `yaml:"backup_dir"`      // Directory of state backups
+	BackupInterval   time.Duration     `yaml:"backup_interval"` // Interval of state backups, 0 disables them
+	BackupKeep       int               `yaml:"backup_keep"`     // Number of state backups kept, 0 keeps all
+	Retention        time.Duration     `yaml:"retention"`       // Age of the recorded checks and webhook receipts kept, 0 keeps all
+	APIAddr          string            `yaml:"api_addr"`        // Optional TCP address of the control API
+	APIToken         string            `yaml:"api_token"`       // Token required by the control API over TCP
+	PublicAddr       string            `yaml:"public_addr"`     // Optional TCP address of the public status page
+	Pprof            bool              `yaml:"pprof"`           // Serve Go runtime profiles on the control API
+	LIFF             LIFFConfig        `yaml:"liff"`            // LINE mini-app
+	Slack            SlackConfig       `yaml:"slack"`           // Slack slash command
+	Discord          DiscordConfig     `yaml:"discord"`         // Discord slash command
+	Coord            CoordConfig       `yaml:"coord"`           // Coordination of several instances
+	Webhooks         []WebhookConfig   `yaml:"webhooks"`        // Generic webhook notifiers
+	Rules            []Rule            `yaml:"rules"`           // Which slots to notify about and when
+	DigestInterval   time.Duration     `yaml:"digest_interval"` // Interval of digests of rules with the digest action
+	NotifyCooldown   time.Duration     `yaml:"notify_cooldown"` // Wait before notifying a slot again, 0 notifies every check
+	NotifyOn         string            `yaml:"notify_on"`       // new: slots that appeared since the previous check, open: every open slot
+	NotifyGone       bool              `yaml:"notify_gone"`     // Follow up when notified slots are gone
+	QuietHours       string            `yaml:"quiet_hours"`     // Hours without notifications on any channel but for critical slots, e.g. 23-07
+	QuietTimeZone    string            `yaml:"quiet_time_zone"` // Time zone of the quiet hours, the local one if empty
+	SMTP             SMTPConfig        `yaml:"smtp"`            // Email notifications
+	Twilio           TwilioConfig      `yaml:"twilio"`          // SMS notifications
+	Matrix           MatrixConfig      `yaml:"matrix"`          // Matrix room notifications
+	Teams            TeamsConfig       `yaml:"teams"`           // Microsoft Teams notifications
+	GoogleChat       GoogleChatConfig  `yaml:"google_chat"`     // Google Chat notifications
+	MQTT             MQTTConfig        `yaml:"mqtt"`            // MQTT events for home automation
+	Line             Subscription      `yaml:"line"`            // Preferences of the LINE user
+	LineAltText      string            `yaml:"line_alt_text"`   // LINE push text: count, or short for watches
+	LocationNames    map[string]string `yaml:"location_names"`  // Extra or overriding romanized location names
+	EgressIPURL      string            `yaml:"egress_ip_url"`   // Service answering with our public IP
+	EgressInterval   time.Duration     `yaml:"egress_interval"` // Interval of the egress check, 0 disables it
+	NTPServer        string            `yaml:"ntp_server"`      // NTP server the local clock is compared with
+	ClockInterval    time.Duration     `yaml:"clock_interval"`  // Interval of the clock check, 0 disables it
+	ClockMaxSkew     time.Duration     `yaml:"clock_max_skew"`  // Skew over which to alert and correct the time
+	Proxy            string            `yaml:"proxy"`           // Proxy for browser traffic, e.g. socks5://127.0.0.1:1080
+	Locale           string            `yaml:"locale"`          // Browser locale and Accept-Language
+	BrowserMode      string            `yaml:"browser_mode"`    // warm: Chrome kept between checks, cold: only runs during them
+	SlotTimes        bool              `yaml:"slot_times"`      // Open available cells to read their time windows
+	WindowSize       string            `yaml:"window_size"`     // Browser window size, WIDTHxHEIGHT
+	Device           string            `yaml:"device"`          // Mobile device to emulate, e.g. "iPhone 13", empty for none
+	AlertThreshold   int               `yaml:"alert_threshold"` // Failed checks in a row before alerting, 0 disables
+	LogFormat        string            `yaml:"log_format"`      // emoji, plain or json
+	AlertWarnings    bool              `yaml:"alert_warnings"`  // Alert when check warnings appear or change
+	NoRowsAlert      time.Duration     `yaml:"no_rows_alert"`   // Alert with the table's text diff once checks find no rows for this long, 0 disables
+	AlertChannel     string            `yaml:"alert_channel"`   // Channel of operational alerts: line, email, sms, all or admin
+	AlertLineUserID  string            `yaml:"alert_line_user"` // LINE user or group of operational alerts, in place of the booking one
+	AlertEmails      []string          `yaml:"alert_emails"`    // Addresses of operational alert emails, in place of the recipients
+	SentryDSN        string            `yaml:"sentry_dsn"`      // Sentry or compatible project of panics and repeated check errors
+	SentryEnv        string            `yaml:"sentry_env"`      // Environment of the reported errors, the profile if empty
+}
+
+// SMTPConfig holds the email notification settings
+type SMTPConfig struct {
+	Host         string           `yaml:"host"`
+	Port         string           `yaml:"port"`
+	Username     string           `yaml:"username"`
+	Password     string           `yaml:"password"`
+	From         string           `yaml:"from"`
+	Recipients   []EmailRecipient `yaml:"recipients"`
+	FallbackOnly bool             `yaml:"fallback_only"` // Only email once the LINE monthly quota is used up
+}
+
+// TwilioConfig holds the SMS notification settings
+type TwilioConfig struct {
+	AccountSID   string   `yaml:"account_sid"`
+	AuthToken    string   `yaml:"auth_token"`
+	From         string   `yaml:"from"`          // Twilio number, e.g. +15005550006
+	To           []string `yaml:"to"`            // Numbers to text, empty disables SMS
+	FallbackOnly bool     `yaml:"fallback_only"` // Only text once the LINE monthly quota is used up
+
+	// CallAfter phones the numbers when found slots aren't acknowledged
+	// within this time, 0 disables calls
+	CallAfter time.Duration `yaml:"call_after"`
+}
+
+// MatrixConfig holds the Matrix notification settings
+type MatrixConfig struct {
+	Homeserver  string `yaml:"homeserver"`   // e.g. https://matrix.example.org
+	AccessToken string `yaml:"access_token"` // Of the account posting the messages
+	RoomID      string `yaml:"room_id"`      // e.g. !abc:example.org, empty disables Matrix
+}
+
+// TeamsConfig holds the Microsoft Teams notification settings
+type TeamsConfig struct {
+	WebhookURL string `yaml:"webhook_url"` // Incoming webhook of the channel, empty disables Teams
+}
+
+// GoogleChatConfig holds the Google Chat notification settings
+type GoogleChatConfig struct {
+	WebhookURL string `yaml:"webhook_url"` // Webhook of the space, empty disables Google Chat
+}
+
+// MQTTConfig holds the settings of MQTT events
+type MQTTConfig struct {
+	Broker   string `yaml:"broker"` // e.g. tcp://localhost:1883, empty disables MQTT
+	Topic    string `yaml:"topic"`  // Topic prefix, "scraper" by default
+	QoS      int    `yaml:"qos"`    // 0 or 1
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	ClientID string `yaml:"client_id"`
+
+	// HomeAssistant announces status sensors to Home Assistant's MQTT
+	// discovery under DiscoveryPrefix ("homeassistant" by default)
+	HomeAssistant   bool   `yaml:"home_assistant"`
+	DiscoveryPrefix string `yaml:"discovery_prefix"`
+}
+
+// LIFFConfig holds the settings of the LINE mini-app
+type LIFFConfig struct {
+	ID           string   `yaml:"id"`            // LIFF app ID, empty disables the mini-app
+	ChannelID    string   `yaml:"channel_id"`    // LINE Login channel owning the LIFF app
+	AllowedUsers []string `yaml:"allowed_users"` // LINE user IDs allowed to use it
+}
+
+// SlackConfig holds the settings of the Slack app's slash command
+type SlackConfig struct {
+	SigningSecret string   `yaml:"signing_secret"` // Of the Slack app, empty disables the command
+	AllowedUsers  []string `yaml:"allowed_users"`  // Slack user IDs allowed to use it
+}
+
+// DiscordConfig holds the settings of the Discord application's slash
+// command
+type DiscordConfig struct {
+	PublicKey    string   `yaml:"public_key"`    // Of the Discord application, hex, empty disables the command
+	AllowedUsers []string `yaml:"allowed_users"` // Discord user IDs allowed to use it
+}
+
+// CoordConfig holds the settings of instances sharing a database so only
+// one of them notifies
+type CoordConfig struct {
+	DatabaseURL string        `yaml:"database_url"` // Postgres URL, empty runs standalone
+	Instance    string        `yaml:"instance"`     // Name of this instance, the hostname by default
+	LeaseTTL    time.Duration `yaml:"lease_ttl"`    // How long the leader lease lasts without renewal
+	Standby     bool          `yaml:"standby"`      // Only check while no other instance leads
+}
+
+// WebhookConfig describes a generic webhook notifier. The bodies are Go
+// templates, see pkg/webhook.
+type WebhookConfig struct {
+	URL           string            `yaml:"url"`
+	Method        string            `yaml:"method"`
+	ContentType   string            `yaml:"content_type"`
+	Headers       map[string]string `yaml:"headers"`
+	Template      string            `yaml:"template"`
+	AlertTemplate string            `yaml:"alert_template"`
+	Retries       *int              `yaml:"retries"` // Default 3
+	Secret        string            `yaml:"secret"`  // Signs requests with HMAC-SHA256
+}
+
+// Rule routes the slots matching a condition, see pkg/rules
+type Rule struct {
+	Name     string `yaml:"name"`
+	When     string `yaml:"when"`      // Condition on each slot, empty matches every slot
+	MinSlots int    `yaml:"min_slots"` // Slots that must match in one check for the rule to apply
+	Action   string `yaml:"action"`    // notify (default), digest or drop
+}
+
+// EmailRecipient is an email address and its subscriber's preferences
+type EmailRecipient struct {
+	Address      string `yaml:"address"`
+	Subscription `yaml:",inline"`
+}
+
+// Subscription holds one subscriber's own notification preferences
+type Subscription struct {
+	Profile    string `yaml:"profile"`     // Notification profile, "full" or "sms"
+	Romanize   bool   `yaml:"romanize"`    // Romanized location names, for lang=en
+	Language   string `yaml:"lang"`        // Language of slot dates, ja or en
+	QuietHours string `yaml:"quiet_hours"` // Hours without notifications, e.g. 22-07
+	TimeZone   string `yaml:"time_zone"`   // Time zone of the quiet hours, e.g. Europe/Paris
+	Dates      string `yaml:"dates"`       // Only notify about these dates, e.g. 08/01-09/30
+}
+
+// EnvVars lists the environment variables read by Load
+var EnvVars = []string{
+	"LINE_CHANNEL_TOKEN", "LINE_USER_ID", "LINE_CHANNEL_SECRET",
+	"SCRAPER_CONFIG", "SCRAPER_PROFILE", "SCRAPER_TARGETS", "SCRAPER_BASE_URL", "SCRAPER_INTERVAL", "SCRAPER_MAX_PAGES",
+	"ADAPTIVE_HOT_INTERVAL", "ADAPTIVE_COLD_INTERVAL", "ADAPTIVE_MIN_SLOTS",
+	"SCRAPER_SOCKET", "SCRAPER_STATE_DIR", "SCRAPER_STORE", "SCRAPER_API_ADDR", "SCRAPER_API_TOKEN", "SCRAPER_PUBLIC_ADDR", "SCRAPER_PPROF",
+	"LIFF_ID", "LINE_LOGIN_CHANNEL_ID", "LIFF_ALLOWED_USERS",
+	"SLACK_SIGNING_SECRET", "SLACK_ALLOWED_USERS", "DISCORD_PUBLIC_KEY", "DISCORD_ALLOWED_USERS",
+	"WEBHOOK_URL", "WEBHOOK_CONTENT_TYPE", "WEBHOOK_HEADERS", "WEBHOOK_TEMPLATE", "WEBHOOK_ALERT_TEMPLATE", "WEBHOOK_SECRET",
+	"COORD_DATABASE_URL", "COORD_INSTANCE", "COORD_LEASE_TTL", "COORD_STANDBY",
+	"BACKUP_DIR", "BACKUP_INTERVAL", "BACKUP_KEEP", "HISTORY_RETENTION",
+	"SMTP_HOST", "SMTP_PORT", "SMTP_USERNAME", "SMTP_PASSWORD", "SMTP_FROM",
+	"EMAIL_RECIPIENTS", "EMAIL_FALLBACK_ONLY",
+	"TWILIO_ACCOUNT_SID", "TWILIO_AUTH_TOKEN", "TWILIO_FROM", "TWILIO_TO", "SMS_FALLBACK_ONLY", "TWILIO_CALL_AFTER",
+	"MATRIX_HOMESERVER", "MATRIX_ACCESS_TOKEN", "MATRIX_ROOM_ID", "TEAMS_WEBHOOK_URL", "GOOGLE_CHAT_WEBHOOK_URL",
+	"MQTT_BROKER", "MQTT_TOPIC", "MQTT_QOS", "MQTT_USERNAME", "MQTT_PASSWORD", "MQTT_CLIENT_ID",
+	"MQTT_HOME_ASSISTANT", "MQTT_DISCOVERY_PREFIX", "DESKTOP_NOTIFY",
+	"LINE_ROMANIZE", "LINE_OPTIONS", "LINE_ALT_TEXT", "LOCATION_NAMES", "NOTIFY_COOLDOWN", "NOTIFY_ON", "NOTIFY_GONE",
+	"QUIET_HOURS", "QUIET_TIME_ZONE", "SCRAPER_MAINTENANCE",
+	"SCRAPER_PAGE_DELAY", "EGRESS_CHECK_INTERVAL", "EGRESS_IP_URL",
+	"NTP_SERVER", "CLOCK_CHECK_INTERVAL", "CLOCK_MAX_SKEW",
+	"SCRAPER_PROXY", "SCRAPER_LOCALE", "SCRAPER_BROWSER_MODE", "SCRAPER_SLOT_TIMES", "SCRAPER_WINDOW_SIZE", "SCRAPER_DEVICE", "ALERT_ERROR_THRESHOLD", "ALERT_CHANNEL", "ALERT_LINE_USER_ID", "ALERT_EMAILS", "ALERT_WARNINGS", "ALERT_NO_ROWS", "LOG_FORMAT",
+	"SENTRY_DSN", "SENTRY_ENVIRONMENT",
+}
+
+// Environment returns the set configuration variables, for exporting
+func Environment() map[string]string {
+	env := make(map[string]string)
+	for _, key := range EnvVars {
+		if v, ok := os.LookupEnv(key); ok {
+			env[key] = v
+		}
+	}
+	return env
+}
+
+// ChangedKeys returns the keys of the config file whose values differ
+// between two configs, in order, e.g. to tell which changes of a reloaded
+// config apply
+func ChangedKeys(a, b Config) ([]string, error) {
+	var settings [2]map[string]interface{}
+	for i, c := range []Config{a, b} {
+		data, err := yaml.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &settings[i]); err != nil {
+			return nil, err
+		}
+	}
+	var keys []string
+	for key, v := range settings[0] {
+		if !reflect.DeepEqual(v, settings[1][key]) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range settings[1] {
+		if _, ok := settings[0][key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Default returns the built-in configuration
+func Default() Config {
+	return Config{
+		BaseURL:        DefaultBaseURL,
+		Interval:       DefaultInterval,
+		AdaptiveMin:    DefaultAdaptiveMin,
+		MaxPages:       DefaultMaxPages,
+		PageDelay:      DefaultPageDelay,
+		SocketPath:     DefaultSocketPath,
+		StateDir:       DefaultStateDir,
+		BackupDir:      DefaultBackupDir,
+		BackupInterval: DefaultBackupInterval,
+		BackupKeep:     DefaultBackupKeep,
+		Retention:      DefaultRetention,
+		SMTP:           SMTPConfig{Port: "587"},
+		EgressIPURL:    DefaultEgressIPURL,
+		NTPServer:      DefaultNTPServer,
+		ClockMaxSkew:   DefaultClockMaxSkew,
+		Locale:         DefaultLocale,
+		BrowserMode:    "warm",
+		WindowSize:     DefaultWindowSize,
+		AlertThreshold: DefaultAlertThreshold,
+		NoRowsAlert:    DefaultNoRowsAlert,
+		AlertChannel:   "all",
+		DigestInterval: DefaultDigestInterval,
+		NotifyCooldown: DefaultNotifyCooldown,
+		NotifyOn:       "new",
+		NotifyGone:     true,
+		LineAltText:    "count",
+		Coord:          CoordConfig{LeaseTTL: DefaultLeaseTTL},
+	}
+}
+
+// Load returns the configuration: the defaults, overridden by the config
+// file (SCRAPER_CONFIG, config.yaml by default) if it exists, then by the
+// profile of the file (SCRAPER_PROFILE, unless profile is set), then by
+// environment variables
+func Load(profile string) (Config, error) {
+	cfg := Default()
+	cfg.Profile = getEnv("SCRAPER_PROFILE", "")
+	if profile != "" {
+		cfg.Profile = profile
+	}
+	if err := loadFile(getEnv("SCRAPER_CONFIG", DefaultConfigPath), cfg.Profile, &cfg); err != nil {
+		return Config{}, err
+	}
+
+	var err error
+	if cfg.Interval, err = getEnvDuration("SCRAPER_INTERVAL", cfg.Interval); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxPages, err = getEnvInt("SCRAPER_MAX_PAGES", cfg.MaxPages); err != nil {
+		return Config{}, err
+	}
+	if cfg.AdaptiveHot, err = getEnvDuration("ADAPTIVE_HOT_INTERVAL", cfg.AdaptiveHot); err != nil {
+		return Config{}, err
+	}
+	if cfg.AdaptiveCold, err = getEnvDuration("ADAPTIVE_COLD_INTERVAL", cfg.AdaptiveCold); err != nil {
+		return Config{}, err
+	}
+	if cfg.AdaptiveMin, err = getEnvInt("ADAPTIVE_MIN_SLOTS", cfg.AdaptiveMin); err != nil {
+		return Config{}, err
+	}
+	if cfg.PageDelay, err = getEnvDuration("SCRAPER_PAGE_DELAY", cfg.PageDelay); err != nil {
+		return Config{}, err
+	}
+	if cfg.EgressInterval, err = getEnvDuration("EGRESS_CHECK_INTERVAL", cfg.EgressInterval); err != nil {
+		return Config{}, err
+	}
+	if cfg.ClockInterval, err = getEnvDuration("CLOCK_CHECK_INTERVAL", cfg.ClockInterval); err != nil {
+		return Config{}, err
+	}
+	if cfg.ClockMaxSkew, err = getEnvDuration("CLOCK_MAX_SKEW", cfg.ClockMaxSkew); err != nil {
+		return Config{}, err
+	}
+	if cfg.BackupInterval, err = getEnvDuration("BACKUP_INTERVAL", cfg.BackupInterval); err != nil {
+		return Config{}, err
+	}
+	if cfg.BackupKeep, err = getEnvInt("BACKUP_KEEP", cfg.BackupKeep); err != nil {
+		return Config{}, err
+	}
+	if cfg.Retention, err = getEnvDuration("HISTORY_RETENTION", cfg.Retention); err != nil {
+		return Config{}, err
+	}
+	if cfg.Coord.LeaseTTL, err = getEnvDuration("COORD_LEASE_TTL", cfg.Coord.LeaseTTL); err != nil {
+		return Config{}, err
+	}
+	if cfg.AlertThreshold, err = getEnvInt("ALERT_ERROR_THRESHOLD", cfg.AlertThreshold); err != nil {
+		return Config{}, err
+	}
+	if cfg.NotifyCooldown, err = getEnvDuration("NOTIFY_COOLDOWN", cfg.NotifyCooldown); err != nil {
+		return Config{}, err
+	}
+	if cfg.NoRowsAlert, err = getEnvDuration("ALERT_NO_ROWS", cfg.NoRowsAlert); err != nil {
+		return Config{}, err
+	}
+	if cfg.Twilio.CallAfter, err = getEnvDuration("TWILIO_CALL_AFTER", cfg.Twilio.CallAfter); err != nil {
+		return Config{}, err
+	}
+	if cfg.MQTT.QoS, err = getEnvInt("MQTT_QOS", cfg.MQTT.QoS); err != nil {
+		return Config{}, err
+	}
+
+	cfg.LineChannelToken = getEnv("LINE_CHANNEL_TOKEN", cfg.LineChannelToken)
+	cfg.LineUserID = getEnv("LINE_USER_ID", cfg.LineUserID)
+	cfg.LineSecret = getEnv("LINE_CHANNEL_SECRET", cfg.LineSecret)
+	cfg.BaseURL = getEnv("SCRAPER_BASE_URL", cfg.BaseURL)
+	cfg.SocketPath = getEnv("SCRAPER_SOCKET", cfg.SocketPath)
+	cfg.StateDir = getEnv("SCRAPER_STATE_DIR", cfg.StateDir)
+	cfg.StoreURL = getEnv("SCRAPER_STORE", cfg.StoreURL)
+	if cfg.StoreURL == "" {
+		// Next to the other state, wherever it was moved
+		cfg.StoreURL = "sqlite:" + filepath.Join(cfg.StateDir, "scraper.db")
+	}
+	cfg.BackupDir = getEnv("BACKUP_DIR", cfg.BackupDir)
+	cfg.APIAddr = getEnv("SCRAPER_API_ADDR", cfg.APIAddr)
+	cfg.APIToken = getEnv("SCRAPER_API_TOKEN", cfg.APIToken)
+	cfg.PublicAddr = getEnv("SCRAPER_PUBLIC_ADDR", cfg.PublicAddr)
+	cfg.Pprof = getEnvBool("SCRAPER_PPROF", cfg.Pprof)
+	cfg.LIFF.ID = getEnv("LIFF_ID", cfg.LIFF.ID)
+	cfg.LIFF.ChannelID = getEnv("LINE_LOGIN_CHANNEL_ID", cfg.LIFF.ChannelID)
+	cfg.Slack.SigningSecret = getEnv("SLACK_SIGNING_SECRET", cfg.Slack.SigningSecret)
+	cfg.Discord.PublicKey = getEnv("DISCORD_PUBLIC_KEY", cfg.Discord.PublicKey)
+	cfg.Coord.DatabaseURL = getEnv("COORD_DATABASE_URL", cfg.Coord.DatabaseURL)
+	cfg.Coord.Instance = getEnv("COORD_INSTANCE", cfg.Coord.Instance)
+	cfg.Coord.Standby = getEnvBool("COORD_STANDBY", cfg.Coord.Standby)
+	if cfg.Coord.Instance == "" {
+		cfg.Coord.Instance, _ = os.Hostname()
+	}
+	cfg.SMTP.Host = getEnv("SMTP_HOST", cfg.SMTP.Host)
+	cfg.SMTP.Port = getEnv("SMTP_PORT", cfg.SMTP.Port)
+	cfg.SMTP.Username = getEnv("SMTP_USERNAME", cfg.SMTP.Username)
+	cfg.SMTP.Password = getEnv("SMTP_PASSWORD", cfg.SMTP.Password)
+	cfg.SMTP.From = getEnv("SMTP_FROM", cfg.SMTP.From)
+	cfg.SMTP.FallbackOnly = getEnvBool("EMAIL_FALLBACK_ONLY", cfg.SMTP.FallbackOnly)
+	cfg.Twilio.AccountSID = getEnv("TWILIO_ACCOUNT_SID", cfg.Twilio.AccountSID)
+	cfg.Twilio.AuthToken = getEnv("TWILIO_AUTH_TOKEN", cfg.Twilio.AuthToken)
+	cfg.Twilio.From = getEnv("TWILIO_FROM", cfg.Twilio.From)
+	cfg.Twilio.FallbackOnly = getEnvBool("SMS_FALLBACK_ONLY", cfg.Twilio.FallbackOnly)
+	cfg.Matrix.Homeserver = getEnv("MATRIX_HOMESERVER", cfg.Matrix.Homeserver)
+	cfg.Matrix.AccessToken = getEnv("MATRIX_ACCESS_TOKEN", cfg.Matrix.AccessToken)
+	cfg.Matrix.RoomID = getEnv("MATRIX_ROOM_ID", cfg.Matrix.RoomID)
+	cfg.Teams.WebhookURL = getEnv("TEAMS_WEBHOOK_URL", cfg.Teams.WebhookURL)
+	cfg.GoogleChat.WebhookURL = getEnv("GOOGLE_CHAT_WEBHOOK_URL", cfg.GoogleChat.WebhookURL)
+	cfg.MQTT.Broker = getEnv("MQTT_BROKER", cfg.MQTT.Broker)
+	cfg.MQTT.Topic = getEnv("MQTT_TOPIC", cfg.MQTT.Topic)
+	cfg.MQTT.Username = getEnv("MQTT_USERNAME", cfg.MQTT.Username)
+	cfg.MQTT.Password = getEnv("MQTT_PASSWORD", cfg.MQTT.Password)
+	cfg.MQTT.ClientID = getEnv("MQTT_CLIENT_ID", cfg.MQTT.ClientID)
+	cfg.MQTT.HomeAssistant = getEnvBool("MQTT_HOME_ASSISTANT", cfg.MQTT.HomeAssistant)
+	cfg.MQTT.DiscoveryPrefix = getEnv("MQTT_DISCOVERY_PREFIX", cfg.MQTT.DiscoveryPrefix)
+	cfg.DesktopNotify = getEnvBool("DESKTOP_NOTIFY", cfg.DesktopNotify)
+	cfg.EgressIPURL = getEnv("EGRESS_IP_URL", cfg.EgressIPURL)
+	cfg.NTPServer = getEnv("NTP_SERVER", cfg.NTPServer)
+	cfg.Proxy = getEnv("SCRAPER_PROXY", cfg.Proxy)
+	cfg.Locale = getEnv("SCRAPER_LOCALE", cfg.Locale)
+	cfg.BrowserMode = getEnv("SCRAPER_BROWSER_MODE", cfg.BrowserMode)
+	cfg.SlotTimes = getEnvBool("SCRAPER_SLOT_TIMES", cfg.SlotTimes)
+	cfg.WindowSize = getEnv("SCRAPER_WINDOW_SIZE", cfg.WindowSize)
+	cfg.Device = getEnv("SCRAPER_DEVICE", cfg.Device)
+	cfg.NotifyOn = getEnv("NOTIFY_ON", cfg.NotifyOn)
+	cfg.NotifyGone = getEnvBool("NOTIFY_GONE", cfg.NotifyGone)
+	cfg.QuietHours = getEnv("QUIET_HOURS", cfg.QuietHours)
+	cfg.QuietTimeZone = getEnv("QUIET_TIME_ZONE", cfg.QuietTimeZone)
+	if v := os.Getenv("SCRAPER_MAINTENANCE"); v != "" {
+		cfg.Maintenance = parseList(v)
+	}
+	cfg.AlertChannel = getEnv("ALERT_CHANNEL", cfg.AlertChannel)
+	cfg.AlertLineUserID = getEnv("ALERT_LINE_USER_ID", cfg.AlertLineUserID)
+	if v := os.Getenv("ALERT_EMAILS"); v != "" {
+		cfg.AlertEmails = parseList(v)
+	}
+	cfg.LogFormat = getEnv("LOG_FORMAT", cfg.LogFormat)
+	cfg.SentryDSN = getEnv("SENTRY_DSN", cfg.SentryDSN)
+	cfg.SentryEnv = getEnv("SENTRY_ENVIRONMENT", cfg.SentryEnv)
+	cfg.AlertWarnings = getEnvBool("ALERT_WARNINGS", cfg.AlertWarnings)
+
+	if v := os.Getenv("SCRAPER_TARGETS"); v != "" {
+		if cfg.Targets, err = ParseTargets(v); err != nil {
+			return Config{}, fmt.Errorf("invalid SCRAPER_TARGETS: %v", err)
+		}
+	}
+	if v := os.Getenv("LIFF_ALLOWED_USERS"); v != "" {
+		cfg.LIFF.AllowedUsers = parseList(v)
+	}
+	if v := os.Getenv("SLACK_ALLOWED_USERS"); v != "" {
+		cfg.Slack.AllowedUsers = parseList(v)
+	}
+	if v := os.Getenv("DISCORD_ALLOWED_USERS"); v != "" {
+		cfg.Discord.AllowedUsers = parseList(v)
+	}
+	if v := os.Getenv("EMAIL_RECIPIENTS"); v != "" {
+		cfg.SMTP.Recipients = parseRecipients(v)
+	}
+	if v := os.Getenv("TWILIO_TO"); v != "" {
+		cfg.Twilio.To = parseList(v)
+	}
+	if v := os.Getenv("LINE_OPTIONS"); v != "" {
+		cfg.Line = parseSubscription(cfg.Line.Romanize, strings.Split(v, ":"))
+	}
+	cfg.Line.Romanize = getEnvBool("LINE_ROMANIZE", cfg.Line.Romanize)
+	cfg.LineAltText = getEnv("LINE_ALT_TEXT", cfg.LineAltText)
+	if v := os.Getenv("WEBHOOK_URL"); v != "" {
+		cfg.Webhooks = append(cfg.Webhooks, WebhookConfig{
+			URL:           v,
+			ContentType:   os.Getenv("WEBHOOK_CONTENT_TYPE"),
+			Headers:       parsePairs(os.Getenv("WEBHOOK_HEADERS")),
+			Template:      os.Getenv("WEBHOOK_TEMPLATE"),
+			AlertTemplate: os.Getenv("WEBHOOK_ALERT_TEMPLATE"),
+			Secret:        os.Getenv("WEBHOOK_SECRET"),
+		})
+	}
+	if v := os.Getenv("LOCATION_NAMES"); v != "" {
+		cfg.LocationNames = parsePairs(v)
+	}
+
+	if cfg.Interval < MinInterval {
+		return Config{}, fmt.Errorf("invalid interval %s: must be at least %s", cfg.Interval, MinInterval)
+	}
+	if cfg.AdaptiveHot != 0 && cfg.AdaptiveHot < MinInterval {
+		return Config{}, fmt.Errorf("invalid adaptive hot interval %s: must be at least %s", cfg.AdaptiveHot, MinInterval)
+	}
+	if cfg.AdaptiveCold != 0 && cfg.AdaptiveCold < MinInterval {
+		return Config{}, fmt.Errorf("invalid adaptive cold interval %s: must be at least %s", cfg.AdaptiveCold, MinInterval)
+	}
+	switch cfg.LineAltText {
+	case "count", "short":
+	default:
+		return Config{}, fmt.Errorf("invalid LINE alt text %q: expected count or short", cfg.LineAltText)
+	}
+	switch cfg.NotifyOn {
+	case "new", "open":
+	default:
+		return Config{}, fmt.Errorf("invalid notify_on %q: expected new or open", cfg.NotifyOn)
+	}
+	if _, err := ParseWindows(cfg.Maintenance); err != nil {
+		return Config{}, fmt.Errorf("invalid maintenance: %v", err)
+	}
+	if cfg.QuietHours != "" {
+		if _, err := ParseHours(cfg.QuietHours); err != nil {
+			return Config{}, fmt.Errorf("invalid quiet hours: %v", err)
+		}
+	}
+	if cfg.QuietTimeZone != "" {
+		if _, err := time.LoadLocation(cfg.QuietTimeZone); err != nil {
+			return Config{}, fmt.Errorf("invalid quiet time zone %q", cfg.QuietTimeZone)
+		}
+	}
+	if cfg.NotifyCooldown < 0 {
+		return Config{}, fmt.Errorf("invalid notify cooldown %s: must not be negative", cfg.NotifyCooldown)
+	}
+	if len(cfg.Rules) > 0 && cfg.DigestInterval <= 0 {
+		return Config{}, fmt.Errorf("invalid digest interval %s: must be positive", cfg.DigestInterval)
+	}
+	if cfg.Coord.DatabaseURL != "" && cfg.Coord.LeaseTTL < 3*time.Second {
+		return Config{}, fmt.Errorf("invalid lease TTL %s: must be at least 3s", cfg.Coord.LeaseTTL)
+	}
+	if cfg.ClockInterval > 0 && cfg.ClockMaxSkew <= 0 {
+		return Config{}, fmt.Errorf("invalid clock max skew %s: must be positive", cfg.ClockMaxSkew)
+	}
+	if cfg.MaxPages <= 0 {
+		return Config{}, fmt.Errorf("invalid max pages %d: must be positive", cfg.MaxPages)
+	}
+	if err := ValidateTargets(cfg.Targets); err != nil {
+		return Config{}, err
+	}
+	switch cfg.BrowserMode {
+	case "cold", "warm":
+	default:
+		return Config{}, fmt.Errorf("invalid browser mode %q: expected cold or warm", cfg.BrowserMode)
+	}
+	if _, _, err := ParseWindowSize(cfg.WindowSize); err != nil {
+		return Config{}, err
+	}
+	switch cfg.AlertChannel {
+	case "line", "email", "sms", "all":
+	case "admin":
+		if cfg.AlertLineUserID == "" && len(cfg.AlertEmails) == 0 {
+			return Config{}, fmt.Errorf("alert channel admin needs ALERT_LINE_USER_ID or ALERT_EMAILS")
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid alert channel %q: expected line, email, sms, all or admin", cfg.AlertChannel)
+	}
+	if len(cfg.AlertEmails) > 0 && (cfg.SMTP.Host == "" || cfg.SMTP.From == "") {
+		return Config{}, fmt.Errorf("ALERT_EMAILS needs SMTP_HOST and SMTP_FROM")
+	}
+	return cfg, nil
+}
+
+// loadFile reads the YAML config file at path into cfg, if it exists
+// file is the layout of the config file: the configuration, and named
+// profiles overriding parts of it
+type file struct {
+	Config   `yaml:",inline"`
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// loadFile applies the config file at path to cfg, then the profile, if
+// any. A missing file is fine unless a profile is selected.
+func loadFile(path, profile string, cfg *Config) error {
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from the operator
+	if errors.Is(err, os.ErrNotExist) && profile == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	f := file{Config: *cfg}
+	if err := decodeStrict(data, &f); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	*cfg = f.Config
+	if profile == "" {
+		return nil
+	}
+
+	node, ok := f.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("unknown profile %q in %s", profile, path)
+	}
+	// Decode the profile from YAML again, as nodes can't reject unknown
+	// fields
+	if data, err = yaml.Marshal(&node); err != nil {
+		return err
+	}
+	if err := decodeStrict(data, cfg); err != nil {
+		return fmt.Errorf("invalid profile %q in %s: %v", profile, path, err)
+	}
+	return nil
+}
+
+// decodeStrict decodes YAML, rejecting unknown fields
+func decodeStrict(data []byte, v interface{}) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// getEnvBool reads "true" or "false" from the environment
+func getEnvBool(key string, fallback bool) bool {
+	switch os.Getenv(key) {
+	case "true":
+		return true
+	case "false":
+		return false
+	default:
+		return fallback
+	}
+}
+
+// getEnvDuration parses a duration such as "2s" from the environment
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a duration like 500ms or 2s", key, v)
+	}
+	return d, nil
+}
+
+// getEnvInt parses a non-negative integer from the environment
+func getEnvInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a non-negative integer", key, v)
+	}
+	return n, nil
+}
+
+// parseRecipients parses a comma-separated list of "address[:option...]"
+func parseRecipients(s string) []EmailRecipient {
+	var recipients []EmailRecipient
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		recipients = append(recipients, EmailRecipient{
+			Address:      parts[0],
+			Subscription: parseSubscription(false, parts[1:]),
+		})
+	}
+	return recipients
+}
+
+// parseSubscription parses subscriber options: a profile name, "romaji",
+// lang=ja|en, quiet=HH-HH, tz=<zone> and dates=MM/DD-MM/DD
+func parseSubscription(romanize bool, opts []string) Subscription {
+	sub := Subscription{Romanize: romanize}
+	for _, opt := range opts {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "":
+		case "romaji":
+			sub.Romanize = true
+		case "lang":
+			sub.Romanize = value == "en"
+			sub.Language = value
+		case "quiet":
+			sub.QuietHours = value
+		case "tz":
+			sub.TimeZone = value
+		case "dates":
+			sub.Dates = value
+		default:
+			sub.Profile = key
+		}
+	}
+	return sub
+}
+
+// ParseTargets parses a comma-separated list of
+// "[!]location[=category][@interval]". A target without a category matches
+// every category of its location, a leading ! marks it critical and an
+// interval, e.g. @2m, checks it on its own interval.
+func ParseTargets(s string) ([]Target, error) {
+	var targets []Target
+	for _, entry := range strings.Split(s, ",") {
+		var interval time.Duration
+		if rest, every, ok := strings.Cut(entry, "@"); ok {
+			d, err := time.ParseDuration(strings.TrimSpace(every))
+			if err != nil {
+				return nil, fmt.Errorf("invalid interval of target %s: %v", strings.TrimSpace(rest), err)
+			}
+			entry, interval = rest, d
+		}
+		location, category, _ := strings.Cut(entry, "=")
+		location, critical := strings.CutPrefix(strings.TrimSpace(location), "!")
+		location = strings.TrimSpace(location)
+		if location == "" {
+			continue
+		}
+		targets = append(targets, Target{Location: location, Category: strings.TrimSpace(category), Critical: critical, Interval: interval})
+	}
+	return targets, nil
+}
+
+// ParseWindowSize parses a browser window size, "WIDTHxHEIGHT"
+func ParseWindowSize(s string) (int, int, error) {
+	w, h, ok := strings.Cut(s, "x")
+	width, werr := strconv.Atoi(w)
+	height, herr := strconv.Atoi(h)
+	if !ok || werr != nil || herr != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid window size %q: expected WIDTHxHEIGHT, e.g. 1920x1080", s)
+	}
+	return width, height, nil
+}
+
+// parseList parses a comma-separated list
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePairs parses a comma-separated list of "key=value"
+func parsePairs(s string) map[string]string {
+	pairs := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return pairs
+}
+
+// getEnv returns the environment variable or fallback if it is unset
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
 }
 
 // Target represents a location and category to check
 type Target struct {
-	Location string
-	Category string
+	Location string        `yaml:"location" json:"location"`
+	Category string        `yaml:"category" json:"category"`           // Empty for every category of the location, /regexp/ for those matching
+	Depth    []DepthWindow `yaml:"depth" json:"depth,omitempty"`       // Pages to check by time of day
+	Critical bool          `yaml:"critical" json:"critical,omitempty"` // Notify even in quiet hours, cooldowns and digests
+	Interval time.Duration `yaml:"interval" json:"interval,omitempty"` // How often to check it, the configured interval if zero
 }
 
 // GetTarget returns the appropriate target based on test mode
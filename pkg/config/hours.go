@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JST is the time zone of the reservation site. Japan has no daylight
+// saving time, so a fixed zone avoids depending on tzdata.
+var JST = time.FixedZone("JST", 9*60*60)
+
+// Hours is a daily window of whole hours. It wraps midnight when Start is
+// after End, and is empty when both are equal.
+type Hours struct {
+	Start, End int // Hours of the day, 0-23
+}
+
+// ParseHours parses a window like "22-07"
+func ParseHours(s string) (Hours, error) {
+	start, end, ok := strings.Cut(s, "-")
+	var h Hours
+	var err1, err2 error
+	h.Start, err1 = strconv.Atoi(strings.TrimSpace(start))
+	h.End, err2 = strconv.Atoi(strings.TrimSpace(end))
+	if !ok || err1 != nil || err2 != nil || h.Start < 0 || h.Start > 23 || h.End < 0 || h.End > 23 {
+		return Hours{}, fmt.Errorf("invalid hours %q: expected HH-HH, e.g. 22-07", s)
+	}
+	return h, nil
+}
+
+// Contains reports whether the hour of the day is within the window
+func (h Hours) Contains(hour int) bool {
+	if h.Start == h.End {
+		return false
+	}
+	if h.Start < h.End {
+		return hour >= h.Start && hour < h.End
+	}
+	return hour >= h.Start || hour < h.End
+}
+
+// DepthWindow sets how many pages to check during some hours (JST)
+type DepthWindow struct {
+	Hours    string `yaml:"hours" json:"hours"` // e.g. 09-11
+	MaxPages int    `yaml:"max_pages" json:"max_pages"`
+}
+
+// MaxPagesAt returns how many pages to check for the target at t: the
+// first depth window containing t, or fallback
+func (t Target) MaxPagesAt(now time.Time, fallback int) int {
+	hour := now.In(JST).Hour()
+	for _, w := range t.Depth {
+		if h, err := ParseHours(w.Hours); err == nil && h.Contains(hour) {
+			return w.MaxPages
+		}
+	}
+	return fallback
+}
+
+// validateDepth checks the depth windows of every target
+func validateDepth(targets []Target) error {
+	for _, t := range targets {
+		for _, w := range t.Depth {
+			if _, err := ParseHours(w.Hours); err != nil {
+				return fmt.Errorf("target %s: %v", t.Location, err)
+			}
+			if w.MaxPages <= 0 {
+				return fmt.Errorf("target %s: max_pages of %s must be positive", t.Location, w.Hours)
+			}
+		}
+	}
+	return nil
+}
+
+// Windows are daily windows (JST), e.g. the site's nightly maintenance
+type Windows []Hours
+
+// ParseWindows parses windows like "02-04". They can't cover the whole day.
+func ParseWindows(list []string) (Windows, error) {
+	var w Windows
+	for _, s := range list {
+		h, err := ParseHours(s)
+		if err != nil {
+			return nil, err
+		}
+		w = append(w, h)
+	}
+	for hour := 0; hour < 24; hour++ {
+		if !w.containsHour(hour) {
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid windows %s: they cover the whole day", strings.Join(list, ", "))
+}
+
+// containsHour reports whether one of the windows contains the hour of the
+// day
+func (w Windows) containsHour(hour int) bool {
+	for _, h := range w {
+		if h.Contains(hour) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports whether t is within one of the windows
+func (w Windows) Contains(t time.Time) bool {
+	return w.containsHour(t.In(JST).Hour())
+}
+
+// End returns the end of the windows containing t, following windows that
+// run into each other, or t if none does
+func (w Windows) End(t time.Time) time.Time {
+	// Hours start at the same time in Japan and UTC
+	for w.Contains(t) {
+		t = t.Truncate(time.Hour).Add(time.Hour)
+	}
+	return t
+}
+
+// Sub returns the time d before t, not counting the time within the
+// windows, e.g. for how recent a check should be when none runs during them
+func (w Windows) Sub(t time.Time, d time.Duration) time.Time {
+	if len(w) == 0 {
+		return t.Add(-d)
+	}
+	for {
+		start := t.Truncate(time.Hour)
+		if start.Equal(t) {
+			start = t.Add(-time.Hour)
+		}
+		switch {
+		case w.Contains(start):
+		case t.Sub(start) >= d:
+			return t.Add(-d)
+		default:
+			d -= t.Sub(start)
+		}
+		t = start
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in Redacted configs
+const redacted = "[redacted]"
+
+// Redacted returns a copy of the config with credentials and tokens
+// replaced, safe to log or show. Webhook URLs keep their host, as their
+// paths or queries often hold the token.
+func (c Config) Redacted() Config {
+	c.LineChannelToken = redactString(c.LineChannelToken)
+	c.LineSecret = redactString(c.LineSecret)
+	c.APIToken = redactString(c.APIToken)
+	c.Slack.SigningSecret = redactString(c.Slack.SigningSecret)
+	c.SMTP.Password = redactString(c.SMTP.Password)
+	c.Twilio.AuthToken = redactString(c.Twilio.AuthToken)
+	c.Matrix.AccessToken = redactString(c.Matrix.AccessToken)
+	c.MQTT.Password = redactString(c.MQTT.Password)
+	c.Teams.WebhookURL = redactPath(c.Teams.WebhookURL)
+	c.GoogleChat.WebhookURL = redactPath(c.GoogleChat.WebhookURL)
+	c.Coord.DatabaseURL = redactURL(c.Coord.DatabaseURL)
+	c.StoreURL = redactURL(c.StoreURL)
+	c.Proxy = redactURL(c.Proxy)
+	c.SentryDSN = redactPath(c.SentryDSN) // The key is the user of the URL
+
+	webhooks := make([]WebhookConfig, len(c.Webhooks))
+	for i, wc := range c.Webhooks {
+		wc.URL = redactURL(wc.URL)
+		wc.Secret = redactString(wc.Secret)
+		if wc.Headers != nil {
+			// Headers are where webhooks put their API keys
+			headers := make(map[string]string, len(wc.Headers))
+			for k, v := range wc.Headers {
+				headers[k] = redactString(v)
+			}
+			wc.Headers = headers
+		}
+		webhooks[i] = wc
+	}
+	c.Webhooks = webhooks
+	return c
+}
+
+// RedactText replaces the config's secrets wherever they appear in text,
+// e.g. a log file, as Redacted would show them
+func (c Config) RedactText(text string) string {
+	r := c.Redacted()
+	var secrets, replacements []string
+	add := func(secret, replacement string) {
+		if secret != "" && secret != replacement {
+			secrets = append(secrets, secret)
+			replacements = append(replacements, replacement)
+		}
+	}
+	add(c.LineChannelToken, r.LineChannelToken)
+	add(c.LineSecret, r.LineSecret)
+	add(c.APIToken, r.APIToken)
+	add(c.Slack.SigningSecret, r.Slack.SigningSecret)
+	add(c.SMTP.Password, r.SMTP.Password)
+	add(c.Twilio.AuthToken, r.Twilio.AuthToken)
+	add(c.Matrix.AccessToken, r.Matrix.AccessToken)
+	add(c.MQTT.Password, r.MQTT.Password)
+	add(c.Teams.WebhookURL, r.Teams.WebhookURL)
+	add(c.GoogleChat.WebhookURL, r.GoogleChat.WebhookURL)
+	add(c.Coord.DatabaseURL, r.Coord.DatabaseURL)
+	add(c.StoreURL, r.StoreURL)
+	add(c.Proxy, r.Proxy)
+	add(c.SentryDSN, r.SentryDSN)
+	for i, wc := range c.Webhooks {
+		add(wc.URL, r.Webhooks[i].URL)
+		add(wc.Secret, r.Webhooks[i].Secret)
+		for k, v := range wc.Headers {
+			add(v, r.Webhooks[i].Headers[k])
+		}
+	}
+
+	// Longest first, so a secret containing another is replaced whole
+	order := make([]int, len(secrets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return len(secrets[order[i]]) > len(secrets[order[j]]) })
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, i := range order {
+		pairs = append(pairs, secrets[i], replacements[i])
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+func redactString(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURL redacts the password and query values of a URL, or all of it
+// if it isn't one, e.g. a key=value database connection string
+func redactURL(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "redacted")
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			q.Set(k, "redacted")
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// redactPath keeps only the scheme and host of a URL
+func redactPath(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return redacted
+	}
+	return u.Scheme + "://" + u.Host + "/" + redacted
+}
+
+// RedactedYAML renders the Redacted config as the config file would set it
+func (c Config) RedactedYAML() ([]byte, error) {
+	return yaml.Marshal(c.Redacted())
+}
+
+// Settings returns the Redacted config keyed like the config file
+func (c Config) Settings() (map[string]interface{}, error) {
+	data, err := c.RedactedYAML()
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// patterns caches the compiled category patterns of template targets
+var patterns sync.Map // string -> *regexp.Regexp
+
+// IsTemplate tells whether the target's category is a pattern, "/regexp/",
+// covering every category of the location it matches, e.g. /29の国/, rather
+// than the exact name of one category
+func (t Target) IsTemplate() bool {
+	return len(t.Category) >= 2 && strings.HasPrefix(t.Category, "/") && strings.HasSuffix(t.Category, "/")
+}
+
+// ID identifies the target by its location and category, e.g. in URLs. It
+// stays the same across restarts and changes of the other targets.
+func (t Target) ID() string {
+	sum := sha256.Sum256([]byte(t.Location + "\x00" + t.Category))
+	return hex.EncodeToString(sum[:4])
+}
+
+// categoryPattern compiles the category pattern of a template target
+func (t Target) categoryPattern() (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(t.Category); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(t.Category[1 : len(t.Category)-1])
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(t.Category, re)
+	return re, nil
+}
+
+// Matches tells whether a row of the table, a location and one of its
+// categories, is one of the target's
+func (t Target) Matches(location, category string) bool {
+	if location != t.Location {
+		return false
+	}
+	if t.Category == "" {
+		return true
+	}
+	if t.IsTemplate() {
+		re, err := t.categoryPattern()
+		return err == nil && re.MatchString(category)
+	}
+	return category == t.Category
+}
+
+// MarshalJSON writes the interval as a duration, e.g. "15m0s", rather than
+// nanoseconds
+func (t Target) MarshalJSON() ([]byte, error) {
+	type target Target
+	v := struct {
+		target
+		Interval string `json:"interval,omitempty"`
+	}{target: target(t)}
+	if t.Interval != 0 {
+		v.Interval = t.Interval.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON reads the interval as a duration, and also as nanoseconds,
+// so targets saved before can still be read
+func (t *Target) UnmarshalJSON(data []byte) error {
+	type target Target
+	v := struct {
+		*target
+		Interval json.RawMessage `json:"interval"`
+	}{target: (*target)(t)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	t.Interval = 0
+	if len(v.Interval) == 0 || string(v.Interval) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(v.Interval, &s); err != nil {
+		var ns int64
+		if err := json.Unmarshal(v.Interval, &ns); err != nil {
+			return fmt.Errorf("invalid interval %s: %v", v.Interval, err)
+		}
+		t.Interval = time.Duration(ns)
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid interval %q: %v", s, err)
+	}
+	t.Interval = d
+	return nil
+}
+
+// ValidateTargets checks the targets' intervals, category patterns and
+// depth windows
+func ValidateTargets(targets []Target) error {
+	for _, t := range targets {
+		if t.Location == "" {
+			return fmt.Errorf("target without a location")
+		}
+		if t.Interval != 0 && t.Interval < MinInterval {
+			return fmt.Errorf("target %s: interval %s must be at least %s", t.Location, t.Interval, MinInterval)
+		}
+		if t.IsTemplate() {
+			if _, err := t.categoryPattern(); err != nil {
+				return fmt.Errorf("target %s: invalid category pattern %s: %v", t.Location, t.Category, err)
+			}
+		}
+	}
+	return validateDepth(targets)
+}
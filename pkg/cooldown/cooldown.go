@@ -0,0 +1,126 @@
+package cooldown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Entry is a slot that was notified
+type Entry struct {
+	Location   string    `json:"location"`
+	Category   string    `json:"category"`
+	Date       string    `json:"date"`
+	NotifiedAt time.Time `json:"notified_at"`
+}
+
+// key identifies a slot, whatever its time windows
+type key struct {
+	location, category, date string
+}
+
+// List remembers when slots were last notified, so a slot that stays open
+// isn't notified again every check until its cooldown is over. It's
+// persisted to a JSON file so restarts don't notify everything again.
+type List struct {
+	path     string
+	cooldown time.Duration
+
+	mu   sync.Mutex
+	last map[key]time.Time
+}
+
+// Load reads the notified slots from path, starting empty if it doesn't exist
+func Load(path string, cooldown time.Duration) (*List, error) {
+	l := &List{path: path, cooldown: cooldown, last: make(map[key]time.Time)}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	for _, e := range entries {
+		l.last[key{e.Location, e.Category, e.Date}] = e.NotifiedAt
+	}
+	return l, nil
+}
+
+// Filter returns the slots not notified within the cooldown, and critical
+// ones whatever their cooldown
+func (l *List) Filter(slots []scraper.Slot) []scraper.Slot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var kept []scraper.Slot
+	for _, slot := range slots {
+		if at, ok := l.last[key{slot.Location, slot.Category, slot.Date}]; ok && now.Sub(at) < l.cooldown && !slot.Critical {
+			continue
+		}
+		kept = append(kept, slot)
+	}
+	return kept
+}
+
+// Record marks the slots as notified now
+func (l *List) Record(slots []scraper.Slot) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for _, slot := range slots {
+		l.last[key{slot.Location, slot.Category, slot.Date}] = now
+	}
+	return l.save()
+}
+
+// Reset forgets every notified slot, so open slots are notified again
+func (l *List) Reset() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.last = make(map[key]time.Time)
+	return l.save()
+}
+
+// save writes the slots still cooling down, dropping the others. Callers
+// hold mu.
+func (l *List) save() error {
+	now := time.Now()
+	entries := []Entry{}
+	for k, at := range l.last {
+		if now.Sub(at) >= l.cooldown {
+			delete(l.last, k)
+			continue
+		}
+		entries = append(entries, Entry{Location: k.location, Category: k.category, Date: k.date, NotifiedAt: at})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].NotifiedAt.Before(entries[j].NotifiedAt) })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0750); err != nil {
+		return err
+	}
+	// Replace the file atomically so backups never read it half-written
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
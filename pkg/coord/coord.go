@@ -0,0 +1,160 @@
+package coord
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/scraper"
+
+	// Postgres driver for database/sql
+	_ "github.com/lib/pq"
+)
+
+// leaseName identifies the notification lease in the leases table
+const leaseName = "notifier"
+
+// Elector elects one of several scraper instances sharing a Postgres
+// database as the notifying leader, through a lease row that the leader
+// renews and others take over once it expires. Every instance keeps
+// checking, so a standby is ready the moment the leader goes away.
+type Elector struct {
+	db       *sql.DB
+	instance string
+	ttl      time.Duration
+
+	mu          sync.Mutex
+	leaderUntil time.Time // Local deadline of our lease, zero if not leader
+	otherUntil  time.Time // Latest end of another instance's lease seen
+}
+
+// NewElector connects to the database and creates the leases table if needed
+func NewElector(ctx context.Context, databaseURL, instance string, ttl time.Duration) (*Elector, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS scraper_leases (
+		name TEXT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create leases table: %v", err)
+	}
+	return &Elector{db: db, instance: instance, ttl: ttl}, nil
+}
+
+// Run tries to take or renew the lease every third of its TTL until ctx is
+// cancelled, then releases it
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		e.renew(ctx)
+		select {
+		case <-ctx.Done():
+			e.release()
+			e.db.Close()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsLeader reports whether this instance holds the lease. A lease that
+// couldn't be renewed in time counts as lost, even before the database
+// hands it to another instance.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Now().Before(e.leaderUntil)
+}
+
+// Standby reports whether another instance holds the lease. Unlike
+// !IsLeader, it is false when the database can't be reached, so an instance
+// cut off from the others keeps checking.
+func (e *Elector) Standby() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Now().Before(e.otherUntil)
+}
+
+func (e *Elector) renew(ctx context.Context) {
+	// Measure the lease from before the query so our deadline never
+	// outlasts the one stored in the database
+	start := time.Now()
+	var holder string
+	err := e.db.QueryRowContext(ctx, `
+		INSERT INTO scraper_leases (name, holder, expires_at)
+		VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE
+		SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE scraper_leases.holder = EXCLUDED.holder OR scraper_leases.expires_at < now()
+		RETURNING holder`,
+		leaseName, e.instance, e.ttl.Milliseconds()).Scan(&holder)
+
+	wasLeader := e.IsLeader()
+	leader := err == nil && holder == e.instance
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("❌ Failed to renew leader lease: %v", err)
+	}
+
+	e.mu.Lock()
+	if leader {
+		e.leaderUntil = start.Add(e.ttl)
+	} else if err == sql.ErrNoRows {
+		// Another instance holds the lease, for at most a TTL from now
+		e.leaderUntil = time.Time{}
+		e.otherUntil = time.Now().Add(e.ttl)
+	}
+	e.mu.Unlock()
+
+	if leader && !wasLeader {
+		log.Printf("👑 %s is now the notifying instance", e.instance)
+	} else if !leader && wasLeader && err == sql.ErrNoRows {
+		log.Printf("💤 %s lost the lead, standing by", e.instance)
+	}
+}
+
+func (e *Elector) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := e.db.ExecContext(ctx, `DELETE FROM scraper_leases WHERE name = $1 AND holder = $2`, leaseName, e.instance); err != nil {
+		log.Printf("❌ Failed to release leader lease: %v", err)
+	}
+	e.mu.Lock()
+	e.leaderUntil = time.Time{}
+	e.mu.Unlock()
+}
+
+// LeaderOnly wraps a notifier so only the leader instance notifies
+type LeaderOnly struct {
+	Notifier notify.Notifier
+	Elector  *Elector
+}
+
+// NotifyAvailableSlots forwards the slots if this instance is the leader
+func (l LeaderOnly) NotifyAvailableSlots(slots []scraper.Slot) error {
+	if !l.Elector.IsLeader() {
+		log.Printf("💤 Standby instance, leaving %d slot(s) to the leader", len(slots))
+		return nil
+	}
+	return l.Notifier.NotifyAvailableSlots(slots)
+}
+
+// Alert forwards the alert if this instance is the leader
+func (l LeaderOnly) Alert(text string) error {
+	if !l.Elector.IsLeader() {
+		return nil
+	}
+	if a, ok := l.Notifier.(notify.Alerter); ok {
+		return a.Alert(text)
+	}
+	return nil
+}
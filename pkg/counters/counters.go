@@ -0,0 +1,103 @@
+package counters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Totals are running totals of the checks, along with the last known
+// values, counted since the first check recorded rather than since start
+type Totals struct {
+	Since       time.Time      `json:"since"` // First check counted
+	Checks      int            `json:"checks_total"`
+	Errors      map[string]int `json:"errors_total,omitempty"` // Failed checks by class
+	SlotsFound  int            `json:"slots_found_total"`
+	LastCheck   time.Time      `json:"last_check,omitempty"`
+	LastSuccess time.Time      `json:"last_success,omitempty"`
+	LastSlots   int            `json:"last_slots"` // Slots found by the last successful check
+}
+
+// Store keeps the totals, persisted to a JSON file so they survive
+// restarts and reboots
+type Store struct {
+	path string
+
+	mu     sync.Mutex
+	totals Totals
+}
+
+// Load reads the totals from path, starting from zero if it doesn't exist
+func Load(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.totals); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Record counts a check done at t, failed if err is set, and saves the totals
+func (s *Store) Record(result scraper.CheckResult, err error, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.totals.Since.IsZero() {
+		s.totals.Since = t
+	}
+	s.totals.Checks++
+	s.totals.LastCheck = t
+	if err != nil {
+		if s.totals.Errors == nil {
+			s.totals.Errors = make(map[string]int)
+		}
+		s.totals.Errors[scraper.ErrorClass(err)]++
+	} else {
+		s.totals.SlotsFound += len(result.Slots)
+		s.totals.LastSuccess = t
+		s.totals.LastSlots = len(result.Slots)
+	}
+	return s.save()
+}
+
+// Totals returns a copy of the current totals
+func (s *Store) Totals() Totals {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	totals := s.totals
+	totals.Errors = make(map[string]int, len(s.totals.Errors))
+	for class, n := range s.totals.Errors {
+		totals.Errors[class] = n
+	}
+	return totals
+}
+
+// save writes the totals. Callers hold mu.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.totals, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return err
+	}
+	// Replace the file atomically so a crash never leaves it half-written
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,130 @@
+package desktop
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// maxLines is the number of slots listed in a notification, desktop
+// notifications only show a few lines
+const maxLines = 4
+
+// Client raises native notifications on the machine running the scraper:
+// osascript on macOS, notify-send on Linux and a PowerShell balloon tip on
+// Windows
+type Client struct {
+	noNotify bool
+}
+
+// NewClient creates a new desktop notification client
+func NewClient(noNotify bool) *Client {
+	return &Client{noNotify: noNotify}
+}
+
+// NotifyAvailableSlots raises a notification summarizing the slots
+func (c *Client) NotifyAvailableSlots(slots []scraper.Slot) error {
+	if len(slots) == 0 {
+		return nil
+	}
+
+	if c.noNotify {
+		log.Println("💻 Desktop notification skipped (--no-notify)")
+		return nil
+	}
+
+	if err := c.show(fmt.Sprintf("🎉 空き枠発見！(%d件)", len(slots)), slotText(slots)); err != nil {
+		return err
+	}
+	log.Printf("💻 Desktop notification shown")
+	return nil
+}
+
+// Alert raises a notification with the given text, used for operational
+// alerts
+func (c *Client) Alert(text string) error {
+	if c.noNotify {
+		log.Printf("💻 Alert skipped (--no-notify): %s", text)
+		return nil
+	}
+
+	return c.show("Scraper alert", text)
+}
+
+// slotText lists the first slots, one per line, and how many more there are
+func slotText(slots []scraper.Slot) string {
+	var lines []string
+	for i, slot := range slots {
+		if i == maxLines {
+			lines = append(lines, fmt.Sprintf("他%d件", len(slots)-maxLines))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("📅 %s %s (%s)", slot.When(), slot.Location, slot.Category))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *Client) show(title, text string) error {
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s sound name \"Glass\"",
+			appleScriptString(text), appleScriptString(title))
+		err = run("osascript", "-e", script)
+	case "linux", "freebsd", "openbsd", "netbsd":
+		err = run("notify-send", "--app-name=policeScrapper", "--urgency=critical", title, text)
+	case "windows":
+		// The balloon needs the script to stay around, don't wait for it
+		cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", balloonScript(title, text))
+		if err = cmd.Start(); err == nil {
+			go cmd.Wait()
+		}
+	default:
+		return fmt.Errorf("desktop notifications aren't supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to show desktop notification: %v", err)
+	}
+	return nil
+}
+
+// run runs a notification command, returning its output with any error
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// powerShellString quotes s as a PowerShell literal string
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// balloonScript shows a tray balloon tip, which needs no extra modules and
+// works from a console session. The icon is kept around long enough for the
+// balloon to be seen.
+func balloonScript(title, text string) string {
+	return fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms
+Add-Type -AssemblyName System.Drawing
+$n = New-Object System.Windows.Forms.NotifyIcon
+$n.Icon = [System.Drawing.SystemIcons]::Information
+$n.BalloonTipTitle = %s
+$n.BalloonTipText = %s
+$n.Visible = $true
+$n.ShowBalloonTip(10000)
+Start-Sleep -Seconds 10
+$n.Dispose()`, powerShellString(title), powerShellString(text))
+}
@@ -0,0 +1,108 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+)
+
+// Interaction types Discord sends, see
+// https://discord.com/developers/docs/interactions/receiving-and-responding
+const (
+	InteractionPing               = 1
+	InteractionApplicationCommand = 2
+)
+
+// Interaction response types
+const (
+	ResponsePong    = 1
+	ResponseMessage = 4 // CHANNEL_MESSAGE_WITH_SOURCE
+)
+
+// flagEphemeral makes a reply only visible to the user who sent the command
+const flagEphemeral = 1 << 6
+
+// User is the Discord user who sent an interaction
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// Interaction is a request Discord sends to the interactions endpoint. Only
+// the fields of slash commands are decoded.
+type Interaction struct {
+	Type int `json:"type"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string      `json:"name"`
+			Value interface{} `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+	Member *struct {
+		User User `json:"user"`
+	} `json:"member"` // Set in servers
+	User *User `json:"user"` // Set in direct messages
+}
+
+// UserID returns the ID of the user who sent the interaction
+func (i Interaction) UserID() string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// Text returns the slash command's options joined by spaces, e.g. "pause
+// 2h" for a single string option
+func (i Interaction) Text() string {
+	text := ""
+	for _, o := range i.Data.Options {
+		if text != "" {
+			text += " "
+		}
+		text += fmt.Sprint(o.Value)
+	}
+	return text
+}
+
+// Response answers an interaction
+type Response struct {
+	Type int           `json:"type"`
+	Data *ResponseData `json:"data,omitempty"`
+}
+
+// ResponseData is the message of a response
+type ResponseData struct {
+	Content string `json:"content"`
+	Flags   int    `json:"flags,omitempty"`
+}
+
+// Reply returns the response answering a command with a message only the
+// user sees
+func Reply(text string) Response {
+	return Response{Type: ResponseMessage, Data: &ResponseData{Content: text, Flags: flagEphemeral}}
+}
+
+// ParsePublicKey parses the hex public key of a Discord application
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Discord public key: expected %d hex-encoded bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// VerifyRequest checks the X-Signature-Ed25519 header of a request, the
+// application key's signature of the X-Signature-Timestamp header followed
+// by the body. Discord rejects endpoints that don't verify it.
+func VerifyRequest(publicKey ed25519.PublicKey, body []byte, timestamp, signature string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(publicKey, append([]byte(timestamp), body...), sig)
+}
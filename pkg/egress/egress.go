@@ -0,0 +1,154 @@
+package egress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/notify"
+)
+
+// Monitor watches the public IP the scraper uses and whether the reservation
+// site still accepts it, to tell ISP-level blocks apart from site outages
+type Monitor struct {
+	ipURL    string
+	siteURL  string
+	interval time.Duration
+	alerter  notify.Alerter
+	client   *http.Client
+
+	mu      sync.Mutex
+	ip      string
+	blocked bool
+}
+
+// NewMonitor creates a monitor resolving the public IP through ipURL (a
+// service answering with the caller's IP as plain text). If proxy is set,
+// requests go through it like the browser's, so the IP seen is the one the
+// site sees.
+func NewMonitor(ipURL, siteURL, proxy string, interval time.Duration, alerter notify.Alerter) (*Monitor, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &Monitor{
+		ipURL:    ipURL,
+		siteURL:  siteURL,
+		interval: interval,
+		alerter:  alerter,
+		client:   &http.Client{Timeout: 30 * time.Second, Transport: transport},
+	}, nil
+}
+
+// Run checks the egress now and then every interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	m.Check(ctx)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check resolves the public IP and probes the site, alerting on changes
+func (m *Monitor) Check(ctx context.Context) {
+	ip, err := m.PublicIP(ctx)
+	if err != nil {
+		log.Printf("❌ Failed to resolve public IP: %v", err)
+	}
+	blocked, err := m.SiteBlocked(ctx)
+	if err != nil {
+		// The site being unreachable is an outage, not a block
+		log.Printf("❌ Failed to probe reservation site: %v", err)
+	}
+
+	m.mu.Lock()
+	previousIP, wasBlocked := m.ip, m.blocked
+	if ip != "" {
+		m.ip = ip
+	}
+	if err == nil {
+		m.blocked = blocked
+	}
+	m.mu.Unlock()
+
+	if ip != "" && previousIP != "" && ip != previousIP {
+		m.alert(fmt.Sprintf("🌐 Public IP changed from %s to %s", previousIP, ip))
+	} else if ip != "" && previousIP == "" {
+		log.Printf("🌐 Public IP is %s", ip)
+	}
+	if err == nil && blocked != wasBlocked {
+		if blocked {
+			m.alert(fmt.Sprintf("🚫 Reservation site answers 403 Forbidden to our IP %s, it may be blocked", ip))
+		} else {
+			m.alert(fmt.Sprintf("✓ Reservation site accepts our IP %s again", ip))
+		}
+	}
+}
+
+func (m *Monitor) alert(text string) {
+	log.Print(text)
+	if m.alerter == nil {
+		return
+	}
+	if err := m.alerter.Alert(text); err != nil {
+		log.Printf("Error sending alert: %v", err)
+	}
+}
+
+// PublicIP asks the IP echo service for our address
+func (m *Monitor) PublicIP(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.ipURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("IP service failed with status: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	ip := strings.TrimSpace(string(body))
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("IP service returned %q", ip)
+	}
+	return ip, nil
+}
+
+// SiteBlocked reports whether the reservation site refuses us with a 403
+func (m *Monitor) SiteBlocked(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.siteURL, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusForbidden, nil
+}
@@ -0,0 +1,115 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/scraper"
+)
+
+// Recipient is an email address and how its messages are rendered
+type Recipient struct {
+	Address string
+	Profile notify.Profile
+}
+
+// Client sends slot notifications by email
+type Client struct {
+	host       string
+	port       string
+	username   string
+	password   string
+	from       string
+	recipients []Recipient
+	noNotify   bool
+}
+
+// NewClient creates a new email client
+func NewClient(host, port, username, password, from string, recipients []Recipient, noNotify bool) *Client {
+	return &Client{
+		host:       host,
+		port:       port,
+		username:   username,
+		password:   password,
+		from:       from,
+		recipients: recipients,
+		noNotify:   noNotify,
+	}
+}
+
+// NotifyAvailableSlots sends one email per recipient about available slots.
+// A failed recipient doesn't keep the others from being emailed.
+func (c *Client) NotifyAvailableSlots(slots []scraper.Slot) error {
+	if len(slots) == 0 {
+		return nil
+	}
+
+	if c.noNotify {
+		log.Println("📧 Email skipped (--no-notify)")
+		return nil
+	}
+
+	var errs []error
+	for _, r := range c.recipients {
+		msg := buildMessage(c.from, r.Address, notify.Subject(r.Profile, slots), notify.Text(r.Profile, slots))
+		if err := c.send(r.Address, msg); err != nil {
+			errs = append(errs, fmt.Errorf("failed to email %s: %v", r.Address, err))
+		}
+	}
+
+	if sent := len(c.recipients) - len(errs); sent > 0 {
+		log.Printf("📧 Email sent to %d recipient(s)", sent)
+	}
+	return errors.Join(errs...)
+}
+
+// Alert emails a plain text message to every recipient, even if some fail
+func (c *Client) Alert(text string) error {
+	if c.noNotify {
+		log.Printf("📧 Alert skipped (--no-notify): %s", text)
+		return nil
+	}
+
+	var errs []error
+	for _, r := range c.recipients {
+		msg := buildMessage(c.from, r.Address, notify.AlertSubject(r.Profile), notify.AlertText(r.Profile, text))
+		if err := c.send(r.Address, msg); err != nil {
+			errs = append(errs, fmt.Errorf("failed to email %s: %v", r.Address, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Client) send(to string, msg []byte) error {
+	if c.host == "" || c.from == "" {
+		return fmt.Errorf("email configuration is incomplete")
+	}
+
+	var auth smtp.Auth
+	if c.username != "" {
+		auth = smtp.PlainAuth("", c.username, c.password, c.host)
+	}
+	return smtp.SendMail(net.JoinHostPort(c.host, c.port), auth, c.from, []string{to}, msg)
+}
+
+// buildMessage creates a plain-text RFC 5322 message
+func buildMessage(from, to, subject, body string) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "From: %s\r\n", from)
+	fmt.Fprintf(&sb, "To: %s\r\n", to)
+	fmt.Fprintf(&sb, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&sb, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(sb.String())
+}
@@ -0,0 +1,85 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Action types
+const (
+	ActionNavigate = "navigate" // Load URL, the start of the flow
+	ActionClick    = "click"    // Click Selector
+	ActionInput    = "input"    // Set Value in the field at Selector
+	ActionSelect   = "select"   // Choose the option of Value in the select at Selector
+	ActionLoad     = "load"     // Wait for the page of URL the previous action led to
+)
+
+// Action is one step of a flow through the site
+type Action struct {
+	Type     string `json:"type"`
+	Selector string `json:"selector,omitempty"` // CSS selector of the element
+	Value    string `json:"value,omitempty"`    // Typed or selected value
+	URL      string `json:"url,omitempty"`      // Page loaded, for navigate and load
+	Label    string `json:"label,omitempty"`    // Text of the element, for humans reading the script
+	Secret   bool   `json:"secret,omitempty"`   // A password, whose value isn't recorded
+}
+
+// String describes the action in one line
+func (a Action) String() string {
+	switch a.Type {
+	case ActionNavigate, ActionLoad:
+		return fmt.Sprintf("%s %s", a.Type, a.URL)
+	case ActionInput, ActionSelect:
+		if a.Secret {
+			return fmt.Sprintf("%s %s = (secret)", a.Type, a.Selector)
+		}
+		return fmt.Sprintf("%s %s = %q", a.Type, a.Selector, a.Value)
+	default:
+		if a.Label != "" {
+			return fmt.Sprintf("%s %s (%s)", a.Type, a.Selector, a.Label)
+		}
+		return fmt.Sprintf("%s %s", a.Type, a.Selector)
+	}
+}
+
+// Script is a recorded flow through the site, e.g. booking a slot, that
+// can be replayed action by action. Editing the selectors keeps it working
+// when the site changes, or it's recorded again.
+type Script struct {
+	RecordedAt time.Time `json:"recorded_at"`
+	Actions    []Action  `json:"actions"`
+}
+
+// Load reads a script from path
+func Load(path string) (Script, error) {
+	var s Script
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from the command line
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Save writes the script to path
+func (s Script) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	// Replace the file atomically so a previous recording is never half
+	// overwritten
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,126 @@
+package gchat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/scraper"
+)
+
+// Client posts notifications to a Google Chat space webhook
+type Client struct {
+	webhookURL string
+	noNotify   bool
+	client     *http.Client
+}
+
+// NewClient creates a new Google Chat client
+func NewClient(webhookURL string, noNotify bool) *Client {
+	return &Client{
+		webhookURL: webhookURL,
+		noNotify:   noNotify,
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// NotifyAvailableSlots posts a card listing the slots
+func (c *Client) NotifyAvailableSlots(slots []scraper.Slot) error {
+	if len(slots) == 0 {
+		return nil
+	}
+
+	if c.noNotify {
+		log.Println("💭 Google Chat message skipped (--no-notify)")
+		return nil
+	}
+
+	if err := c.send(createSlotCard(slots)); err != nil {
+		return err
+	}
+	log.Printf("💭 Google Chat message sent")
+	return nil
+}
+
+// Alert posts a plain text message, used for operational alerts
+func (c *Client) Alert(text string) error {
+	if c.noNotify {
+		log.Printf("💭 Alert skipped (--no-notify): %s", text)
+		return nil
+	}
+	return c.send(map[string]interface{}{"text": text})
+}
+
+// createSlotCard builds a card with one line per slot and a button to the
+// reservation page. The text is shown in notifications.
+func createSlotCard(slots []scraper.Slot) map[string]interface{} {
+	widgets := make([]interface{}, 0, len(slots))
+	for _, slot := range slots {
+		widgets = append(widgets, map[string]interface{}{
+			"decoratedText": map[string]interface{}{
+				"topLabel":    "📍 " + slot.Location,
+				"text":        "📅 " + slot.When(),
+				"bottomLabel": "👥 " + slot.Category,
+				"wrapText":    true,
+			},
+		})
+	}
+
+	button := map[string]interface{}{
+		"buttonList": map[string]interface{}{
+			"buttons": []interface{}{
+				map[string]interface{}{
+					"text": "Open reservation page",
+					"onClick": map[string]interface{}{
+						"openLink": map[string]interface{}{"url": notify.ReserveURL},
+					},
+				},
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		"text": notify.Subject(notify.ProfileFull, slots),
+		"cardsV2": []interface{}{
+			map[string]interface{}{
+				"cardId": "slots",
+				"card": map[string]interface{}{
+					"header": map[string]interface{}{
+						"title":    "🎉 空き枠発見！",
+						"subtitle": fmt.Sprintf("%d件", len(slots)),
+					},
+					"sections": []interface{}{
+						map[string]interface{}{"widgets": widgets},
+						map[string]interface{}{"widgets": []interface{}{button}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (c *Client) send(payload interface{}) error {
+	if c.webhookURL == "" {
+		return fmt.Errorf("google chat configuration is incomplete")
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %v", err)
+	}
+
+	resp, err := c.client.Post(c.webhookURL, "application/json; charset=UTF-8", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to send Google Chat message: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("google chat message failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
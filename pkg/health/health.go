@@ -0,0 +1,236 @@
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/scraper"
+)
+
+// Health states of a target
+const (
+	Healthy = "healthy" // The last check read the target's row, recently
+	Stale   = "stale"   // Not read recently, or the last check couldn't read it
+	Broken  = "broken"  // Several checks in a row couldn't read it
+)
+
+// BrokenAfter is how many checks in a row must fail to read a target for it
+// to be broken rather than stale
+const BrokenAfter = 3
+
+// window is how many recent checks of a target are scored
+const window = 20
+
+// A target whose row is missing from BrokenAfter successful checks in a
+// row, e.g. a category the site removed, has its circuit opened: it's left
+// out of the scheduled checks, so it doesn't slow down the others, and
+// retried on its own schedule, after retryWait, doubling up to maxRetryWait
+const (
+	retryWait    = 30 * time.Minute
+	maxRetryWait = 6 * time.Hour
+)
+
+// Target is the health of a monitored target
+type Target struct {
+	Location string    `json:"location"`
+	Category string    `json:"category,omitempty"`
+	State    string    `json:"state"`
+	Score    int       `json:"score"`            // Percentage of the recent checks that read the target
+	Reason   string    `json:"reason,omitempty"` // Why it isn't healthy
+	LastRead time.Time `json:"last_read,omitempty"`
+	Misses   int       `json:"misses,omitempty"`   // Successful checks in a row that didn't find its row
+	RetryAt  time.Time `json:"retry_at,omitempty"` // Next check of the target while its circuit is open
+}
+
+// outcome is whether one check read a target, and why not
+type outcome struct {
+	ok     bool
+	miss   bool // The check went fine, but the target's row wasn't there
+	reason string
+}
+
+// record is what's known about a target's recent checks
+type record struct {
+	recent   []outcome // Oldest first, at most window
+	bad      int       // Checks in a row that didn't read it
+	misses   int       // Successful checks in a row that didn't find its row
+	retryAt  time.Time // Zero unless the circuit is open
+	lastRead time.Time
+}
+
+// open tells whether the target's circuit is open
+func (r *record) open() bool {
+	return r.misses >= BrokenAfter
+}
+
+// parseWarnings are warnings meaning the table wasn't parsed properly
+var parseWarnings = map[string]bool{
+	scraper.WarnTableMissing:    true,
+	scraper.WarnHeaderMissing:   true,
+	scraper.WarnDateParseFailed: true,
+	scraper.WarnDateOrder:       true,
+}
+
+// Tracker scores each target from the recent checks: whether they
+// succeeded, parsed the table cleanly and found the target's row. It's kept
+// in memory, so targets are stale after a restart until the first check.
+type Tracker struct {
+	mu      sync.Mutex
+	records map[string]*record
+}
+
+// NewTracker creates a tracker without any check recorded
+func NewTracker() *Tracker {
+	return &Tracker{records: make(map[string]*record)}
+}
+
+func keyOf(t config.Target) string {
+	return t.Location + "\x00" + t.Category
+}
+
+// Record scores a check of the given targets done at t, failed if err is
+// set. A failed check counts against the targets' health, but not towards
+// opening their circuits: the site or the browser is at fault, not the
+// target. It returns the targets whose circuit opened or closed.
+func (tr *Tracker) Record(targets []config.Target, result scraper.CheckResult, err error, t time.Time) (opened, closed []config.Target) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	for _, target := range targets {
+		o := check(target, result, err)
+		r := tr.records[keyOf(target)]
+		if r == nil {
+			r = &record{}
+			tr.records[keyOf(target)] = r
+		}
+		r.recent = append(r.recent, o)
+		if len(r.recent) > window {
+			r.recent = r.recent[len(r.recent)-window:]
+		}
+		wasOpen := r.open()
+		switch {
+		case o.ok:
+			r.bad, r.misses, r.retryAt = 0, 0, time.Time{}
+			r.lastRead = t
+			if wasOpen {
+				closed = append(closed, target)
+			}
+		case o.miss:
+			r.bad++
+			r.misses++
+			if r.open() {
+				r.retryAt = t.Add(retryAfter(r.misses))
+				if !wasOpen {
+					opened = append(opened, target)
+				}
+			}
+		default:
+			r.bad++
+			if wasOpen {
+				// Retry as if the check hadn't happened
+				r.retryAt = t.Add(retryAfter(r.misses))
+			}
+		}
+	}
+	return opened, closed
+}
+
+// retryAfter returns the wait before checking a target whose row was
+// missing from the last misses checks
+func retryAfter(misses int) time.Duration {
+	wait := retryWait << min(misses-BrokenAfter, 8)
+	return min(wait, maxRetryWait)
+}
+
+// Due returns the targets to check at now: every target but those whose
+// circuit is open and whose retry isn't due yet
+func (tr *Tracker) Due(targets []config.Target, now time.Time) []config.Target {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	due := make([]config.Target, 0, len(targets))
+	for _, target := range targets {
+		if r := tr.records[keyOf(target)]; r != nil && r.open() && now.Before(r.retryAt) {
+			continue
+		}
+		due = append(due, target)
+	}
+	return due
+}
+
+// Reset closes every circuit, so all targets are checked again
+func (tr *Tracker) Reset() {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	for _, r := range tr.records {
+		r.misses, r.retryAt = 0, time.Time{}
+	}
+}
+
+// check tells whether a check read the target
+func check(target config.Target, result scraper.CheckResult, err error) outcome {
+	if err != nil {
+		return outcome{reason: fmt.Sprintf("check failed (%s)", scraper.ErrorClass(err))}
+	}
+	for _, w := range result.Warnings {
+		if parseWarnings[w.Code] {
+			return outcome{reason: fmt.Sprintf("table not parsed (%s)", w.Code)}
+		}
+	}
+	for _, row := range result.Rows {
+		if !target.Matches(row.Location, row.Category) {
+			continue
+		}
+		for _, n := range row.Counts {
+			if n > 0 {
+				return outcome{ok: true}
+			}
+		}
+	}
+	return outcome{miss: true, reason: "row not found"}
+}
+
+// Targets returns the health of the targets at now. A target not read
+// within its staleAfter is stale.
+func (tr *Tracker) Targets(targets []config.Target, staleAfter func(config.Target) time.Duration, now time.Time) []Target {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	health := make([]Target, 0, len(targets))
+	for _, target := range targets {
+		h := Target{Location: target.Location, Category: target.Category, State: Healthy}
+		r := tr.records[keyOf(target)]
+		if r == nil {
+			h.State, h.Reason = Stale, "not checked yet"
+			health = append(health, h)
+			continue
+		}
+
+		good := 0
+		for _, o := range r.recent {
+			if o.ok {
+				good++
+			}
+		}
+		h.Score = good * 100 / len(r.recent)
+		h.LastRead = r.lastRead
+		h.Misses = r.misses
+		last := r.recent[len(r.recent)-1]
+		switch {
+		case r.open():
+			h.State, h.RetryAt = Broken, r.retryAt
+			h.Reason = fmt.Sprintf("%s, %d checks in a row, left out until %s", last.reason, r.bad, r.retryAt.In(config.JST).Format("01/02 15:04"))
+		case r.bad >= BrokenAfter:
+			h.State, h.Reason = Broken, fmt.Sprintf("%s, %d checks in a row", last.reason, r.bad)
+		case !last.ok:
+			h.State, h.Reason = Stale, last.reason
+		case now.Sub(r.lastRead) > staleAfter(target):
+			h.State, h.Reason = Stale, fmt.Sprintf("not read since %s", r.lastRead.Format("01/02 15:04"))
+		}
+		health = append(health, h)
+	}
+	return health
+}
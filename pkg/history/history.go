@@ -0,0 +1,151 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Log is an append-only record of check results, one JSON document per line
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Open returns the history log at path, created on the first Record
+func Open(path string) *Log {
+	return &Log{path: path}
+}
+
+// Record appends a check result
+func (l *Log) Record(result scraper.CheckResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600) // #nosec G304 - path comes from configuration
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Each calls fn for every recorded result, oldest first, until fn returns
+// false. Results recorded meanwhile are left out, so Record isn't held up
+// by a long scan.
+func (l *Log) Each(fn func(scraper.CheckResult) bool) error {
+	l.mu.Lock()
+	f, err := os.Open(l.path)
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = f.Stat(); err == nil {
+			size = info.Size()
+		} else {
+			f.Close()
+		}
+	}
+	l.mu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(io.LimitReader(f, size))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var result scraper.CheckResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			return fmt.Errorf("%s:%d: %v", l.path, line, err)
+		}
+		if !fn(result) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// Prune removes the results checked before before, returning how many
+func (l *Log) Prune(before time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	tmp := l.path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600) // #nosec G304 - path comes from configuration
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	w := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var result scraper.CheckResult
+		if json.Unmarshal(scanner.Bytes(), &result) == nil && result.CheckedAt.Before(before) {
+			pruned++
+			continue
+		}
+		if _, err = w.Write(append(scanner.Bytes(), '\n')); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = scanner.Err()
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || pruned == 0 {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return pruned, os.Rename(tmp, l.path)
+}
+
+// At returns the last result checked at or before t, the state of the
+// table as the scraper saw it at that time
+func (l *Log) At(t time.Time) (scraper.CheckResult, bool, error) {
+	var found scraper.CheckResult
+	var ok bool
+	err := l.Each(func(r scraper.CheckResult) bool {
+		if r.CheckedAt.After(t) {
+			return false
+		}
+		found, ok = r, true
+		return true
+	})
+	return found, ok, err
+}
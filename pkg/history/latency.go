@@ -0,0 +1,68 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Latency summarizes how long slots stayed available before disappearing
+type Latency struct {
+	Count int           `json:"count"` // Slots seen appearing and disappearing
+	P50   time.Duration `json:"p50_ns"`
+	P90   time.Duration `json:"p90_ns"`
+}
+
+type slotKey struct {
+	location, category, date string
+}
+
+// Lifetimes returns how long each slot that has come and gone was available:
+// from the first check that found it to the first check that didn't. The
+// resolution is the check interval, and slots still open are left out.
+func (l *Log) Lifetimes() ([]time.Duration, error) {
+	var lifetimes []time.Duration
+	firstSeen := make(map[slotKey]time.Time)
+	err := l.Each(func(r scraper.CheckResult) bool {
+		present := make(map[slotKey]bool, len(r.Slots))
+		for _, slot := range r.Slots {
+			k := slotKey{slot.Location, slot.Category, slot.Date}
+			present[k] = true
+			if _, ok := firstSeen[k]; !ok {
+				firstSeen[k] = r.CheckedAt
+			}
+		}
+		for k, seen := range firstSeen {
+			if !present[k] {
+				lifetimes = append(lifetimes, r.CheckedAt.Sub(seen))
+				delete(firstSeen, k)
+			}
+		}
+		return true
+	})
+	return lifetimes, err
+}
+
+// SlotLatency returns the p50 and p90 of slot lifetimes
+func (l *Log) SlotLatency() (Latency, error) {
+	lifetimes, err := l.Lifetimes()
+	if err != nil || len(lifetimes) == 0 {
+		return Latency{}, err
+	}
+	sort.Slice(lifetimes, func(i, j int) bool { return lifetimes[i] < lifetimes[j] })
+	return Latency{
+		Count: len(lifetimes),
+		P50:   percentile(lifetimes, 50),
+		P90:   percentile(lifetimes, 90),
+	}, nil
+}
+
+// percentile returns the nearest-rank percentile p of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,66 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Matrix is the availability table as a check saw it: a row per location
+// and category with its cells by status, and the dates it had open
+type Matrix struct {
+	CheckedAt time.Time         `json:"checked_at"`
+	Dates     []string          `json:"dates"` // Dates with an open slot, in the site's order
+	Rows      []MatrixRow       `json:"rows"`
+	Warnings  []scraper.Warning `json:"warnings,omitempty"`
+}
+
+// MatrixRow is a row of the table
+type MatrixRow struct {
+	Location string              `json:"location"`
+	Category string              `json:"category"`
+	Counts   map[string]int      `json:"counts"`         // Cells by status over the pages checked
+	Open     map[string][]string `json:"open,omitempty"` // Time windows of the open dates, empty if not read
+}
+
+// NewMatrix rebuilds the table of a recorded check from its rows and slots.
+// Checks recorded before rows were kept only have the rows of their slots.
+func NewMatrix(r scraper.CheckResult) Matrix {
+	m := Matrix{CheckedAt: r.CheckedAt, Dates: []string{}, Rows: []MatrixRow{}, Warnings: r.Warnings}
+	index := make(map[[2]string]int)
+	for _, row := range r.Rows {
+		index[[2]string{row.Location, row.Category}] = len(m.Rows)
+		m.Rows = append(m.Rows, MatrixRow{Location: row.Location, Category: row.Category, Counts: row.Counts})
+	}
+
+	seen := make(map[string]bool)
+	for _, slot := range r.Slots {
+		if !seen[slot.Date] {
+			seen[slot.Date] = true
+			m.Dates = append(m.Dates, slot.Date)
+		}
+		k := [2]string{slot.Location, slot.Category}
+		i, ok := index[k]
+		if !ok {
+			i = len(m.Rows)
+			index[k] = i
+			m.Rows = append(m.Rows, MatrixRow{Location: slot.Location, Category: slot.Category, Counts: map[string]int{}})
+		}
+		if m.Rows[i].Open == nil {
+			m.Rows[i].Open = make(map[string][]string)
+		}
+		times := m.Rows[i].Open[slot.Date]
+		if times == nil {
+			times = []string{}
+		}
+		m.Rows[i].Open[slot.Date] = append(times, slot.Times...)
+	}
+	sort.SliceStable(m.Rows, func(i, j int) bool {
+		if m.Rows[i].Location != m.Rows[j].Location {
+			return m.Rows[i].Location < m.Rows[j].Location
+		}
+		return m.Rows[i].Category < m.Rows[j].Category
+	})
+	return m
+}
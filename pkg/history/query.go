@@ -0,0 +1,204 @@
+package history
+
+import (
+	"fmt"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Statuses of the Filter, for checks and for slots
+const (
+	StatusFound = "found" // Checks that found slots
+	StatusEmpty = "empty" // Checks that found none
+	StatusOpen  = "open"  // Slots still listed by the last check
+	StatusGone  = "gone"  // Slots no longer listed
+)
+
+// Filter selects recorded checks or slots. Zero fields match everything.
+type Filter struct {
+	Status   string    // found or empty for checks, open or gone for slots
+	Location string    // Only slots at this location
+	Category string    // Only slots of this category
+	From, To time.Time // Only checks in this range, or slots seen in it, To excluded
+}
+
+// Validate reports whether the status applies to checks (forSlots false)
+// or slots (forSlots true)
+func (f Filter) Validate(forSlots bool) error {
+	switch f.Status {
+	case "":
+		return nil
+	case StatusFound, StatusEmpty:
+		if !forSlots {
+			return nil
+		}
+	case StatusOpen, StatusGone:
+		if forSlots {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid status %q", f.Status)
+}
+
+func (f Filter) inRange(t time.Time) bool {
+	return (f.From.IsZero() || !t.Before(f.From)) && (f.To.IsZero() || t.Before(f.To))
+}
+
+func (f Filter) matches(slot scraper.Slot) bool {
+	return (f.Location == "" || slot.Location == f.Location) &&
+		(f.Category == "" || slot.Category == f.Category)
+}
+
+// SlotRecord is a slot from the first check that found it to the last one
+// before it disappeared. A slot that comes back is a new record.
+type SlotRecord struct {
+	Location  string    `json:"location"`
+	Category  string    `json:"category"`
+	Date      string    `json:"date"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Open      bool      `json:"open"` // Still listed by the last check
+}
+
+// Match narrows the slots of a check to the filter's location and category,
+// and reports whether the check matches the filter
+func (f Filter) Match(r scraper.CheckResult) (scraper.CheckResult, bool) {
+	if !f.inRange(r.CheckedAt) {
+		return r, false
+	}
+	slots := []scraper.Slot{}
+	for _, slot := range r.Slots {
+		if f.matches(slot) {
+			slots = append(slots, slot)
+		}
+	}
+	if (f.Status == StatusFound && len(slots) == 0) || (f.Status == StatusEmpty && len(slots) > 0) {
+		return r, false
+	}
+	r.Slots = slots
+	return r, true
+}
+
+// Checks returns the recorded checks matching the filter, newest first,
+// skipping offset of them and returning at most limit, along with the total
+// number of matches. The slots of each check are narrowed to the filter's
+// location and category.
+func (l *Log) Checks(f Filter, offset, limit int) ([]scraper.CheckResult, int, error) {
+	// Only the latest offset+limit matches are kept, the page being among them
+	var latest []scraper.CheckResult
+	total := 0
+	err := l.Each(func(r scraper.CheckResult) bool {
+		if r, ok := f.Match(r); ok {
+			total++
+			latest = append(latest, r)
+			if len(latest) > offset+limit {
+				latest = latest[1:]
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	matched := make([]scraper.CheckResult, 0, len(latest))
+	for i := len(latest) - 1; i >= 0; i-- {
+		matched = append(matched, latest[i])
+	}
+	lo, hi := PageRange(len(matched), offset, limit)
+	return matched[lo:hi], total, nil
+}
+
+// Slots returns the slots seen by recorded checks matching the filter,
+// latest first, skipping offset of them and returning at most limit, along
+// with the total number of matches
+func (l *Log) Slots(f Filter, offset, limit int) ([]SlotRecord, int, error) {
+	runs := NewSlotRuns(f)
+	err := l.Each(func(r scraper.CheckResult) bool {
+		runs.Add(r.CheckedAt, r.Slots)
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	records, total := runs.Page(offset, limit)
+	return records, total, nil
+}
+
+// SlotRuns turns the slots of checks, added oldest first, into slot records
+type SlotRuns struct {
+	f       Filter
+	records []SlotRecord
+	open    map[slotKey]int // Index of the records still listed
+}
+
+// NewSlotRuns starts the slot records of the checks matching the filter
+func NewSlotRuns(f Filter) *SlotRuns {
+	return &SlotRuns{f: f, open: make(map[slotKey]int)}
+}
+
+// Add adds the slots of a check, those it doesn't list being gone since
+func (s *SlotRuns) Add(checkedAt time.Time, slots []scraper.Slot) {
+	present := make(map[slotKey]bool, len(slots))
+	for _, slot := range slots {
+		if !s.f.matches(slot) {
+			continue
+		}
+		k := slotKey{slot.Location, slot.Category, slot.Date}
+		present[k] = true
+		if i, ok := s.open[k]; ok {
+			s.records[i].LastSeen = checkedAt
+			continue
+		}
+		s.open[k] = len(s.records)
+		s.records = append(s.records, SlotRecord{
+			Location:  slot.Location,
+			Category:  slot.Category,
+			Date:      slot.Date,
+			FirstSeen: checkedAt,
+			LastSeen:  checkedAt,
+		})
+	}
+	for k := range s.open {
+		if !present[k] {
+			delete(s.open, k)
+		}
+	}
+}
+
+// Page returns the records matching the filter, latest first, skipping
+// offset of them and returning at most limit, along with the total number
+// of matches
+func (s *SlotRuns) Page(offset, limit int) ([]SlotRecord, int) {
+	for _, i := range s.open {
+		s.records[i].Open = true
+	}
+	f := s.f
+	matched := []SlotRecord{}
+	for i := len(s.records) - 1; i >= 0; i-- {
+		rec := s.records[i]
+		if (f.Status == StatusOpen && !rec.Open) || (f.Status == StatusGone && rec.Open) {
+			continue
+		}
+		// Keep slots seen at some time within the range
+		if (!f.To.IsZero() && !rec.FirstSeen.Before(f.To)) || (!f.From.IsZero() && rec.LastSeen.Before(f.From)) {
+			continue
+		}
+		matched = append(matched, rec)
+	}
+	lo, hi := PageRange(len(matched), offset, limit)
+	return matched[lo:hi], len(matched)
+}
+
+// PageRange returns the bounds of the page of n items starting at offset
+func PageRange(n, offset, limit int) (int, int) {
+	lo := offset
+	if lo > n {
+		lo = n
+	}
+	hi := lo + limit
+	if hi > n {
+		hi = n
+	}
+	return lo, hi
+}
@@ -0,0 +1,51 @@
+package history
+
+import (
+	"math"
+	"time"
+
+	"policeScrapper/pkg/config"
+)
+
+// SlotStats summarizes when and where slots appeared, to tune the polling
+// schedule. Times are those of the checks that first found the slots, so
+// accurate to one check interval.
+type SlotStats struct {
+	Slots       int            `json:"slots"`      // Slots seen appearing
+	ByHour      [24]int        `json:"by_hour"`    // Slots appearing in each hour of the day, Japan time
+	ByWeekday   [7]int         `json:"by_weekday"` // Slots appearing on each day of the week, Sunday first
+	ByLocation  map[string]int `json:"by_location"`
+	Gone        int            `json:"gone"`            // Slots seen disappearing
+	AvgLifetime time.Duration  `json:"avg_lifetime_ns"` // Average lifetime of the slots gone
+}
+
+// SlotStats aggregates the slots of every recorded check. A slot that comes
+// back counts again.
+func (l *Log) SlotStats() (SlotStats, error) {
+	stats := SlotStats{ByLocation: make(map[string]int)}
+	records, _, err := l.Slots(Filter{}, 0, math.MaxInt)
+	if err != nil {
+		return stats, err
+	}
+	for _, rec := range records {
+		seen := rec.FirstSeen.In(config.JST)
+		stats.Slots++
+		stats.ByHour[seen.Hour()]++
+		stats.ByWeekday[seen.Weekday()]++
+		stats.ByLocation[rec.Location]++
+	}
+
+	lifetimes, err := l.Lifetimes()
+	if err != nil {
+		return stats, err
+	}
+	var total time.Duration
+	for _, d := range lifetimes {
+		total += d
+	}
+	if len(lifetimes) > 0 {
+		stats.Gone = len(lifetimes)
+		stats.AvgLifetime = total / time.Duration(len(lifetimes))
+	}
+	return stats, nil
+}
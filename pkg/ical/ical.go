@@ -0,0 +1,94 @@
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event is a calendar event, lasting whole days if AllDay is set
+type Event struct {
+	UID         string
+	Start, End  time.Time
+	AllDay      bool
+	Summary     string
+	Description string
+}
+
+// Calendar is an iCalendar (RFC 5545) feed
+type Calendar struct {
+	Name    string
+	Refresh time.Duration // How often subscribers should fetch it again, if set
+	Stamp   time.Time     // When the events were last updated
+	Events  []Event
+}
+
+// Write encodes the calendar
+func (c Calendar) Write(w io.Writer) error {
+	var sb strings.Builder
+	line := func(s string) {
+		sb.WriteString(fold(s))
+		sb.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//policeScrapper//Slots//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME:" + escape(c.Name))
+	}
+	if c.Refresh > 0 {
+		duration := fmt.Sprintf("PT%dM", max(1, int(c.Refresh.Minutes())))
+		line("REFRESH-INTERVAL;VALUE=DURATION:" + duration)
+		line("X-PUBLISHED-TTL:" + duration)
+	}
+	stamp := c.Stamp.UTC().Format("20060102T150405Z")
+	for _, e := range c.Events {
+		line("BEGIN:VEVENT")
+		line("UID:" + escape(e.UID))
+		line("DTSTAMP:" + stamp)
+		if e.AllDay {
+			line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + e.End.Format("20060102"))
+		} else {
+			line("DTSTART:" + e.Start.UTC().Format("20060102T150405Z"))
+			line("DTEND:" + e.End.UTC().Format("20060102T150405Z"))
+		}
+		line("SUMMARY:" + escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + escape(e.Description))
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// escape escapes a text value
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold splits a content line into lines of at most 75 octets, without
+// splitting UTF-8 characters, continued lines starting with a space
+func fold(s string) string {
+	var sb strings.Builder
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		sb.WriteString(s[:cut])
+		sb.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74 // The leading space counts
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
@@ -0,0 +1,56 @@
+package line
+
+import (
+	"fmt"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Alt text styles of slot notifications. The alt text is what push
+// notifications and watches show, as they can't render flex messages.
+const (
+	// AltTextCount counts the slots, e.g. 空き枠が見つかりました！(3件)
+	AltTextCount = "count"
+	// AltTextShort names the first slot, short enough for a watch, e.g.
+	// 府中 08/02(土) available — book now
+	AltTextShort = "short"
+)
+
+// LINE rejects alt texts over 400 characters, this leaves room for the
+// part number of long lists
+const altTextMaxLength = 390
+
+var weekdays = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+// altText renders the alt text of a notification about the slots
+func altText(style string, slots []scraper.Slot, now time.Time) string {
+	if style != AltTextShort {
+		return fmt.Sprintf("空き枠が見つかりました！(%d件)", len(slots))
+	}
+
+	first := slots[0]
+	text := first.Location + " " + shortDate(first.Date, now)
+	if len(first.Times) > 0 {
+		text += " " + first.Times[0]
+	}
+	if len(slots) > 1 {
+		text += fmt.Sprintf(" +%d", len(slots)-1)
+	}
+	text += " available — book now"
+	if r := []rune(text); len(r) > altTextMaxLength {
+		text = string(r[:altTextMaxLength])
+	}
+	return text
+}
+
+// shortDate adds the weekday to a slot date, e.g. 08/02(土). Dates that
+// can't be parsed, e.g. already rendered for a language, are returned
+// unchanged.
+func shortDate(date string, now time.Time) string {
+	day, err := scraper.ParseDate(date, now)
+	if err != nil {
+		return date
+	}
+	return fmt.Sprintf("%s(%s)", date, weekdays[day.Weekday()])
+}
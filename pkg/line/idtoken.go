@@ -0,0 +1,45 @@
+package line
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const verifyIDTokenURL = "https://api.line.me/oauth2/v2.1/verify"
+
+// VerifyIDToken checks an ID token issued to a LIFF app of the given LINE
+// Login channel and returns the LINE user ID it belongs to
+func VerifyIDToken(ctx context.Context, idToken, channelID string) (string, error) {
+	form := url.Values{"id_token": {idToken}, "client_id": {channelID}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyIDTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify ID token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ID token rejected with status: %d", resp.StatusCode)
+	}
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return "", fmt.Errorf("failed to decode ID token claims: %v", err)
+	}
+	if claims.Sub == "" {
+		return "", fmt.Errorf("ID token has no user ID")
+	}
+	return claims.Sub, nil
+}
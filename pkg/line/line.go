@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 
+	"policeScrapper/pkg/clock"
 	"policeScrapper/pkg/scraper"
 )
 
@@ -17,6 +18,13 @@ type Client struct {
 	channelToken string
 	userID       string
 	noNotify     bool
+
+	// AltTextStyle is the alt text of slot notifications, AltTextCount
+	// (default) or AltTextShort
+	AltTextStyle string
+	// SnoozeReplies offers to snooze the notified dates with quick replies,
+	// sent back as chat commands to the bot
+	SnoozeReplies bool
 }
 
 // NewClient creates a new LINE client
@@ -36,10 +44,22 @@ type Message struct {
 
 // LineContent represents the content of a LINE message
 type LineContent struct {
-	Type     string      `json:"type"`
-	Text     string      `json:"text,omitempty"`
-	AltText  string      `json:"altText,omitempty"`
-	Contents interface{} `json:"contents,omitempty"`
+	Type       string      `json:"type"`
+	Text       string      `json:"text,omitempty"`
+	AltText    string      `json:"altText,omitempty"`
+	Contents   interface{} `json:"contents,omitempty"`
+	QuickReply *QuickReply `json:"quickReply,omitempty"`
+}
+
+// QuickReply holds the buttons shown under a message
+type QuickReply struct {
+	Items []QuickReplyItem `json:"items"`
+}
+
+// QuickReplyItem is a button sending a text message when tapped
+type QuickReplyItem struct {
+	Type   string            `json:"type"` // Always "action"
+	Action map[string]string `json:"action"`
 }
 
 // NotifyAvailableSlots sends a notification about available slots
@@ -53,17 +73,51 @@ func (c *Client) NotifyAvailableSlots(slots []scraper.Slot) error {
 		return nil
 	}
 
-	flexMessage := c.createFlexMessage(slots)
-	payload := Message{
-		To:       c.userID,
-		Messages: []LineContent{flexMessage},
+	// Long lists take several messages, sent in order. Packing them in as
+	// few requests as possible also saves quota, which counts requests.
+	messages := createFlexMessages(slots, altText(c.AltTextStyle, slots, clock.Now()))
+	if c.SnoozeReplies {
+		messages[len(messages)-1].QuickReply = snoozeReplies(slots)
+	}
+	for start := 0; start < len(messages); start += messagesPerPush {
+		end := min(start+messagesPerPush, len(messages))
+		if err := c.sendMessage(Message{To: c.userID, Messages: messages[start:end]}); err != nil {
+			if start > 0 {
+				return fmt.Errorf("sent %d of %d messages: %v", start, len(messages), err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Alert sends a plain text message, used for operational alerts
+func (c *Client) Alert(text string) error {
+	if c.noNotify {
+		log.Printf("📱 Alert skipped (--no-notify): %s", text)
+		return nil
 	}
 
-	return c.sendMessage(payload)
+	return c.sendMessage(Message{
+		To:       c.userID,
+		Messages: []LineContent{{Type: "text", Text: text}},
+	})
 }
 
 func (c *Client) sendMessage(payload Message) error {
-	if c.channelToken == "" || c.userID == "" {
+	if c.userID == "" {
+		return fmt.Errorf("LINE configuration is incomplete")
+	}
+	if err := c.post(lineAPIURL, payload); err != nil {
+		return err
+	}
+
+	log.Printf("📱 Notification sent")
+	return nil
+}
+
+func (c *Client) post(url string, payload interface{}) error {
+	if c.channelToken == "" {
 		return fmt.Errorf("LINE configuration is incomplete")
 	}
 
@@ -72,7 +126,7 @@ func (c *Client) sendMessage(payload Message) error {
 		return fmt.Errorf("failed to marshal JSON: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", lineAPIURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
@@ -90,12 +144,91 @@ func (c *Client) sendMessage(payload Message) error {
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("message failed with status: %d", resp.StatusCode)
 	}
-
-	log.Printf("📱 Notification sent")
 	return nil
 }
 
-func (c *Client) createFlexMessage(slots []scraper.Slot) LineContent {
+// snoozeReplies offers to snooze each date of the slots for a day, with
+// the bot's snooze command
+func snoozeReplies(slots []scraper.Slot) *QuickReply {
+	var items []QuickReplyItem
+	seen := make(map[string]bool)
+	for _, slot := range slots {
+		if seen[slot.Date] || len(items) == quickRepliesMax {
+			continue
+		}
+		seen[slot.Date] = true
+		items = append(items, QuickReplyItem{
+			Type: "action",
+			Action: map[string]string{
+				"type":  "message",
+				"label": "💤 " + slot.Date,
+				"text":  "snooze " + slot.Date,
+			},
+		})
+	}
+	return &QuickReply{Items: items}
+}
+
+// LINE message limits, see https://developers.line.biz/en/reference/messaging-api/#flex-message
+const (
+	slotsPerBubble     = 10        // Keeps bubbles well below their 30KB limit
+	bubblesPerCarousel = 12        // Carousels hold at most 12 bubbles
+	carouselMaxSize    = 45 * 1024 // Carousels are limited to 50KB of JSON
+	messagesPerPush    = 5         // A push request holds at most 5 messages
+	quickRepliesMax    = 13        // A message holds at most 13 quick replies
+)
+
+// createFlexMessages lays the slots out as bubbles of at most
+// slotsPerBubble slots, packed into as many carousel messages as the size
+// limits require. Every bubble states its place in the whole list.
+func createFlexMessages(slots []scraper.Slot, altText string) []LineContent {
+	var bubbles []interface{}
+	for start := 0; start < len(slots); start += slotsPerBubble {
+		end := min(start+slotsPerBubble, len(slots))
+		bubbles = append(bubbles, createBubble(slots[start:end], start, len(slots)))
+	}
+	if len(bubbles) == 1 {
+		return []LineContent{{
+			Type:     "flex",
+			AltText:  altText,
+			Contents: bubbles[0],
+		}}
+	}
+
+	var carousels [][]interface{}
+	var current []interface{}
+	size := 0
+	for _, bubble := range bubbles {
+		data, _ := json.Marshal(bubble)
+		if len(current) > 0 && (len(current) == bubblesPerCarousel || size+len(data) > carouselMaxSize) {
+			carousels = append(carousels, current)
+			current, size = nil, 0
+		}
+		current = append(current, bubble)
+		size += len(data)
+	}
+	carousels = append(carousels, current)
+
+	messages := make([]LineContent, len(carousels))
+	for i, contents := range carousels {
+		text := altText
+		if len(carousels) > 1 {
+			text += fmt.Sprintf(" %d/%d", i+1, len(carousels))
+		}
+		messages[i] = LineContent{
+			Type:    "flex",
+			AltText: text,
+			Contents: map[string]interface{}{
+				"type":     "carousel",
+				"contents": contents,
+			},
+		}
+	}
+	return messages
+}
+
+// createBubble renders slots, starting at offset in a list of total slots
+func createBubble(slots []scraper.Slot, offset, total int) map[string]interface{} {
 	// Create boxes for each slot
 	boxes := make([]interface{}, len(slots))
 	for i, slot := range slots {
@@ -123,7 +256,7 @@ func (c *Client) createFlexMessage(slots []scraper.Slot) LineContent {
 						},
 						map[string]interface{}{
 							"type":   "text",
-							"text":   "📅 " + slot.Date,
+							"text":   "📅 " + slot.When(),
 							"size":   "sm",
 							"color":  "#666666",
 							"margin": "sm",
@@ -160,30 +293,36 @@ func (c *Client) createFlexMessage(slots []scraper.Slot) LineContent {
 
 	boxes = append(boxes, button)
 
-	return LineContent{
-		Type:    "flex",
-		AltText: fmt.Sprintf("空き枠が見つかりました！(%d件)", len(slots)),
-		Contents: map[string]interface{}{
-			"type": "bubble",
-			"header": map[string]interface{}{
-				"type":   "box",
-				"layout": "vertical",
-				"contents": []interface{}{
-					map[string]interface{}{
-						"type":   "text",
-						"text":   "🎉 空き枠発見！",
-						"size":   "xl",
-						"weight": "bold",
-						"color":  "#1DB446",
-					},
-				},
-			},
-			"body": map[string]interface{}{
-				"type":     "box",
-				"layout":   "vertical",
-				"contents": boxes,
-				"spacing":  "md",
-			},
+	header := []interface{}{
+		map[string]interface{}{
+			"type":   "text",
+			"text":   "🎉 空き枠発見！",
+			"size":   "xl",
+			"weight": "bold",
+			"color":  "#1DB446",
+		},
+	}
+	if len(slots) < total {
+		header = append(header, map[string]interface{}{
+			"type":  "text",
+			"text":  fmt.Sprintf("全%d件中 %d〜%d件目", total, offset+1, offset+len(slots)),
+			"size":  "sm",
+			"color": "#666666",
+		})
+	}
+
+	return map[string]interface{}{
+		"type": "bubble",
+		"header": map[string]interface{}{
+			"type":     "box",
+			"layout":   "vertical",
+			"contents": header,
+		},
+		"body": map[string]interface{}{
+			"type":     "box",
+			"layout":   "vertical",
+			"contents": boxes,
+			"spacing":  "md",
 		},
 	}
 }
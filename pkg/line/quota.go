@@ -0,0 +1,59 @@
+package line
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	quotaURL            = "https://api.line.me/v2/bot/message/quota"
+	quotaConsumptionURL = "https://api.line.me/v2/bot/message/quota/consumption"
+)
+
+// Usage returns the push messages sent this month and the monthly limit
+// (0 if the plan is unlimited)
+func (c *Client) Usage() (used, limit int, err error) {
+	var quota struct {
+		Type  string `json:"type"`
+		Value int    `json:"value"`
+	}
+	if err := c.getJSON(quotaURL, &quota); err != nil {
+		return 0, 0, err
+	}
+	var consumption struct {
+		TotalUsage int `json:"totalUsage"`
+	}
+	if err := c.getJSON(quotaConsumptionURL, &consumption); err != nil {
+		return 0, 0, err
+	}
+
+	if quota.Type != "limited" {
+		return consumption.TotalUsage, 0, nil
+	}
+	return consumption.TotalUsage, quota.Value, nil
+}
+
+func (c *Client) getJSON(url string, out interface{}) error {
+	if c.channelToken == "" {
+		return fmt.Errorf("LINE configuration is incomplete")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.channelToken)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get quota: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("quota request failed with status: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
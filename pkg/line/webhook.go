@@ -0,0 +1,57 @@
+package line
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+)
+
+const replyAPIURL = "https://api.line.me/v2/bot/message/reply"
+
+// WebhookBody is the body of the requests LINE sends to the bot's webhook,
+// see https://developers.line.biz/en/reference/messaging-api/#webhooks
+type WebhookBody struct {
+	Destination string  `json:"destination"`
+	Events      []Event `json:"events"`
+}
+
+// Event is a webhook event. Only the fields of text messages are decoded.
+type Event struct {
+	Type       string `json:"type"` // e.g. message or follow
+	ReplyToken string `json:"replyToken"`
+	Source     struct {
+		Type   string `json:"type"` // user, group or room
+		UserID string `json:"userId"`
+	} `json:"source"`
+	Message struct {
+		Type string `json:"type"` // e.g. text or sticker
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+// VerifySignature checks the X-Line-Signature header of a webhook request,
+// the base64 HMAC-SHA256 of the body keyed with the channel secret
+func VerifySignature(channelSecret string, body []byte, signature string) bool {
+	got, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(channelSecret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Reply answers a webhook event with a text message. Replies don't count
+// towards the monthly push message quota.
+func (c *Client) Reply(replyToken, text string) error {
+	if c.noNotify {
+		log.Printf("📱 Reply skipped (--no-notify): %s", text)
+		return nil
+	}
+
+	return c.post(replyAPIURL, struct {
+		ReplyToken string        `json:"replyToken"`
+		Messages   []LineContent `json:"messages"`
+	}{replyToken, []LineContent{{Type: "text", Text: text}}})
+}
@@ -0,0 +1,112 @@
+package logfmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Format selects how log lines are written
+type Format string
+
+const (
+	// FormatEmoji writes lines as logged, with their emoji markers
+	FormatEmoji Format = "emoji"
+	// FormatPlain replaces emoji markers with ASCII level tags and drops
+	// other emoji, for log shippers and grep pipelines
+	FormatPlain Format = "plain"
+	// FormatJSON writes one JSON object per line with time, level and message
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(name))); f {
+	case "", FormatEmoji:
+		return FormatEmoji, nil
+	case FormatPlain, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q: expected emoji, plain or json", name)
+	}
+}
+
+// levels maps the emoji markers used in log messages to severities, most
+// severe first
+var levels = []struct{ marker, level string }{
+	{"❌", "error"},
+	{"⚠️", "warn"},
+	{"⏱", "warn"},
+}
+
+// Writer rewrites each log line to its format. The log package writes every
+// line with a single Write call.
+type Writer struct {
+	W      io.Writer
+	Format Format
+}
+
+// Write writes one log line in the writer's format
+func (w Writer) Write(p []byte) (int, error) {
+	if w.Format == FormatEmoji || w.Format == "" {
+		return w.W.Write(p)
+	}
+
+	line := strings.TrimRight(string(p), "\n")
+	level, marker := levelOf(line)
+	var out []byte
+	if w.Format == FormatJSON {
+		data, err := json.Marshal(struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{time.Now().UTC().Format(time.RFC3339), level, stripEmoji(line)})
+		if err != nil {
+			return 0, err
+		}
+		out = append(data, '\n')
+	} else {
+		// The marker becomes a level tag in place, after the timestamp
+		if marker != "" {
+			line = strings.Replace(line, marker, strings.ToUpper(level)+":", 1)
+		}
+		out = []byte(stripEmoji(line) + "\n")
+	}
+	if _, err := w.W.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// levelOf returns the severity of a line and the marker it was found from
+func levelOf(line string) (level, marker string) {
+	for _, l := range levels {
+		if strings.Contains(line, l.marker) {
+			return l.level, l.marker
+		}
+	}
+	return "info", ""
+}
+
+// stripEmoji removes emoji along with the space following them
+func stripEmoji(line string) string {
+	var sb strings.Builder
+	skipSpace := false
+	for _, r := range line {
+		if unicode.Is(unicode.So, r) || unicode.Is(unicode.Variation_Selector, r) {
+			// Drop the space separating the marker from the text too
+			skipSpace = true
+			continue
+		}
+		if skipSpace && r == ' ' {
+			skipSpace = false
+			continue
+		}
+		skipSpace = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,140 @@
+package matrix
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/scraper"
+)
+
+// Client posts notifications to a Matrix room
+type Client struct {
+	homeserver  string
+	accessToken string
+	roomID      string
+	noNotify    bool
+	client      *http.Client
+}
+
+// NewClient creates a new Matrix client posting to roomID (e.g.
+// !abc:example.org) on the homeserver (e.g. https://matrix.example.org)
+func NewClient(homeserver, accessToken, roomID string, noNotify bool) *Client {
+	return &Client{
+		homeserver:  strings.TrimSuffix(homeserver, "/"),
+		accessToken: accessToken,
+		roomID:      roomID,
+		noNotify:    noNotify,
+		client:      &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// message is an m.room.message event with an HTML body
+type message struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+// NotifyAvailableSlots posts the slot list with the booking link
+func (c *Client) NotifyAvailableSlots(slots []scraper.Slot) error {
+	if len(slots) == 0 {
+		return nil
+	}
+
+	if c.noNotify {
+		log.Println("💠 Matrix message skipped (--no-notify)")
+		return nil
+	}
+
+	err := c.send(message{
+		MsgType:       "m.text",
+		Body:          notify.Text(notify.ProfileFull, slots),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: formatHTML(slots),
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("💠 Matrix message sent")
+	return nil
+}
+
+// Alert posts a plain text notice
+func (c *Client) Alert(text string) error {
+	if c.noNotify {
+		log.Printf("💠 Alert skipped (--no-notify): %s", text)
+		return nil
+	}
+	return c.send(message{MsgType: "m.notice", Body: text})
+}
+
+// formatHTML renders the slots as an HTML list, grouped like the LINE message
+func formatHTML(slots []scraper.Slot) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<h4>🎉 空き枠発見！(%d件)</h4><ul>", len(slots))
+	for _, slot := range slots {
+		fmt.Fprintf(&sb, "<li><b>%s</b> 📍 %s<br>👥 %s</li>",
+			html.EscapeString(slot.When()), html.EscapeString(slot.Location), html.EscapeString(slot.Category))
+	}
+	fmt.Fprintf(&sb, `</ul><p><a href="%s">予約する</a></p>`, html.EscapeString(notify.ReserveURL))
+	return sb.String()
+}
+
+func (c *Client) send(msg message) error {
+	if c.homeserver == "" || c.accessToken == "" || c.roomID == "" {
+		return fmt.Errorf("matrix configuration is incomplete")
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		c.homeserver, url.PathEscape(c.roomID), txnID())
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Matrix message: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			ErrCode string `json:"errcode"`
+			Error   string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.ErrCode != "" {
+			return fmt.Errorf("matrix error %s: %s", apiErr.ErrCode, apiErr.Error)
+		}
+		return fmt.Errorf("matrix request failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// txnID returns a unique transaction ID, so the homeserver can drop
+// duplicates of the same request
+func txnID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
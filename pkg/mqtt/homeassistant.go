@@ -0,0 +1,86 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// DefaultDiscoveryPrefix is Home Assistant's default MQTT discovery prefix
+const DefaultDiscoveryPrefix = "homeassistant"
+
+// entity is a Home Assistant entity read from the status topic
+type entity struct {
+	component   string // sensor or binary_sensor
+	id          string
+	name        string
+	template    string
+	deviceClass string
+}
+
+var entities = []entity{
+	{"sensor", "last_check", "Last check", "{{ value_json.checked_at }}", "timestamp"},
+	{"sensor", "slots_found", "Slots found", "{{ value_json.slots }}", ""},
+	{"sensor", "earliest_slot", "Earliest slot", "{{ value_json.earliest | default('none') }}", ""},
+	{"binary_sensor", "check_problem", "Check problem", "{{ 'OFF' if value_json.ok else 'ON' }}", "problem"},
+}
+
+var unsafeID = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// PublishDiscovery announces the status entities to Home Assistant's MQTT
+// discovery, so they show up as one device with sensors for the last check,
+// the slots found and the earliest slot, and a problem binary sensor. The
+// announcements are retained, so Home Assistant gets them whenever it starts.
+func (c *Client) PublishDiscovery(prefix string) error {
+	if c.noNotify {
+		return nil
+	}
+	if prefix == "" {
+		prefix = DefaultDiscoveryPrefix
+	}
+	node := "police_scraper_" + unsafeID.ReplaceAllString(c.cfg.Topic, "_")
+	device := map[string]interface{}{
+		"identifiers": []string{node},
+		"name":        "Police Scraper",
+	}
+	for _, e := range entities {
+		cfg := map[string]interface{}{
+			"name":           e.name,
+			"unique_id":      node + "_" + e.id,
+			"state_topic":    c.cfg.Topic + "/status",
+			"value_template": e.template,
+			"device":         device,
+		}
+		if e.deviceClass != "" {
+			cfg["device_class"] = e.deviceClass
+		}
+		payload, err := json.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+		topic := fmt.Sprintf("%s/%s/%s/%s/config", prefix, e.component, node, e.id)
+		if err := c.Publish(topic, payload, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// earliest returns the slot date (MM/DD) coming first from now on
+func earliest(slots []scraper.Slot, now time.Time) string {
+	var best string
+	var bestAt time.Time
+	for _, slot := range slots {
+		at, err := scraper.ParseDate(slot.Date, now)
+		if err != nil {
+			continue
+		}
+		if best == "" || at.Before(bestAt) {
+			best, bestAt = slot.Date, at
+		}
+	}
+	return best
+}
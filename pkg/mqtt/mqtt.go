@@ -0,0 +1,298 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"time"
+
+	"policeScrapper/pkg/clock"
+	"policeScrapper/pkg/scraper"
+)
+
+// Config describes the broker and where events are published
+type Config struct {
+	Broker   string // e.g. tcp://localhost:1883 or ssl://broker.example.com:8883
+	Topic    string // Prefix of the topics, events go to <topic>/slots and <topic>/status
+	QoS      byte   // 0 (at most once) or 1 (at least once)
+	Username string
+	Password string
+	ClientID string // Random if empty
+}
+
+// Client publishes events to an MQTT broker. It speaks just enough MQTT
+// 3.1.1 to publish: it connects for every event, which suits events minutes
+// apart and needs no reconnection logic.
+type Client struct {
+	cfg      Config
+	addr     string
+	tls      bool
+	noNotify bool
+}
+
+// timeout bounds every exchange with the broker
+const timeout = 10 * time.Second
+
+// NewClient checks the configuration and creates a client
+func NewClient(cfg Config, noNotify bool) (*Client, error) {
+	u, err := url.Parse(cfg.Broker)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid broker %q: expected e.g. tcp://localhost:1883", cfg.Broker)
+	}
+	c := &Client{cfg: cfg, addr: u.Host, noNotify: noNotify}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		if u.Port() == "" {
+			c.addr = net.JoinHostPort(u.Hostname(), "1883")
+		}
+	case "ssl", "tls", "mqtts":
+		c.tls = true
+		if u.Port() == "" {
+			c.addr = net.JoinHostPort(u.Hostname(), "8883")
+		}
+	default:
+		return nil, fmt.Errorf("invalid broker %q: unsupported scheme %q", cfg.Broker, u.Scheme)
+	}
+	if cfg.QoS > 1 {
+		return nil, fmt.Errorf("invalid QoS %d: expected 0 or 1", cfg.QoS)
+	}
+	if c.cfg.Topic == "" {
+		c.cfg.Topic = "scraper"
+	}
+	if c.cfg.ClientID == "" {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		c.cfg.ClientID = "scraper-" + hex.EncodeToString(b)
+	}
+	return c, nil
+}
+
+// SlotEvent is published to <topic>/slots when slots are found
+type SlotEvent struct {
+	FoundAt time.Time      `json:"found_at"`
+	Count   int            `json:"count"`
+	Slots   []scraper.Slot `json:"slots"`
+}
+
+// StatusEvent is published, retained, to <topic>/status after every check
+type StatusEvent struct {
+	CheckedAt time.Time `json:"checked_at"`
+	OK        bool      `json:"ok"`
+	Slots     int       `json:"slots"`
+	Earliest  string    `json:"earliest,omitempty"` // Date of the earliest slot, MM/DD
+	Error     string    `json:"error,omitempty"`
+}
+
+// NotifyAvailableSlots publishes a slot event
+func (c *Client) NotifyAvailableSlots(slots []scraper.Slot) error {
+	if len(slots) == 0 {
+		return nil
+	}
+
+	if c.noNotify {
+		log.Println("📡 MQTT event skipped (--no-notify)")
+		return nil
+	}
+
+	payload, err := json.Marshal(SlotEvent{FoundAt: time.Now(), Count: len(slots), Slots: slots})
+	if err != nil {
+		return err
+	}
+	if err := c.Publish(c.cfg.Topic+"/slots", payload, false); err != nil {
+		return err
+	}
+	log.Printf("📡 MQTT event published to %s/slots", c.cfg.Topic)
+	return nil
+}
+
+// PublishStatus publishes the outcome of a check as the retained status, so
+// subscribers get the latest status as soon as they connect
+func (c *Client) PublishStatus(result scraper.CheckResult, checkErr error) {
+	if c.noNotify {
+		return
+	}
+	event := StatusEvent{CheckedAt: time.Now(), OK: checkErr == nil}
+	if checkErr != nil {
+		event.Error = checkErr.Error()
+	} else {
+		event.CheckedAt = result.CheckedAt
+		event.Slots = len(result.Slots)
+		event.Earliest = earliest(result.Slots, clock.Now())
+	}
+	payload, err := json.Marshal(event)
+	if err == nil {
+		err = c.Publish(c.cfg.Topic+"/status", payload, true)
+	}
+	if err != nil {
+		log.Printf("Error publishing MQTT status: %v", err)
+	}
+}
+
+// Publish connects to the broker, publishes the payload and disconnects.
+// With QoS 1 it waits for the broker's acknowledgement.
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: timeout}
+	if c.tls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, &tls.Config{MinVersion: tls.VersionTLS12})
+	} else {
+		conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	if _, err := conn.Write(c.connectPacket()); err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %v", err)
+	}
+	kind, body, err := readPacket(r)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %v", err)
+	}
+	if kind != 0x20 || len(body) != 2 {
+		return fmt.Errorf("unexpected MQTT packet %#x instead of CONNACK", kind)
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("MQTT broker refused the connection: %s", connackReason(body[1]))
+	}
+
+	const packetID = 1
+	if _, err := conn.Write(publishPacket(topic, payload, c.cfg.QoS, retain, packetID)); err != nil {
+		return fmt.Errorf("failed to publish to MQTT broker: %v", err)
+	}
+	if c.cfg.QoS == 1 {
+		kind, body, err := readPacket(r)
+		if err != nil {
+			return fmt.Errorf("no MQTT publish acknowledgement: %v", err)
+		}
+		if kind != 0x40 || len(body) != 2 || binary.BigEndian.Uint16(body) != packetID {
+			return fmt.Errorf("unexpected MQTT packet %#x instead of PUBACK", kind)
+		}
+	}
+
+	// DISCONNECT, errors don't matter anymore
+	_, _ = conn.Write([]byte{0xE0, 0x00})
+	return nil
+}
+
+func (c *Client) connectPacket() []byte {
+	var vh bytes.Buffer
+	writeString(&vh, "MQTT")
+	vh.WriteByte(4)     // Protocol level of MQTT 3.1.1
+	flags := byte(0x02) // Clean session
+	if c.cfg.Username != "" {
+		flags |= 0x80
+		if c.cfg.Password != "" {
+			flags |= 0x40
+		}
+	}
+	vh.WriteByte(flags)
+	_ = binary.Write(&vh, binary.BigEndian, uint16(60)) // Keep alive, in seconds
+	writeString(&vh, c.cfg.ClientID)
+	if c.cfg.Username != "" {
+		writeString(&vh, c.cfg.Username)
+		if c.cfg.Password != "" {
+			writeString(&vh, c.cfg.Password)
+		}
+	}
+	return packet(0x10, vh.Bytes())
+}
+
+func publishPacket(topic string, payload []byte, qos byte, retain bool, packetID uint16) []byte {
+	header := byte(0x30) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	var body bytes.Buffer
+	writeString(&body, topic)
+	if qos > 0 {
+		_ = binary.Write(&body, binary.BigEndian, packetID)
+	}
+	body.Write(payload)
+	return packet(header, body.Bytes())
+}
+
+// packet prepends the fixed header: the type and flags, and the remaining
+// length as a variable length integer
+func packet(header byte, body []byte) []byte {
+	out := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func readPacket(r *bufio.Reader) (kind byte, body []byte, err error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed MQTT packet length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7F) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body = make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header & 0xF0, body, nil
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+func connackReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client ID rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad username or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("code %d", code)
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Digest holds slots that aren't urgent and sends them together at an
+// interval. A slot found by several checks is sent once.
+type Digest struct {
+	notifier Notifier
+
+	mu      sync.Mutex
+	pending []scraper.Slot
+}
+
+// NewDigest creates a digest sending through n
+func NewDigest(n Notifier) *Digest {
+	return &Digest{notifier: n}
+}
+
+// NotifyAvailableSlots holds the slots for the next digest
+func (d *Digest) NotifyAvailableSlots(slots []scraper.Slot) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, slot := range slots {
+		if !containsSlot(d.pending, slot) {
+			d.pending = append(d.pending, slot)
+		}
+	}
+	log.Printf("🗞 %d slot(s) held for the next digest", len(d.pending))
+	return nil
+}
+
+// Run sends the digest every interval until ctx is done
+func (d *Digest) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Flush(); err != nil {
+				log.Printf("❌ Error sending digest: %v", err)
+			}
+		}
+	}
+}
+
+// Flush sends the held slots, if any
+func (d *Digest) Flush() error {
+	d.mu.Lock()
+	slots := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+
+	if len(slots) == 0 {
+		return nil
+	}
+	log.Printf("🗞 Sending digest of %d slot(s)", len(slots))
+	return d.notifier.NotifyAvailableSlots(slots)
+}
+
+func containsSlot(slots []scraper.Slot, slot scraper.Slot) bool {
+	for _, s := range slots {
+		if s.Location == slot.Location && s.Category == slot.Category && s.Date == slot.Date {
+			return true
+		}
+	}
+	return false
+}
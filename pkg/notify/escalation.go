@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Caller places phone calls reading a message out loud
+type Caller interface {
+	Call(text string) error
+}
+
+// Escalation wraps a notifier and calls when found slots aren't
+// acknowledged in time, since overnight slot drops are easy to sleep
+// through. Only one escalation is pending at a time: slots found while
+// waiting for an acknowledgement don't push the call back.
+type Escalation struct {
+	notifier Notifier
+	caller   Caller
+	after    time.Duration
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// NewEscalation creates an escalation calling after the given time
+func NewEscalation(n Notifier, caller Caller, after time.Duration) *Escalation {
+	return &Escalation{notifier: n, caller: caller, after: after}
+}
+
+// NotifyAvailableSlots forwards the slots and starts waiting for an
+// acknowledgement. It waits even if the notification failed, as the call is
+// then the only way to reach the user.
+func (e *Escalation) NotifyAvailableSlots(slots []scraper.Slot) error {
+	err := e.notifier.NotifyAvailableSlots(slots)
+	if len(slots) == 0 {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.timer == nil {
+		text := fmt.Sprintf("%d appointment slots were found, on %s. Check your messages to book.",
+			len(slots), strings.Join(scraper.SlotDates(slots), ", "))
+		e.timer = time.AfterFunc(e.after, func() { e.escalate(text) })
+	}
+	return err
+}
+
+// Alert forwards operational alerts, which are never escalated
+func (e *Escalation) Alert(text string) error {
+	if a, ok := e.notifier.(Alerter); ok {
+		return a.Alert(text)
+	}
+	return nil
+}
+
+// Acknowledge cancels the pending call. It returns false if there was none.
+func (e *Escalation) Acknowledge() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.timer == nil {
+		return false
+	}
+	e.timer.Stop()
+	e.timer = nil
+	return true
+}
+
+// Pending reports whether a call will be placed unless acknowledged
+func (e *Escalation) Pending() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.timer != nil
+}
+
+func (e *Escalation) escalate(text string) {
+	e.mu.Lock()
+	e.timer = nil
+	e.mu.Unlock()
+
+	log.Printf("📞 Slots not acknowledged within %s, calling", e.after)
+	if err := e.caller.Call(text); err != nil {
+		log.Printf("❌ Error placing escalation call: %v", err)
+	}
+}
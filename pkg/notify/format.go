@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/scraper"
+)
+
+// Profile selects how a notification is rendered for a recipient
+type Profile string
+
+const (
+	// ProfileFull is the regular multi-line message
+	ProfileFull Profile = "full"
+	// ProfileSMS is a short plain-text message for email-to-SMS gateways
+	ProfileSMS Profile = "sms"
+)
+
+// ReserveURL is the reservation page linked from notifications
+var ReserveURL = config.DefaultBaseURL
+
+// SMSMaxLength is the longest message carrier gateways deliver as one SMS,
+// in characters of the GSM 7-bit alphabet
+const SMSMaxLength = 160
+
+// SMSMaxUnicodeLength is the longest SMS with characters outside the GSM
+// 7-bit alphabet, e.g. Japanese, which is sent as UCS-2
+const SMSMaxUnicodeLength = 70
+
+// gsm7 is the GSM 7-bit default alphabet, and gsm7Extension the characters
+// of its extension table, which take two characters each
+const (
+	gsm7          = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7Extension = "^{}\\[~]|€"
+)
+
+// ParseProfile returns the profile with the given name
+func ParseProfile(name string) (Profile, error) {
+	switch p := Profile(strings.ToLower(strings.TrimSpace(name))); p {
+	case "", ProfileFull:
+		return ProfileFull, nil
+	case ProfileSMS:
+		return ProfileSMS, nil
+	default:
+		return "", fmt.Errorf("unknown notification profile %q", name)
+	}
+}
+
+// Subject returns a one-line summary of the found slots. SMS gateways
+// prepend the subject to the text, so the SMS profile has none.
+func Subject(profile Profile, slots []scraper.Slot) string {
+	if profile == ProfileSMS {
+		return ""
+	}
+	return fmt.Sprintf("空き枠が見つかりました！(%d件)", len(slots))
+}
+
+// Text renders the found slots as plain text for the given profile
+func Text(profile Profile, slots []scraper.Slot) string {
+	if profile == ProfileSMS {
+		return smsText(slots)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🎉 空き枠発見！\n")
+	for _, slot := range slots {
+		fmt.Fprintf(&sb, "\n📍 %s\n👥 %s\n📅 %s\n", slot.Location, slot.Category, slot.When())
+	}
+	fmt.Fprintf(&sb, "\n予約する: %s\n", ReserveURL)
+	return sb.String()
+}
+
+// GoneText renders slots that were open at the previous check but are gone
+func GoneText(slots []scraper.Slot) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "👋 空き枠がなくなりました (%d件)\n", len(slots))
+	for _, slot := range slots {
+		fmt.Fprintf(&sb, "\n📅 %s %s (%s)", slot.When(), slot.Location, slot.Category)
+	}
+	return sb.String()
+}
+
+// AlertSubject returns the subject of operational alerts
+func AlertSubject(profile Profile) string {
+	if profile == ProfileSMS {
+		return ""
+	}
+	return "Scraper alert"
+}
+
+// AlertText renders an operational alert for the given profile
+func AlertText(profile Profile, text string) string {
+	if profile == ProfileSMS {
+		return smsTruncate(smsClean(text))
+	}
+	return text
+}
+
+// smsText renders slots as a single line fitting in one SMS, see smsFits,
+// grouping dates by location and dropping whatever doesn't fit. The test
+// centers are romanized, so the line stays in the GSM 7-bit alphabet.
+func smsText(slots []scraper.Slot) string {
+	var locations []string
+	dates := make(map[string][]string)
+	for _, slot := range slots {
+		location := smsClean(DefaultLocationNames.Name(slot.Location))
+		if _, ok := dates[location]; !ok {
+			locations = append(locations, location)
+		}
+		dates[location] = append(dates[location], smsClean(slot.Date))
+	}
+
+	text := "Slots open:"
+	best := text + moreSuffix(len(slots))
+	shown := 0
+	for _, location := range locations {
+		text += " " + location
+		for i, date := range dates[location] {
+			if i == 0 {
+				text += " " + date
+			} else {
+				text += "," + date
+			}
+			shown++
+			candidate := text + moreSuffix(len(slots)-shown)
+			if !smsFits(candidate) {
+				return smsTruncate(best)
+			}
+			best = candidate
+		}
+	}
+	return best
+}
+
+func moreSuffix(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" +%d more", n)
+}
+
+// smsClean drops the emoji and symbols outside the GSM 7-bit alphabet and
+// puts the text on one line. Letters and digits of other scripts are kept,
+// e.g. Japanese names, making the SMS a shorter UCS-2 one.
+func smsClean(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(gsm7, r) || strings.ContainsRune(gsm7Extension, r) || unicode.IsLetter(r) || unicode.IsNumber(r) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// smsFits tells whether text fits in one SMS: SMSMaxLength characters of
+// the GSM 7-bit alphabet, or SMSMaxUnicodeLength if any isn't in it
+func smsFits(s string) bool {
+	n := 0
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune(gsm7, r):
+			n++
+		case strings.ContainsRune(gsm7Extension, r):
+			n += 2
+		default:
+			return len(utf16.Encode([]rune(s))) <= SMSMaxUnicodeLength
+		}
+	}
+	return n <= SMSMaxLength
+}
+
+// smsTruncate cuts text to fit in one SMS
+func smsTruncate(s string) string {
+	r := []rune(s)
+	for len(r) > 0 && !smsFits(string(r)) {
+		r = r[:len(r)-1]
+	}
+	return string(r)
+}
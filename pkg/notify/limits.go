@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Limits describes the payload and rate constraints of a notification channel
+type Limits struct {
+	MaxSlots  int // Maximum slots per message, 0 for no limit
+	PerMinute int // Maximum messages per minute, 0 for no limit
+}
+
+// Channel limits, kept well below the documented maximums
+var (
+	// The LINE client splits long lists into carousels itself, keeping the
+	// list's context across messages
+	LineLimits = Limits{PerMinute: 60}
+	// Email batches keep SMS profile messages within SMSMaxLength
+	EmailLimits = Limits{MaxSlots: 15, PerMinute: 20}
+	// Twilio queues about one SMS per second per number
+	SMSLimits = Limits{MaxSlots: 15, PerMinute: 30}
+	// Matrix events are limited to 64KB, homeservers rate limit senders
+	MatrixLimits = Limits{MaxSlots: 50, PerMinute: 10}
+	// Teams cards are limited to 28KB, webhooks to a few posts per second
+	TeamsLimits = Limits{MaxSlots: 30, PerMinute: 60}
+	// Google Chat messages are limited to 32KB, webhooks to one post per
+	// second per space
+	GoogleChatLimits = Limits{MaxSlots: 30, PerMinute: 60}
+	// Desktop notifications summarize the slots, a burst of them is noise
+	DesktopLimits = Limits{PerMinute: 6}
+)
+
+// Guarded wraps a notifier so messages respect the channel limits. Slot
+// lists that are too long are split over several messages instead of being
+// rejected, and sends wait when the per-minute rate is used up.
+type Guarded struct {
+	notifier Notifier
+	limits   Limits
+
+	mu   sync.Mutex
+	sent []time.Time
+}
+
+// Guard wraps a notifier with the given limits
+func Guard(n Notifier, limits Limits) *Guarded {
+	return &Guarded{notifier: n, limits: limits}
+}
+
+// NotifyAvailableSlots sends the slots in as many messages as needed. A
+// failed batch doesn't keep the next ones from being sent.
+func (g *Guarded) NotifyAvailableSlots(slots []scraper.Slot) error {
+	batches := Split(slots, g.limits.MaxSlots)
+	if len(batches) > 1 {
+		log.Printf("📱 Splitting %d slots into %d messages", len(slots), len(batches))
+	}
+	var errs []error
+	for i, batch := range batches {
+		g.wait()
+		if err := g.notifier.NotifyAvailableSlots(batch); err != nil {
+			if len(batches) > 1 {
+				err = fmt.Errorf("message %d of %d: %w", i+1, len(batches), err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Alert forwards the alert within the rate limit
+func (g *Guarded) Alert(text string) error {
+	a, ok := g.notifier.(Alerter)
+	if !ok {
+		return nil
+	}
+	g.wait()
+	return a.Alert(text)
+}
+
+// wait blocks until another message may be sent within the rate limit
+func (g *Guarded) wait() {
+	if g.limits.PerMinute <= 0 {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for {
+		// Forget messages older than the one-minute window
+		cutoff := time.Now().Add(-time.Minute)
+		for len(g.sent) > 0 && g.sent[0].Before(cutoff) {
+			g.sent = g.sent[1:]
+		}
+		if len(g.sent) < g.limits.PerMinute {
+			g.sent = append(g.sent, time.Now())
+			return
+		}
+		time.Sleep(time.Until(g.sent[0].Add(time.Minute)))
+	}
+}
+
+// Split divides slots into batches of at most max slots (no limit if max <= 0)
+func Split(slots []scraper.Slot, max int) [][]scraper.Slot {
+	if len(slots) == 0 {
+		return nil
+	}
+	if max <= 0 || len(slots) <= max {
+		return [][]scraper.Slot{slots}
+	}
+	var batches [][]scraper.Slot
+	for len(slots) > max {
+		batches = append(batches, slots[:max])
+		slots = slots[max:]
+	}
+	return append(batches, slots)
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Language selects how slot dates are rendered for a recipient
+type Language string
+
+const (
+	// LanguageNone keeps the dates as listed on the site, e.g. 08/02
+	LanguageNone Language = ""
+	// LanguageJapanese renders dates like 8月2日(土)
+	LanguageJapanese Language = "ja"
+	// LanguageEnglish renders dates like Sat, Aug 2
+	LanguageEnglish Language = "en"
+)
+
+var japaneseWeekdays = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+// ParseLanguage returns the language with the given code
+func ParseLanguage(code string) (Language, error) {
+	switch l := Language(strings.ToLower(strings.TrimSpace(code))); l {
+	case LanguageNone, LanguageJapanese, LanguageEnglish:
+		return l, nil
+	default:
+		return "", fmt.Errorf("unknown language %q: expected ja or en", code)
+	}
+}
+
+// FormatDate renders a slot date (MM/DD) in the language, with its weekday.
+// Dates that can't be parsed are returned unchanged.
+func (l Language) FormatDate(date string, now time.Time) string {
+	if l == LanguageNone {
+		return date
+	}
+	day, err := scraper.ParseDate(date, now)
+	if err != nil {
+		return date
+	}
+	if l == LanguageEnglish {
+		return day.Format("Mon, Jan 2")
+	}
+	return fmt.Sprintf("%d月%d日(%s)", day.Month(), day.Day(), japaneseWeekdays[day.Weekday()])
+}
+
+// Apply returns a copy of the slots with their dates rendered in the language
+func (l Language) Apply(slots []scraper.Slot, now time.Time) []scraper.Slot {
+	if l == LanguageNone {
+		return slots
+	}
+	out := make([]scraper.Slot, len(slots))
+	for i, slot := range slots {
+		slot.Date = l.FormatDate(slot.Date, now)
+		out[i] = slot
+	}
+	return out
+}
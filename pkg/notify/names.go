@@ -0,0 +1,43 @@
+package notify
+
+import "policeScrapper/pkg/scraper"
+
+// DefaultLocationNames maps test center names to their romanized names
+var DefaultLocationNames = LocationNames{
+	"府中試験場": "Fuchu Driving Center",
+	"鮫洲試験場": "Samezu Driving Center",
+	"江東試験場": "Koto Driving Center",
+}
+
+// LocationNames maps location names as shown on the site to display names
+type LocationNames map[string]string
+
+// NewLocationNames returns the built-in names extended (or overridden) by extra
+func NewLocationNames(extra map[string]string) LocationNames {
+	names := make(LocationNames, len(DefaultLocationNames)+len(extra))
+	for k, v := range DefaultLocationNames {
+		names[k] = v
+	}
+	for k, v := range extra {
+		names[k] = v
+	}
+	return names
+}
+
+// Name returns the display name of a location, or the location itself if unknown
+func (n LocationNames) Name(location string) string {
+	if name, ok := n[location]; ok {
+		return name
+	}
+	return location
+}
+
+// Apply returns a copy of slots with locations replaced by their display names
+func (n LocationNames) Apply(slots []scraper.Slot) []scraper.Slot {
+	renamed := make([]scraper.Slot, len(slots))
+	for i, slot := range slots {
+		slot.Location = n.Name(slot.Location)
+		renamed[i] = slot
+	}
+	return renamed
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Notifier delivers found slots to users
+type Notifier interface {
+	NotifyAvailableSlots(slots []scraper.Slot) error
+}
+
+// Alerter delivers plain text operational alerts
+type Alerter interface {
+	Alert(text string) error
+}
+
+// Multi sends notifications through several notifiers
+type Multi []Notifier
+
+// NotifyAvailableSlots notifies every notifier, even if some of them fail
+func (m Multi) NotifyAvailableSlots(slots []scraper.Slot) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.NotifyAvailableSlots(slots); err != nil {
+			errs = append(errs, fmt.Errorf("%T: %v", n, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Alert sends the alert through every notifier that supports alerts
+func (m Multi) Alert(text string) error {
+	var errs []error
+	for _, n := range m {
+		if a, ok := n.(Alerter); ok {
+			if err := a.Alert(text); err != nil {
+				errs = append(errs, fmt.Errorf("%T: %v", n, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
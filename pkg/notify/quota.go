@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// QuotaWarnRatio is the share of the monthly quota that triggers a warning
+const QuotaWarnRatio = 0.8
+
+// Meter reports the monthly message quota of a channel
+type Meter interface {
+	// Usage returns the messages sent this month and the limit (0 if unlimited)
+	Usage() (used, limit int, err error)
+}
+
+// QuotaStatus is the last known quota usage of a metered notifier
+type QuotaStatus struct {
+	Used      int       `json:"used"`
+	Limit     int       `json:"limit"` // 0 if unlimited
+	Exhausted bool      `json:"exhausted"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// QuotaFallback sends through a metered notifier until its monthly quota is
+// used up and through a fallback notifier after that. It warns once a month,
+// through the metered channel itself, when the quota is nearly used.
+type QuotaFallback struct {
+	primary  Notifier
+	meter    Meter
+	fallback Notifier
+
+	mu          sync.Mutex
+	status      QuotaStatus
+	warnedMonth string
+}
+
+// NewQuotaFallback creates a quota-aware notifier sending through primary
+// while meter reports quota left. fallback may be nil.
+func NewQuotaFallback(primary Notifier, meter Meter, fallback Notifier) *QuotaFallback {
+	return &QuotaFallback{primary: primary, meter: meter, fallback: fallback}
+}
+
+// NotifyAvailableSlots notifies through the primary notifier if it has quota left
+func (q *QuotaFallback) NotifyAvailableSlots(slots []scraper.Slot) error {
+	if q.refresh().Exhausted {
+		if q.fallback == nil {
+			return fmt.Errorf("monthly quota used up and no fallback channel configured")
+		}
+		log.Printf("📱 Monthly quota used up, notifying through fallback channel")
+		return q.fallback.NotifyAvailableSlots(slots)
+	}
+	return q.primary.NotifyAvailableSlots(slots)
+}
+
+// Alert forwards alerts the same way as notifications
+func (q *QuotaFallback) Alert(text string) error {
+	var target interface{} = q.primary
+	if q.refresh().Exhausted {
+		target = q.fallback
+	}
+	if a, ok := target.(Alerter); ok {
+		return a.Alert(text)
+	}
+	return nil
+}
+
+// Status returns the last known quota usage
+func (q *QuotaFallback) Status() QuotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.status
+}
+
+// refresh fetches the current usage, keeping the last known value on errors
+func (q *QuotaFallback) refresh() QuotaStatus {
+	used, limit, err := q.meter.Usage()
+
+	q.mu.Lock()
+	if err != nil {
+		log.Printf("❌ Failed to get notification quota: %v", err)
+		status := q.status
+		q.mu.Unlock()
+		return status
+	}
+	q.status = QuotaStatus{
+		Used:      used,
+		Limit:     limit,
+		Exhausted: limit > 0 && used >= limit,
+		CheckedAt: time.Now(),
+	}
+	status := q.status
+	month := time.Now().Format("2006-01")
+	warn := limit > 0 && float64(used) >= QuotaWarnRatio*float64(limit) && q.warnedMonth != month
+	if warn {
+		q.warnedMonth = month
+	}
+	q.mu.Unlock()
+
+	if warn {
+		text := fmt.Sprintf("⚠️ %d of %d monthly messages used", used, limit)
+		log.Print(text)
+		if a, ok := q.primary.(Alerter); ok {
+			if err := a.Alert(text); err != nil {
+				log.Printf("Error sending alert: %v", err)
+			}
+		}
+	}
+	return status
+}
@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"policeScrapper/pkg/clock"
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/scraper"
+)
+
+// QuietHours is a daily window, in the subscriber's time zone, during which
+// slots aren't sent
+type QuietHours struct {
+	config.Hours
+	Location *time.Location
+}
+
+// ParseQuietHours parses a window like "22-07" in the named time zone,
+// or the local time zone if tz is empty
+func ParseQuietHours(s, tz string) (QuietHours, error) {
+	loc := time.Local
+	if tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return QuietHours{}, fmt.Errorf("unknown time zone %q", tz)
+		}
+	}
+	if s == "" {
+		return QuietHours{Location: loc}, nil
+	}
+
+	h, err := config.ParseHours(s)
+	if err != nil {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours %q: expected HH-HH, e.g. 22-07", s)
+	}
+	return QuietHours{Hours: h, Location: loc}, nil
+}
+
+// Active reports whether t falls within the quiet hours
+func (q QuietHours) Active(t time.Time) bool {
+	if q.Location != nil {
+		t = t.In(q.Location)
+	}
+	return q.Contains(t.Hour())
+}
+
+// DateRange limits slots to dates between From and To (MM/DD, inclusive).
+// The range wraps the new year when From is after To; empty means any date.
+type DateRange struct {
+	From, To string
+}
+
+// ParseDateRange parses a range like "08/01-09/30"
+func ParseDateRange(s string) (DateRange, error) {
+	if s == "" {
+		return DateRange{}, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return DateRange{}, fmt.Errorf("invalid dates %q: expected MM/DD-MM/DD", s)
+	}
+	for _, d := range []string{from, to} {
+		if _, err := time.Parse("01/02", d); err != nil {
+			return DateRange{}, fmt.Errorf("invalid dates %q: expected MM/DD-MM/DD", s)
+		}
+	}
+	return DateRange{From: from, To: to}, nil
+}
+
+// Contains reports whether the slot date (MM/DD) is within the range
+func (r DateRange) Contains(date string) bool {
+	if r.From == "" {
+		return true
+	}
+	if r.From <= r.To {
+		return date >= r.From && date <= r.To
+	}
+	return date >= r.From || date <= r.To
+}
+
+// Preferences are one subscriber's own notification settings
+type Preferences struct {
+	Quiet    QuietHours
+	Dates    DateRange
+	Romanize bool     // Use romanized location names
+	Language Language // Language of slot dates, as listed on the site if empty
+}
+
+// Subscriber routes notifications to a single user according to their own
+// preferences, so one user's quiet hours or filters don't affect the others
+type Subscriber struct {
+	Name     string
+	Notifier Notifier
+	Prefs    Preferences
+	Names    LocationNames
+}
+
+// NotifyAvailableSlots forwards the slots the subscriber wants, rendered in
+// their language. During their quiet hours, only critical slots are.
+func (s Subscriber) NotifyAvailableSlots(slots []scraper.Slot) error {
+	now := clock.Now()
+	if s.Prefs.Quiet.Active(now) {
+		var critical []scraper.Slot
+		for _, slot := range slots {
+			if slot.Critical {
+				critical = append(critical, slot)
+			}
+		}
+		if len(critical) == 0 {
+			log.Printf("🔕 Quiet hours for %s, skipping %d slot(s)", s.Name, len(slots))
+			return nil
+		}
+		log.Printf("🔕 Quiet hours for %s, only notifying %d critical slot(s) of %d", s.Name, len(critical), len(slots))
+		slots = critical
+	}
+
+	var wanted []scraper.Slot
+	for _, slot := range slots {
+		if s.Prefs.Dates.Contains(slot.Date) {
+			wanted = append(wanted, slot)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+	if s.Prefs.Romanize {
+		wanted = s.Names.Apply(wanted)
+	}
+	wanted = s.Prefs.Language.Apply(wanted, now)
+	return s.Notifier.NotifyAvailableSlots(wanted)
+}
+
+// Alert forwards operational alerts regardless of preferences
+func (s Subscriber) Alert(text string) error {
+	if a, ok := s.Notifier.(Alerter); ok {
+		return a.Alert(text)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"sync"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Switch forwards to a notifier that can be replaced while in use, so the
+// notifiers wrapping it (escalation, digests, rules...) pick up the channels
+// of a reloaded config
+type Switch struct {
+	mu       sync.RWMutex
+	notifier Notifier
+}
+
+// NewSwitch creates a switch forwarding to n
+func NewSwitch(n Notifier) *Switch {
+	return &Switch{notifier: n}
+}
+
+// Set forwards to n from now on
+func (s *Switch) Set(n Notifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifier = n
+}
+
+// current returns the notifier forwarded to
+func (s *Switch) current() Notifier {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.notifier
+}
+
+// NotifyAvailableSlots forwards the slots
+func (s *Switch) NotifyAvailableSlots(slots []scraper.Slot) error {
+	return s.current().NotifyAvailableSlots(slots)
+}
+
+// Alert forwards operational alerts, if the notifier supports them
+func (s *Switch) Alert(text string) error {
+	if a, ok := s.current().(Alerter); ok {
+		return a.Alert(text)
+	}
+	return nil
+}
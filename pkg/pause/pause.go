@@ -0,0 +1,84 @@
+package pause
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State is whether scheduled checks are paused, and until when
+type State struct {
+	Paused bool      `json:"paused"`
+	Since  time.Time `json:"since,omitempty"`
+	Until  time.Time `json:"until,omitempty"` // Zero until resumed
+}
+
+// Flag records a pause of scheduled checks, e.g. once booked or while
+// travelling. It's persisted to a JSON file so it survives restarts.
+type Flag struct {
+	path string
+
+	mu    sync.Mutex
+	state State
+}
+
+// Load reads the flag from path, starting unpaused if it doesn't exist
+func Load(path string) (*Flag, error) {
+	f := &Flag{path: path}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// Set records a pause until the given time, or until cleared if zero
+func (f *Flag) Set(until time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = State{Paused: true, Since: time.Now(), Until: until}
+	return f.save()
+}
+
+// Clear records that checks resumed
+func (f *Flag) Clear() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = State{}
+	return f.save()
+}
+
+// State returns the current state
+func (f *Flag) State() State {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+// save writes the state. Callers hold mu.
+func (f *Flag) save() error {
+	data, err := json.MarshalIndent(f.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0750); err != nil {
+		return err
+	}
+	// Replace the file atomically so backups never read it half-written
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
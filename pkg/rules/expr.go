@@ -0,0 +1,339 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Expr is a compiled condition on a single slot, e.g.
+//
+//	weekend && days <= 30
+//	days <= 7 || location == "府中試験場"
+//
+// Variables:
+//
+//	days      int     days from today to the slot date
+//	weekday   string  mon, tue, wed, thu, fri, sat or sun
+//	weekend   bool    the slot is on a Saturday or Sunday
+//	month     int     month of the slot date, 1-12
+//	location  string
+//	category  string
+//
+// Operators are ==, !=, <, <=, >, >= (ints and strings), &&, || and !, with
+// parentheses for grouping. Strings are quoted with " or '.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile parses and type-checks an expression
+func Compile(src string) (*Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule %q: %v", src, err)
+	}
+	p := &parser{toks: toks}
+	root, err := p.or()
+	if err == nil && p.pos < len(p.toks) {
+		err = fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	if err == nil && root.kind() != kindBool {
+		err = fmt.Errorf("expected a condition, got %s", root.kind())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule %q: %v", src, err)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Match evaluates the expression for a slot as of now
+func (e *Expr) Match(slot scraper.Slot, now time.Time) bool {
+	return e.root.eval(newEnv(slot, now)).(bool)
+}
+
+// env holds the variables of one slot
+type env map[string]interface{}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// newEnv computes the variables of a slot
+func newEnv(slot scraper.Slot, now time.Time) env {
+	e := env{"location": slot.Location, "category": slot.Category, "days": 0, "weekday": "", "weekend": false, "month": 0}
+	date, err := scraper.ParseDate(slot.Date, now)
+	if err != nil {
+		return e
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	e["days"] = int(date.Sub(today).Hours() / 24)
+	e["weekday"] = weekdays[date.Weekday()]
+	e["weekend"] = date.Weekday() == time.Saturday || date.Weekday() == time.Sunday
+	e["month"] = int(date.Month())
+	return e
+}
+
+type kind string
+
+const (
+	kindBool   kind = "bool"
+	kindInt    kind = "int"
+	kindString kind = "string"
+)
+
+var variables = map[string]kind{
+	"days":     kindInt,
+	"weekday":  kindString,
+	"weekend":  kindBool,
+	"month":    kindInt,
+	"location": kindString,
+	"category": kindString,
+}
+
+type node interface {
+	kind() kind
+	eval(env) interface{}
+}
+
+type literal struct{ v interface{} }
+
+func (l literal) kind() kind {
+	switch l.v.(type) {
+	case bool:
+		return kindBool
+	case int:
+		return kindInt
+	}
+	return kindString
+}
+func (l literal) eval(env) interface{} { return l.v }
+
+type variable string
+
+func (v variable) kind() kind             { return variables[string(v)] }
+func (v variable) eval(e env) interface{} { return e[string(v)] }
+
+type not struct{ x node }
+
+func (n not) kind() kind             { return kindBool }
+func (n not) eval(e env) interface{} { return !n.x.eval(e).(bool) }
+
+type binary struct {
+	op   string
+	l, r node
+}
+
+func (b binary) kind() kind { return kindBool }
+
+func (b binary) eval(e env) interface{} {
+	switch b.op {
+	case "&&":
+		return b.l.eval(e).(bool) && b.r.eval(e).(bool)
+	case "||":
+		return b.l.eval(e).(bool) || b.r.eval(e).(bool)
+	}
+
+	l, r := b.l.eval(e), b.r.eval(e)
+	var c int
+	switch l := l.(type) {
+	case int:
+		c = l - r.(int)
+	case string:
+		c = strings.Compare(l, r.(string))
+	case bool:
+		if l != r.(bool) {
+			c = 1
+		}
+	}
+	switch b.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+type token struct {
+	text string
+	lit  interface{} // Value of number and string literals
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(rs) && rs[end] != r {
+				end++
+			}
+			if end == len(rs) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, token{text: string(rs[i : end+1]), lit: string(rs[i+1 : end])})
+			i = end + 1
+		case unicode.IsDigit(r):
+			end := i
+			for end < len(rs) && unicode.IsDigit(rs[end]) {
+				end++
+			}
+			n, err := strconv.Atoi(string(rs[i:end]))
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{text: string(rs[i:end]), lit: n})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(rs) && (unicode.IsLetter(rs[end]) || unicode.IsDigit(rs[end]) || rs[end] == '_') {
+				end++
+			}
+			toks = append(toks, token{text: string(rs[i:end])})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"} {
+				if strings.HasPrefix(string(rs[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q", r)
+			}
+			toks = append(toks, token{text: op})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+// parser is a recursive descent parser:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = primary [ ("==" | "!=" | "<" | "<=" | ">" | ">=") primary ]
+//	primary = "(" or ")" | variable | number | string | "true" | "false"
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos].text
+	}
+	return ""
+}
+
+func (p *parser) or() (node, error) {
+	return p.logical("||", p.and)
+}
+
+func (p *parser) and() (node, error) {
+	return p.logical("&&", p.unary)
+}
+
+func (p *parser) logical(op string, operand func() (node, error)) (node, error) {
+	l, err := operand()
+	for err == nil && p.peek() == op {
+		p.pos++
+		var r node
+		if r, err = operand(); err != nil {
+			break
+		}
+		if l.kind() != kindBool || r.kind() != kindBool {
+			return nil, fmt.Errorf("%s needs conditions on both sides", op)
+		}
+		l = binary{op: op, l: l, r: r}
+	}
+	return l, err
+}
+
+func (p *parser) unary() (node, error) {
+	if p.peek() != "!" {
+		return p.compare()
+	}
+	p.pos++
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if x.kind() != kindBool {
+		return nil, fmt.Errorf("! needs a condition")
+	}
+	return not{x}, nil
+}
+
+func (p *parser) compare() (node, error) {
+	l, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return l, nil
+	}
+	p.pos++
+	r, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if l.kind() != r.kind() {
+		return nil, fmt.Errorf("can't compare %s with %s", l.kind(), r.kind())
+	}
+	if l.kind() == kindBool && op != "==" && op != "!=" {
+		return nil, fmt.Errorf("can't order conditions with %s", op)
+	}
+	return binary{op: op, l: l, r: r}, nil
+}
+
+func (p *parser) primary() (node, error) {
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("unexpected end")
+	}
+	tok := p.toks[p.pos]
+	p.pos++
+	switch {
+	case tok.lit != nil:
+		return literal{tok.lit}, nil
+	case tok.text == "(":
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return x, nil
+	case tok.text == "true" || tok.text == "false":
+		return literal{tok.text == "true"}, nil
+	}
+	if _, ok := variables[tok.text]; ok {
+		return variable(tok.text), nil
+	}
+	return nil, fmt.Errorf("unknown %q", tok.text)
+}
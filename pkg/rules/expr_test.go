@@ -0,0 +1,131 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// now is a Monday
+var now = time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC)
+
+var (
+	saturday  = scraper.Slot{Date: "06/07", Location: "府中試験場", Category: "外国免許切替"} // In 5 days
+	wednesday = scraper.Slot{Date: "06/04", Location: "鮫洲試験場", Category: "外国免許切替"} // In 2 days
+	july      = scraper.Slot{Date: "07/01", Location: "鮫洲試験場", Category: "再交付"}    // A Tuesday in 29 days
+)
+
+func TestCompile(t *testing.T) {
+	valid := []string{
+		"weekend",
+		"true",
+		"!weekend",
+		"!!weekend",
+		"days <= 30",
+		"weekend && days <= 30",
+		`days <= 7 || location == "府中試験場"`,
+		`weekday == 'sat' || weekday == "sun"`,
+		"(weekend || month == 7) && !(days > 20)",
+		"weekend == false",
+		"  days>=1&&days<=7  ",
+		"month != 12",
+		`category < "z"`,
+	}
+	for _, src := range valid {
+		t.Run(src, func(t *testing.T) {
+			e, err := Compile(src)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", src, err)
+			}
+			if e.String() != src {
+				t.Errorf("String() = %q, want %q", e.String(), src)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string // Part of the error
+	}{
+		{"", "unexpected end"},
+		{"days", "expected a condition"},
+		{`"sat"`, "expected a condition"},
+		{"days <=", "unexpected end"},
+		{"days <= 7 &&", "unexpected end"},
+		{"&& weekend", `unknown "&&"`},
+		{"weekend weekend", `unexpected "weekend"`},
+		{"(weekend", "missing )"},
+		{"weekend)", `unexpected ")"`},
+		{"holiday", `unknown "holiday"`},
+		{`location == "府中試験場`, "unterminated string"},
+		{"days = 7", `unexpected '='`},
+		{"days & weekend", `unexpected '&'`},
+		{`days == "7"`, "can't compare int with string"},
+		{"weekend == 1", "can't compare bool with int"},
+		{"weekend < true", "can't order conditions with <"},
+		{"!days", "! needs a condition"},
+		{"days && weekend", "&& needs conditions on both sides"},
+		{"weekend || month", "|| needs conditions on both sides"},
+		{"days < 7 < 9", `unexpected "<"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Compile(tt.src)
+			if err == nil {
+				t.Fatalf("Compile(%q) succeeded, want an error", tt.src)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile(%q) error = %v, want it to mention %s", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		src   string
+		slot  scraper.Slot
+		match bool
+	}{
+		{"weekend", saturday, true},
+		{"weekend", wednesday, false},
+		{"!weekend", wednesday, true},
+		{"days == 5", saturday, true},
+		{"days < 5", saturday, false},
+		{"days <= 30", july, true},
+		{"days > 30", july, false},
+		{"month == 7", july, true},
+		{"weekday == 'tue'", july, true},
+		{`weekday == "sat"`, wednesday, false},
+		{`location == "府中試験場"`, saturday, true},
+		{`location != "府中試験場"`, saturday, false},
+		{`category == "再交付"`, july, true},
+		{"weekend == false", wednesday, true},
+		// && binds tighter than ||
+		{"days <= 7 || weekend && month == 7", wednesday, true},
+		{"(days <= 7 || weekend) && month == 7", wednesday, false},
+		{"weekend && days <= 7 || month == 7", july, true},
+		{"weekend && (days <= 7 || month == 7)", july, false},
+		// ! binds tighter than &&
+		{"!weekend && days < 5", wednesday, true},
+		{"!(weekend && days < 5)", saturday, true},
+		{"!weekend && days < 5", saturday, false},
+		// A date that can't be read has no days, weekday or month
+		{"days == 0 && weekday == '' && !weekend", scraper.Slot{Date: "?"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := Compile(tt.src)
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.src, err)
+			}
+			if got := e.Match(tt.slot, now); got != tt.match {
+				t.Errorf("Match(%s %s) = %v, want %v", tt.slot.Date, tt.slot.Location, got, tt.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"policeScrapper/pkg/clock"
+	"policeScrapper/pkg/config"
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/scraper"
+)
+
+// Action is what a rule does with the slots it matches
+type Action string
+
+// Actions of rules
+const (
+	ActionNotify Action = "notify" // Notify immediately
+	ActionDigest Action = "digest" // Hold for the next digest
+	ActionDrop   Action = "drop"   // Don't notify
+)
+
+// Rule routes the slots matching a condition
+type Rule struct {
+	Name     string
+	When     *Expr // nil matches every slot
+	MinSlots int   // Slots the rule must match in one check to apply
+	Action   Action
+}
+
+// Parse compiles the configured rules
+func Parse(cfgs []config.Rule) ([]Rule, error) {
+	rules := make([]Rule, 0, len(cfgs))
+	for i, c := range cfgs {
+		r := Rule{Name: c.Name, MinSlots: c.MinSlots, Action: Action(c.Action)}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("#%d", i+1)
+		}
+		switch r.Action {
+		case ActionNotify, ActionDigest, ActionDrop:
+		case "":
+			r.Action = ActionNotify
+		default:
+			return nil, fmt.Errorf("rule %s: unknown action %q: expected notify, digest or drop", r.Name, c.Action)
+		}
+		if c.When != "" {
+			var err error
+			if r.When, err = Compile(c.When); err != nil {
+				return nil, fmt.Errorf("rule %s: %v", r.Name, err)
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Router applies rules to the slots of every check. Each slot is handled by
+// the first rule matching it whose MinSlots is reached; slots matching no
+// rule are dropped. Slots of critical targets skip the rules and are
+// notified immediately.
+type Router struct {
+	rules     []Rule
+	immediate notify.Notifier
+	digest    notify.Notifier
+}
+
+// NewRouter creates a router sending slots to immediate or digest
+func NewRouter(rules []Rule, immediate, digest notify.Notifier) *Router {
+	return &Router{rules: rules, immediate: immediate, digest: digest}
+}
+
+// NotifyAvailableSlots routes the slots according to the rules
+func (r *Router) NotifyAvailableSlots(slots []scraper.Slot) error {
+	now := clock.Now().In(config.JST)
+	var remaining, immediate, digest []scraper.Slot
+	for _, slot := range slots {
+		if slot.Critical {
+			immediate = append(immediate, slot)
+		} else {
+			remaining = append(remaining, slot)
+		}
+	}
+	if len(immediate) > 0 {
+		log.Printf("📏 Notifying %d critical slot(s) whatever the rules", len(immediate))
+	}
+	for _, rule := range r.rules {
+		var matched, rest []scraper.Slot
+		for _, slot := range remaining {
+			if rule.When == nil || rule.When.Match(slot, now) {
+				matched = append(matched, slot)
+			} else {
+				rest = append(rest, slot)
+			}
+		}
+		if len(matched) == 0 || len(matched) < rule.MinSlots {
+			continue
+		}
+		remaining = rest
+
+		log.Printf("📏 Rule %s: %s %d slot(s)", rule.Name, rule.Action, len(matched))
+		switch rule.Action {
+		case ActionNotify:
+			immediate = append(immediate, matched...)
+		case ActionDigest:
+			digest = append(digest, matched...)
+		}
+	}
+	if len(remaining) > 0 {
+		log.Printf("📏 %d slot(s) matched no rule, not notifying", len(remaining))
+	}
+
+	var errs []error
+	if len(immediate) > 0 {
+		errs = append(errs, r.immediate.NotifyAvailableSlots(immediate))
+	}
+	if len(digest) > 0 {
+		errs = append(errs, r.digest.NotifyAvailableSlots(digest))
+	}
+	return errors.Join(errs...)
+}
+
+// Alert forwards operational alerts, which rules don't apply to
+func (r *Router) Alert(text string) error {
+	if a, ok := r.immediate.(notify.Alerter); ok {
+		return a.Alert(text)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package scraper
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVHeader names the columns of WriteCSV. Like the JSON documents, columns
+// are only removed or changed with a new SchemaVersion, new ones are added
+// at the end.
+var CSVHeader = []string{"checked_at", "location", "category", "date", "times", "critical"}
+
+// WriteCSV writes the header and a row per slot, its time windows separated
+// by semicolons
+func (r CheckResult) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+	checkedAt := r.CheckedAt.Format(time.RFC3339)
+	for _, slot := range r.Slots {
+		row := []string{
+			checkedAt,
+			slot.Location,
+			slot.Category,
+			slot.Date,
+			strings.Join(slot.Times, ";"),
+			strconv.FormatBool(slot.Critical),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
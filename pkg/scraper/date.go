@@ -0,0 +1,88 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoSuchDate is returned by ParseDate for a date that doesn't exist in
+// the year it's put in, e.g. 02/29 out of leap years
+var ErrNoSuchDate = errors.New("no such date")
+
+// pastDays is how long slots of past days may still be listed
+const pastDays = 7
+
+// ParseDate returns the day of a slot date (MM/DD) as midnight UTC. Slot
+// dates have no year, so the year is the one putting the day within a year
+// from a few days ago, allowing for slots of past days still being listed.
+// Around new year that's next year for January in late December, and last
+// year for December in early January. A date that doesn't exist in that
+// year is an ErrNoSuchDate rather than the day after.
+func ParseDate(date string, now time.Time) (time.Time, error) {
+	d, err := time.Parse("01/02", date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -pastDays)
+	// The first year the month and day fall on or after from
+	year := from.Year()
+	if d.Month() < from.Month() || (d.Month() == from.Month() && d.Day() < from.Day()) {
+		year++
+	}
+	day := time.Date(year, d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+	if day.Day() != d.Day() {
+		// time.Date moved it on to the next month
+		return time.Time{}, fmt.Errorf("%s in %d: %w", date, year, ErrNoSuchDate)
+	}
+	return day, nil
+}
+
+// maxDateGap is the most days the header may skip from one column, or page,
+// to the next. The table lists consecutive weeks, so more means a wrong year.
+const maxDateGap = 31 * 24 * time.Hour
+
+// HeaderDate is the date of a column of the table's header
+type HeaderDate struct {
+	Column int    `json:"column"`
+	Date   string `json:"date"` // MM/DD
+}
+
+// CheckDateOrder checks that the header dates, in column order, go forward
+// from after, the last date of the previous page if not zero. Dates going
+// back, or jumping ahead by more than a month, mean the year was inferred
+// wrong, e.g. around new year, so slots may be misdated: they're still
+// reported, with a date_order warning, as are dates that don't exist in the
+// year inferred. It returns the last date, to check the next page from.
+func CheckDateOrder(dates []HeaderDate, after, now time.Time) (time.Time, []Warning) {
+	var warnings []Warning
+	last, lastDate := after, ""
+	for _, h := range dates {
+		day, err := ParseDate(h.Date, now)
+		if errors.Is(err, ErrNoSuchDate) {
+			warnings = append(warnings, Warning{
+				Code:    WarnDateOrder,
+				Message: fmt.Sprintf("Column %d date %s doesn't exist (%v), slot years may be wrong", h.Column, h.Date, err),
+				Column:  h.Column,
+			})
+			continue
+		}
+		if err != nil {
+			continue // Already warned about by the slot script
+		}
+		if !last.IsZero() && (day.Before(last) || day.Sub(last) > maxDateGap) {
+			previous := "the previous page"
+			if lastDate != "" {
+				previous = lastDate
+			}
+			warnings = append(warnings, Warning{
+				Code: WarnDateOrder,
+				Message: fmt.Sprintf("Column %d date %s (%s) doesn't follow %s (%s), slot years may be wrong",
+					h.Column, h.Date, day.Format("2006-01-02"), previous, last.Format("2006-01-02")),
+				Column: h.Column,
+			})
+		}
+		last, lastDate = day, h.Date
+	}
+	return last, warnings
+}
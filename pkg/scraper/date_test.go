@@ -0,0 +1,90 @@
+package scraper
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		name string
+		date string
+		now  time.Time
+		want string // YYYY-MM-DD, empty when it's an error
+		err  error
+	}{
+		{"same year", "06/15", day(2025, 6, 1), "2025-06-15", nil},
+		{"late December, January slot", "01/03", day(2025, 12, 28), "2026-01-03", nil},
+		{"early January, December slot", "12/30", day(2026, 1, 2), "2025-12-30", nil},
+		{"past day still listed", "05/28", day(2025, 6, 1), "2025-05-28", nil},
+		{"leap day in a leap year", "02/29", day(2028, 2, 1), "2028-02-29", nil},
+		{"leap day from the December before", "02/29", day(2027, 12, 30), "2028-02-29", nil},
+		{"leap day out of leap years", "02/29", day(2027, 2, 1), "", ErrNoSuchDate},
+		{"not a date", "2/3", day(2025, 6, 1), "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDate(tt.date, tt.now)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("ParseDate(%q) = %s, want an error", tt.date, got.Format("2006-01-02"))
+				}
+				if tt.err != nil && !errors.Is(err, tt.err) {
+					t.Fatalf("ParseDate(%q) error = %v, want %v", tt.date, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDate(%q) error = %v", tt.date, err)
+			}
+			if got.Format("2006-01-02") != tt.want {
+				t.Errorf("ParseDate(%q) = %s, want %s", tt.date, got.Format("2006-01-02"), tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDateOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		dates    []string
+		after    time.Time
+		now      time.Time
+		last     string // YYYY-MM-DD
+		warnings []int  // Columns warned about
+	}{
+		{"forward", []string{"06/02", "06/03", "06/04"}, time.Time{}, day(2025, 6, 1), "2025-06-04", nil},
+		{"across new year", []string{"12/30", "12/31", "01/01", "01/02"}, time.Time{}, day(2025, 12, 28), "2026-01-02", nil},
+		{"from the previous page", []string{"01/05", "01/06"}, day(2026, 1, 4), day(2025, 12, 28), "2026-01-06", nil},
+		{"backwards column", []string{"06/02", "06/04", "06/03", "06/05"}, time.Time{}, day(2025, 6, 1), "2025-06-05", []int{3}},
+		{"backwards from the previous page", []string{"06/01"}, day(2025, 6, 10), day(2025, 6, 1), "2025-06-01", []int{1}},
+		{"jump ahead", []string{"06/02", "09/02"}, time.Time{}, day(2025, 6, 1), "2025-09-02", []int{2}},
+		{"leap day out of leap years", []string{"02/28", "02/29", "03/01"}, time.Time{}, day(2027, 2, 25), "2027-03-01", []int{2}},
+		{"unparsed dates are skipped", []string{"06/02", "?", "06/04"}, time.Time{}, day(2025, 6, 1), "2025-06-04", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := make([]HeaderDate, len(tt.dates))
+			for i, d := range tt.dates {
+				headers[i] = HeaderDate{Column: i + 1, Date: d}
+			}
+			last, warnings := CheckDateOrder(headers, tt.after, tt.now)
+			if last.Format("2006-01-02") != tt.last {
+				t.Errorf("last = %s, want %s", last.Format("2006-01-02"), tt.last)
+			}
+			if len(warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %v, want columns %v", warnings, tt.warnings)
+			}
+			for i, w := range warnings {
+				if w.Code != WarnDateOrder || w.Column != tt.warnings[i] {
+					t.Errorf("warning %d = %s on column %d, want %s on column %d", i, w.Code, w.Column, WarnDateOrder, tt.warnings[i])
+				}
+			}
+		})
+	}
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 12, 0, 0, 0, time.UTC)
+}
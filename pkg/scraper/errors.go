@@ -0,0 +1,82 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Steps of a check, reported by StepError
+const (
+	StepLaunch     = "launch"     // Starting Chrome
+	StepSetup      = "setup"      // Preparing the browser tab
+	StepNavigate   = "navigate"   // Loading the reservation page
+	StepTableWait  = "table_wait" // Waiting for the availability table
+	StepParse      = "parse"      // Reading the slots of a page
+	StepPagination = "pagination" // Moving to the next weeks
+	StepWatchdog   = "watchdog"   // The whole check stopped responding
+	StepPanic      = "panic"      // The check panicked, see PanicError
+)
+
+// Error classes, used to pick a backoff policy and in status reporting
+const (
+	ClassTimeout = "timeout"
+	ClassError   = "error"
+)
+
+// StepError is a check failure at a given step
+type StepError struct {
+	Step      string
+	Err       error
+	Page      int    // Page being read, 0 before the table
+	Artifacts string // Directory of the page and screenshot the check left, if kept
+}
+
+// Error describes the failure and where it happened
+func (e *StepError) Error() string {
+	if e.Step == StepPanic {
+		return e.Err.Error()
+	}
+	if e.Timeout() {
+		return fmt.Sprintf("timed out during %s: %v", e.Step, e.Err)
+	}
+	return fmt.Sprintf("failed during %s: %v", e.Step, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the step failed because a deadline was exceeded
+func (e *StepError) Timeout() bool {
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// PanicError is a panic recovered during a check, with where it happened
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// Error describes the panic
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("check panicked: %v", e.Value)
+}
+
+// ErrorClass returns ClassTimeout for timeouts and ClassError otherwise
+func ErrorClass(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ClassTimeout
+	}
+	return ClassError
+}
+
+// ErrorStep returns the step a check failed at, or "" if unknown
+func ErrorStep(err error) string {
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		return stepErr.Step
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package scraper
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the JSON documents built from Slot and
+// CheckResult. It only changes on breaking changes (a field removed, renamed
+// or retyped); new fields may be added within a version, so consumers must
+// ignore fields they don't know.
+const SchemaVersion = 2
+
+// JSONSchema is the JSON Schema describing the current SchemaVersion
+//
+//go:embed schema/v2.json
+var JSONSchema []byte
+
+// CheckResult is the outcome of a single availability check
+type CheckResult struct {
+	SchemaVersion int            `json:"schema_version"`
+	CheckedAt     time.Time      `json:"checked_at"`
+	Slots         []Slot         `json:"slots"`
+	PagesChecked  int            `json:"pages_checked"`
+	Duration      time.Duration  `json:"duration_ns"`
+	StatusCounts  map[string]int `json:"status_counts,omitempty"` // Target cells by status ("available", "full", "closed")
+	Rows          []RowCounts    `json:"rows,omitempty"`          // Cells by status of each target row
+	Warnings      []Warning      `json:"warnings,omitempty"`      // Non-fatal problems noticed while parsing
+}
+
+// RowCounts counts the cells of one row of the table, a location and
+// category, by status over all pages checked
+type RowCounts struct {
+	Location string         `json:"location"`
+	Category string         `json:"category"`
+	Counts   map[string]int `json:"counts"`
+}
+
+// NewCheckResult creates a CheckResult stamped with the current schema version
+func NewCheckResult(checkedAt time.Time, slots []Slot) CheckResult {
+	if slots == nil {
+		slots = []Slot{}
+	}
+	return CheckResult{
+		SchemaVersion: SchemaVersion,
+		CheckedAt:     checkedAt,
+		Slots:         slots,
+	}
+}
+
+// Summary describes the result in one line
+func (r CheckResult) Summary() string {
+	if len(r.Slots) == 0 {
+		return fmt.Sprintf("No slots found (checked %d pages in %.1fs)", r.PagesChecked, r.Duration.Seconds())
+	}
+	return fmt.Sprintf("Found %d slots: %s (checked %d pages in %.1fs)",
+		len(r.Slots), strings.Join(SlotDates(r.Slots), ", "), r.PagesChecked, r.Duration.Seconds())
+}
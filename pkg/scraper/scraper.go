@@ -1,11 +1,23 @@
 package scraper
 
+import "strings"
+
 // Slot represents an available time slot
 type Slot struct {
-	Location  string `json:"location"`
-	Category  string `json:"category"`
-	Date      string `json:"date"`
-	Available bool   `json:"available"`
+	Location  string   `json:"location"`
+	Category  string   `json:"category"`
+	Date      string   `json:"date"`
+	Available bool     `json:"available"`
+	Times     []string `json:"times,omitempty"`    // Time windows, e.g. 09:00-10:00, if read
+	Critical  bool     `json:"critical,omitempty"` // Of a critical target, notified even in quiet hours
+}
+
+// When describes the slot's date, followed by its time windows if known
+func (s Slot) When() string {
+	if len(s.Times) == 0 {
+		return s.Date
+	}
+	return s.Date + " " + strings.Join(s.Times, ", ")
 }
 
 // SlotDates extracts dates from slots
@@ -0,0 +1,48 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Warning codes, stable identifiers consumers can match on
+const (
+	WarnTableMissing    = "table_missing"     // The availability table isn't on the page
+	WarnHeaderMissing   = "header_missing"    // The date header row isn't in the table
+	WarnDateParseFailed = "date_parse_failed" // A header cell has no recognizable MM/DD date
+	WarnDateMissing     = "date_missing"      // An available cell's column has no date
+	WarnStatusUnknown   = "status_unknown"    // A cell has a status mark we don't know
+	WarnTimesMissing    = "times_missing"     // A slot's time windows couldn't be read
+	WarnDateOrder       = "date_order"        // A header date comes before the previous column's
+	WarnPagesSkipped    = "pages_skipped"     // Pagination stopped before the last page
+)
+
+// Warning is a non-fatal problem noticed during a check, e.g. a header
+// date that couldn't be parsed. The check still succeeds, but its result
+// may be incomplete.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Page    int    `json:"page,omitempty"`   // Page of the table, from 1
+	Column  int    `json:"column,omitempty"` // Column of the table, if relevant
+}
+
+// String describes the warning in one line
+func (w Warning) String() string {
+	if w.Page > 0 {
+		return fmt.Sprintf("page %d: %s", w.Page, w.Message)
+	}
+	return w.Message
+}
+
+// UnmarshalJSON also accepts the plain strings of schema version 1, so
+// results recorded before version 2 can still be read
+func (w *Warning) UnmarshalJSON(data []byte) error {
+	var message string
+	if err := json.Unmarshal(data, &message); err == nil {
+		*w = Warning{Message: message}
+		return nil
+	}
+	type warning Warning
+	return json.Unmarshal(data, (*warning)(w))
+}
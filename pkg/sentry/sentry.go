@@ -0,0 +1,155 @@
+package sentry
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Levels of events
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// Client reports errors to Sentry, or a tracker with the same API such as
+// GlitchTip, through its store endpoint
+type Client struct {
+	storeURL    string
+	key         string
+	environment string
+	release     string
+	client      *http.Client
+}
+
+// Event is an error to report
+type Event struct {
+	Message     string
+	Type        string // Kind of error, e.g. the step a check failed at
+	Level       string // LevelError if empty
+	Tags        map[string]string
+	Extra       map[string]interface{}
+	Fingerprint []string // Groups events into issues, by message if empty
+}
+
+// New creates a client for the project of dsn, e.g.
+// https://<key>@o1.ingest.sentry.io/<project>. Events are labelled with the
+// environment and release, if set.
+func New(dsn, environment, release string) (*Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %v", err)
+	}
+	key := u.User.Username()
+	i := strings.LastIndex(u.Path, "/")
+	if u.Scheme == "" || u.Host == "" || key == "" || i < 0 || u.Path[i+1:] == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: expected https://<key>@<host>/<project>")
+	}
+	project := u.Path[i+1:]
+	return &Client{
+		storeURL:    fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, u.Path[:i], project),
+		key:         key,
+		environment: environment,
+		release:     release,
+		client:      &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+// Capture sends the event
+func (c *Client) Capture(e Event) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to create event ID: %v", err)
+	}
+	level := e.Level
+	if level == "" {
+		level = LevelError
+	}
+	hostname, _ := os.Hostname()
+	payload := map[string]interface{}{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		"platform":    "go",
+		"logger":      "scraper",
+		"level":       level,
+		"server_name": hostname,
+		"message":     e.Message,
+		"exception": map[string]interface{}{
+			"values": []interface{}{map[string]interface{}{"type": e.Type, "value": e.Message}},
+		},
+	}
+	if c.environment != "" {
+		payload["environment"] = c.environment
+	}
+	if c.release != "" {
+		payload["release"] = c.release
+	}
+	if len(e.Tags) > 0 {
+		payload["tags"] = e.Tags
+	}
+	if len(e.Extra) > 0 {
+		payload["extra"] = e.Extra
+	}
+	if len(e.Fingerprint) > 0 {
+		payload["fingerprint"] = e.Fingerprint
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, c.storeURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=policeScrapper/1.0, sentry_key=%s", c.key))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Sentry event: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sentry event failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Go runs fn in a new goroutine, reporting its panics like Recover, as a
+// deferred Recover only covers the goroutine deferring it
+func (c *Client) Go(fn func()) {
+	go func() {
+		defer c.Recover()
+		fn()
+	}()
+}
+
+// Recover reports a panic of the calling goroutine before letting it crash
+// the process, when deferred. It does nothing on a nil client.
+func (c *Client) Recover() {
+	if c == nil {
+		return
+	}
+	if r := recover(); r != nil {
+		err := c.Capture(Event{
+			Message: fmt.Sprint(r),
+			Type:    "panic",
+			Level:   LevelFatal,
+			Extra:   map[string]interface{}{"stack": string(debug.Stack())},
+		})
+		if err != nil {
+			log.Printf("Error reporting the panic: %v", err)
+		}
+		panic(r)
+	}
+}
@@ -0,0 +1,41 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRequestAge is how old a signed request may be, against replays
+const maxRequestAge = 5 * time.Minute
+
+// Response answers a slash command, see
+// https://api.slack.com/interactivity/slash-commands#responding_to_commands
+type Response struct {
+	ResponseType string `json:"response_type"` // ephemeral, only seen by the user, or in_channel
+	Text         string `json:"text"`
+}
+
+// VerifyRequest checks the X-Slack-Signature header of a request, the hex
+// HMAC-SHA256 of "v0:<timestamp>:<body>" keyed with the app's signing
+// secret, and that the X-Slack-Request-Timestamp is recent
+func VerifyRequest(signingSecret string, body []byte, timestamp, signature string, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > maxRequestAge || age < -maxRequestAge {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "v0="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,130 @@
+package snooze
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// Entry is a snoozed slot date
+type Entry struct {
+	Date  string    `json:"date"`
+	Until time.Time `json:"until"`
+}
+
+// List holds snoozed dates, persisted to a JSON file so they survive restarts
+type List struct {
+	path string
+
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// Load reads the snooze list from path, starting empty if it doesn't exist
+func Load(path string) (*List, error) {
+	l := &List{path: path, until: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	for _, e := range entries {
+		l.until[e.Date] = e.Until
+	}
+	return l, nil
+}
+
+// Snooze stops notifications about a date (MM/DD) for the given duration
+func (l *List) Snooze(date string, d time.Duration) (Entry, error) {
+	if _, err := time.Parse("01/02", date); err != nil {
+		return Entry{}, fmt.Errorf("invalid date %q: expected MM/DD", date)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := Entry{Date: date, Until: time.Now().Add(d)}
+	l.until[date] = e.Until
+	return e, l.save()
+}
+
+// Unsnooze resumes notifications about a date
+func (l *List) Unsnooze(date string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.until, date)
+	return l.save()
+}
+
+// Entries returns the active snoozes sorted by date
+func (l *List) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	entries := []Entry{}
+	for date, until := range l.until {
+		if until.After(now) {
+			entries = append(entries, Entry{Date: date, Until: until})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Date < entries[j].Date })
+	return entries
+}
+
+// Filter returns the slots whose dates aren't snoozed
+func (l *List) Filter(slots []scraper.Slot) []scraper.Slot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var kept []scraper.Slot
+	for _, slot := range slots {
+		if until, ok := l.until[slot.Date]; ok && until.After(now) {
+			continue
+		}
+		kept = append(kept, slot)
+	}
+	return kept
+}
+
+// save writes the active snoozes, dropping expired ones. Callers hold mu.
+func (l *List) save() error {
+	now := time.Now()
+	entries := []Entry{}
+	for date, until := range l.until {
+		if !until.After(now) {
+			delete(l.until, date)
+			continue
+		}
+		entries = append(entries, Entry{Date: date, Until: until})
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0750); err != nil {
+		return err
+	}
+	// Replace the file atomically so backups never read it half-written
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
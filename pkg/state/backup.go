@@ -0,0 +1,104 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupPrefix starts the file names of scheduled backups
+const backupPrefix = "state-"
+
+// Backups periodically snapshots the state directory into archives that
+// can be restored with Import, keeping only the most recent ones
+type Backups struct {
+	Dir       string                   // State directory to back up
+	BackupDir string                   // Directory receiving the archives
+	Keep      int                      // Number of archives to keep, 0 keeps all
+	Env       func() map[string]string // Configuration to include, if set
+}
+
+// Run backs up now and then every interval until ctx is cancelled
+func (b *Backups) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if path, err := b.Backup(); err != nil {
+			log.Printf("❌ State backup failed: %v", err)
+		} else {
+			log.Printf("💾 State backed up to %s", path)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Backup writes a new archive and removes the ones beyond Keep
+func (b *Backups) Backup() (string, error) {
+	if err := os.MkdirAll(b.BackupDir, 0750); err != nil {
+		return "", err
+	}
+
+	var env map[string]string
+	if b.Env != nil {
+		env = b.Env()
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated
+	// archive among the backups
+	name := backupPrefix + time.Now().Format("20060102-150405") + ".tar.gz"
+	path := filepath.Join(b.BackupDir, name)
+	tmp, err := os.CreateTemp(b.BackupDir, ".tmp-"+backupPrefix)
+	if err != nil {
+		return "", err
+	}
+	err = Export(tmp, b.Dir, env)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return path, b.rotate()
+}
+
+// rotate removes the oldest archives beyond Keep
+func (b *Backups) rotate() error {
+	if b.Keep <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(b.BackupDir)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasPrefix(e.Name(), backupPrefix) {
+			names = append(names, e.Name())
+		}
+	}
+	// Timestamped names sort oldest first
+	sort.Strings(names)
+	for len(names) > b.Keep {
+		if err := os.Remove(filepath.Join(b.BackupDir, names[0])); err != nil {
+			return fmt.Errorf("failed to remove old backup: %v", err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package state
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	// SQLite driver for database/sql, to snapshot the store
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ConfigFile is the archive entry holding the exported configuration, as
+// shell exports that can be sourced on the new server
+const ConfigFile = "config.env"
+
+// stateDir is the archive directory holding the state files
+const stateDir = "state/"
+
+// Export writes a gzipped tar archive of every file in dir, plus the
+// configuration environment, so the scraper can move to another server
+func Export(w io.Writer, dir string, env map[string]string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := writeEntry(tw, ConfigFile, []byte(formatEnv(env))); err != nil {
+		return err
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == ConfigFile {
+			// A previous import, superseded by the current environment
+			return nil
+		}
+		if isJournal(rel) {
+			// Belongs to a database, whose snapshot is consistent without it
+			return nil
+		}
+		data, err := readState(p)
+		if err != nil {
+			return err
+		}
+		return writeEntry(tw, stateDir+filepath.ToSlash(rel), data)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Import extracts an archive written by Export into dir, replacing existing
+// files. The configuration is written to dir/config.env. It returns the
+// names of the restored files.
+func Import(r io.Reader, dir string) ([]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a state archive: %v", err)
+	}
+	defer gz.Close()
+
+	var restored []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return restored, fmt.Errorf("failed to read archive: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if name != ConfigFile {
+			if !strings.HasPrefix(name, stateDir) {
+				return restored, fmt.Errorf("unexpected archive entry %q", hdr.Name)
+			}
+			name = strings.TrimPrefix(name, stateDir)
+		}
+		if !filepath.IsLocal(name) {
+			return restored, fmt.Errorf("unsafe archive entry %q", hdr.Name)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+			return restored, err
+		}
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600) // #nosec G304 - path is checked by filepath.IsLocal
+		if err != nil {
+			return restored, err
+		}
+		_, err = io.Copy(f, tr) // #nosec G110 - archives are our own exports
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return restored, fmt.Errorf("failed to write %s: %v", target, err)
+		}
+		restored = append(restored, target)
+	}
+	return restored, nil
+}
+
+// sqliteHeader starts every SQLite database file
+const sqliteHeader = "SQLite format 3\x00"
+
+// isJournal reports whether a file is the rollback journal or write-ahead
+// log of a SQLite database
+func isJournal(name string) bool {
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// readState reads a state file. SQLite databases, like the store's, may be
+// written while they're read, so they're read from a snapshot taken with
+// VACUUM INTO instead of as they are on disk.
+func readState(p string) ([]byte, error) {
+	data, err := os.ReadFile(p) // #nosec G304 - walking the state directory
+	if err != nil || !strings.HasPrefix(string(data), sqliteHeader) {
+		return data, err
+	}
+
+	dir, err := os.MkdirTemp("", "scraper-snapshot-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, filepath.Base(p))
+
+	db, err := sql.Open("sqlite3", p)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	if _, err := db.Exec(`VACUUM INTO ?`, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to snapshot %s: %v", p, err)
+	}
+	return os.ReadFile(snapshot) // #nosec G304 - our own temporary file
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// formatEnv renders the environment as sorted, quoted shell exports
+func formatEnv(env map[string]string) string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "export %s='%s'\n", k, strings.ReplaceAll(env[k], "'", `'\''`))
+	}
+	return sb.String()
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/history"
+	"policeScrapper/pkg/scraper"
+)
+
+// Memory is a Store keeping everything in memory, for one-off runs like
+// --once where nothing needs to outlive the process
+type Memory struct {
+	mu     sync.Mutex
+	checks []scraper.CheckResult
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// RecordCheck appends a check. Failed checks aren't kept, as Checks only
+// returns successful ones.
+func (m *Memory) RecordCheck(result scraper.CheckResult, checkErr error) error {
+	if checkErr != nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks = append(m.checks, result)
+	return nil
+}
+
+// Checks returns a page of the successful checks matching the filter,
+// newest first, and the total number of matches
+func (m *Memory) Checks(f history.Filter, offset, limit int) ([]scraper.CheckResult, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	matched := []scraper.CheckResult{}
+	for i := len(m.checks) - 1; i >= 0; i-- {
+		if r, ok := f.Match(m.checks[i]); ok {
+			matched = append(matched, r)
+		}
+	}
+	lo, hi := history.PageRange(len(matched), offset, limit)
+	return matched[lo:hi], len(matched), nil
+}
+
+// Slots returns a page of the slots seen by successful checks matching the
+// filter, latest first, and the total number of matches
+func (m *Memory) Slots(f history.Filter, offset, limit int) ([]history.SlotRecord, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs := history.NewSlotRuns(f)
+	for _, r := range m.checks {
+		runs.Add(r.CheckedAt, r.Slots)
+	}
+	records, total := runs.Page(offset, limit)
+	return records, total, nil
+}
+
+// Prune removes the checks recorded before before, returning how many
+func (m *Memory) Prune(before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.checks[:0]
+	for _, c := range m.checks {
+		if !c.CheckedAt.Before(before) {
+			kept = append(kept, c)
+		}
+	}
+	pruned := len(m.checks) - len(kept)
+	m.checks = kept
+	return pruned, nil
+}
+
+// Close does nothing, there's nothing to release
+func (m *Memory) Close() error {
+	return nil
+}
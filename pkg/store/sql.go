@@ -0,0 +1,339 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"policeScrapper/pkg/history"
+	"policeScrapper/pkg/scraper"
+
+	// Postgres driver for database/sql
+	_ "github.com/lib/pq"
+	// SQLite driver for database/sql
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// dialect holds what differs between the SQL databases
+type dialect struct {
+	driver string
+	schema []string
+}
+
+var sqlite = dialect{
+	driver: "sqlite3",
+	schema: []string{
+		`CREATE TABLE IF NOT EXISTS scraper_checks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			instance TEXT NOT NULL DEFAULT '',
+			checked_at TIMESTAMP NOT NULL,
+			pages_checked INTEGER NOT NULL,
+			duration_ns INTEGER NOT NULL,
+			slots_found INTEGER NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			error_class TEXT NOT NULL DEFAULT '',
+			error_step TEXT NOT NULL DEFAULT '',
+			result TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS scraper_checks_checked_at ON scraper_checks (checked_at)`,
+		`CREATE TABLE IF NOT EXISTS scraper_check_rows (
+			check_id INTEGER NOT NULL REFERENCES scraper_checks (id),
+			location TEXT NOT NULL,
+			category TEXT NOT NULL,
+			status TEXT NOT NULL,
+			cells INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS scraper_check_rows_check_id ON scraper_check_rows (check_id)`,
+		`CREATE TABLE IF NOT EXISTS scraper_check_slots (
+			check_id INTEGER NOT NULL REFERENCES scraper_checks (id),
+			location TEXT NOT NULL,
+			category TEXT NOT NULL,
+			date TEXT NOT NULL,
+			times TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS scraper_check_slots_check_id ON scraper_check_slots (check_id)`,
+	},
+}
+
+var postgres = dialect{
+	driver: "postgres",
+	schema: []string{
+		`CREATE TABLE IF NOT EXISTS scraper_checks (
+			id BIGSERIAL PRIMARY KEY,
+			instance TEXT NOT NULL DEFAULT '',
+			checked_at TIMESTAMPTZ NOT NULL,
+			pages_checked INTEGER NOT NULL,
+			duration_ns BIGINT NOT NULL,
+			slots_found INTEGER NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			error_class TEXT NOT NULL DEFAULT '',
+			error_step TEXT NOT NULL DEFAULT '',
+			result TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS scraper_checks_checked_at ON scraper_checks (checked_at)`,
+		`CREATE TABLE IF NOT EXISTS scraper_check_rows (
+			check_id BIGINT NOT NULL REFERENCES scraper_checks (id),
+			location TEXT NOT NULL,
+			category TEXT NOT NULL,
+			status TEXT NOT NULL,
+			cells INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS scraper_check_rows_check_id ON scraper_check_rows (check_id)`,
+		`CREATE TABLE IF NOT EXISTS scraper_check_slots (
+			check_id BIGINT NOT NULL REFERENCES scraper_checks (id),
+			location TEXT NOT NULL,
+			category TEXT NOT NULL,
+			date TEXT NOT NULL,
+			times TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS scraper_check_slots_check_id ON scraper_check_slots (check_id)`,
+	},
+}
+
+// SQL is a Store in a SQLite or Postgres database. Check results are kept
+// whole as JSON, next to columns to query them by.
+type SQL struct {
+	db       *sql.DB
+	dialect  dialect
+	instance string // Recorded with each check, as instances may share the database
+}
+
+// maxPostgresConns caps the connections of each instance, so several
+// instances and a dashboard fit in a small Postgres server's limit
+const maxPostgresConns = 4
+
+// openSQL connects to the database and creates the tables if needed
+func openSQL(d dialect, dsn, instance string) (*SQL, error) {
+	if d.driver == sqlite.driver {
+		if err := os.MkdirAll(filepath.Dir(dsn), 0750); err != nil {
+			return nil, err
+		}
+		// Wait for other connections rather than fail when the file is locked
+		dsn += "?_busy_timeout=5000"
+	}
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if d.driver == postgres.driver {
+		db.SetMaxOpenConns(maxPostgresConns)
+	}
+	for _, stmt := range d.schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create tables: %v", err)
+		}
+	}
+	return &SQL{db: db, dialect: d, instance: instance}, nil
+}
+
+// RecordCheck appends a check, failed if checkErr is set. Besides the
+// whole result, its slots and the cells of each row by status go to tables
+// of their own, to query them in SQL.
+func (s *SQL) RecordCheck(result scraper.CheckResult, checkErr error) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	var message, class, step string
+	if checkErr != nil {
+		message, class, step = checkErr.Error(), scraper.ErrorClass(checkErr), scraper.ErrorStep(checkErr)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op once committed
+
+	var id int64
+	err = tx.QueryRow(s.bind(`INSERT INTO scraper_checks
+		(instance, checked_at, pages_checked, duration_ns, slots_found, error, error_class, error_step, result)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		s.instance, result.CheckedAt.UTC(), result.PagesChecked, int64(result.Duration), len(result.Slots),
+		message, class, step, string(data)).Scan(&id)
+	if err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		for status, n := range row.Counts {
+			if _, err := tx.Exec(s.bind(`INSERT INTO scraper_check_rows (check_id, location, category, status, cells)
+				VALUES (?, ?, ?, ?, ?)`), id, row.Location, row.Category, status, n); err != nil {
+				return err
+			}
+		}
+	}
+	for _, slot := range result.Slots {
+		if _, err := tx.Exec(s.bind(`INSERT INTO scraper_check_slots (check_id, location, category, date, times)
+			VALUES (?, ?, ?, ?, ?)`), id, slot.Location, slot.Category, slot.Date, strings.Join(slot.Times, ",")); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Checks returns a page of the successful checks matching the filter,
+// newest first, and the total number of matches. The database pages them,
+// the slots of each being narrowed to the filter's location and category.
+func (s *SQL) Checks(f history.Filter, offset, limit int) ([]scraper.CheckResult, int, error) {
+	where := []string{"error = ''"}
+	var args []interface{}
+	if !f.From.IsZero() {
+		where = append(where, "checked_at >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		where = append(where, "checked_at < ?")
+		args = append(args, f.To.UTC())
+	}
+	if f.Status == history.StatusFound || f.Status == history.StatusEmpty {
+		found := "SELECT 1 FROM scraper_check_slots s WHERE s.check_id = scraper_checks.id"
+		if f.Location != "" {
+			found += " AND s.location = ?"
+			args = append(args, f.Location)
+		}
+		if f.Category != "" {
+			found += " AND s.category = ?"
+			args = append(args, f.Category)
+		}
+		if f.Status == history.StatusFound {
+			where = append(where, "EXISTS ("+found+")")
+		} else {
+			where = append(where, "NOT EXISTS ("+found+")")
+		}
+	}
+	cond := " WHERE " + strings.Join(where, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(s.bind(`SELECT COUNT(*) FROM scraper_checks`+cond), args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(s.bind(`SELECT result FROM scraper_checks`+cond+` ORDER BY checked_at DESC, id DESC LIMIT ? OFFSET ?`),
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	checks := []scraper.CheckResult{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, 0, err
+		}
+		var result scraper.CheckResult
+		if err := json.Unmarshal([]byte(data), &result); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse check: %v", err)
+		}
+		result, _ = f.Match(result)
+		checks = append(checks, result)
+	}
+	return checks, total, rows.Err()
+}
+
+// Slots returns a page of the slots seen by successful checks matching the
+// filter, latest first, and the total number of matches. The checks are
+// streamed from the database, only their slots being read.
+func (s *SQL) Slots(f history.Filter, offset, limit int) ([]history.SlotRecord, int, error) {
+	join := "s.check_id = c.id"
+	var args []interface{}
+	if f.Location != "" {
+		join += " AND s.location = ?"
+		args = append(args, f.Location)
+	}
+	if f.Category != "" {
+		join += " AND s.category = ?"
+		args = append(args, f.Category)
+	}
+	rows, err := s.db.Query(s.bind(`SELECT c.id, c.checked_at, s.location, s.category, s.date
+		FROM scraper_checks c LEFT JOIN scraper_check_slots s ON `+join+`
+		WHERE c.error = '' ORDER BY c.checked_at, c.id`), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	runs := history.NewSlotRuns(f)
+	var id int64 = -1
+	var checkedAt time.Time
+	var slots []scraper.Slot
+	for rows.Next() {
+		var rowID int64
+		var at time.Time
+		var location, category, date sql.NullString
+		if err := rows.Scan(&rowID, &at, &location, &category, &date); err != nil {
+			return nil, 0, err
+		}
+		if rowID != id {
+			if id >= 0 {
+				runs.Add(checkedAt, slots)
+			}
+			id, checkedAt, slots = rowID, at, nil
+		}
+		if location.Valid {
+			slots = append(slots, scraper.Slot{Location: location.String, Category: category.String, Date: date.String})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if id >= 0 {
+		runs.Add(checkedAt, slots)
+	}
+	records, total := runs.Page(offset, limit)
+	return records, total, nil
+}
+
+// Prune removes the checks recorded before before, with their rows and
+// slots, returning how many
+func (s *SQL) Prune(before time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op once committed
+
+	old := `SELECT id FROM scraper_checks WHERE checked_at < ?`
+	for _, table := range []string{"scraper_check_rows", "scraper_check_slots"} {
+		if _, err := tx.Exec(s.bind(`DELETE FROM `+table+` WHERE check_id IN (`+old+`)`), before.UTC()); err != nil {
+			return 0, err
+		}
+	}
+	res, err := tx.Exec(s.bind(`DELETE FROM scraper_checks WHERE checked_at < ?`), before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// Close closes the database
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
+
+// bind rewrites the ? placeholders of a query as the database expects them
+func (s *SQL) bind(query string) string {
+	if s.dialect.driver != postgres.driver {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
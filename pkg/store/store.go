@@ -0,0 +1,53 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"policeScrapper/pkg/history"
+	"policeScrapper/pkg/scraper"
+)
+
+// Store records the checks, so their history can be queried whatever the
+// backend: SQLite on a single machine, Postgres for instances sharing a
+// database, or memory for one-off runs.
+type Store interface {
+	// RecordCheck appends a check, failed if checkErr is set, in which case
+	// the result only has its time
+	RecordCheck(result scraper.CheckResult, checkErr error) error
+	// Checks returns a page of the successful checks matching the filter,
+	// newest first, and the total number of matches
+	Checks(f history.Filter, offset, limit int) ([]scraper.CheckResult, int, error)
+	// Slots returns a page of the slots seen by successful checks matching
+	// the filter, latest first, and the total number of matches
+	Slots(f history.Filter, offset, limit int) ([]history.SlotRecord, int, error)
+	// Prune removes the checks recorded before before, returning how many
+	Prune(before time.Time) (int, error)
+
+	Close() error
+}
+
+// Open opens the store at url, recording checks as done by instance:
+//
+//	memory:                     in memory, gone when the program exits
+//	sqlite:state/scraper.db     SQLite database file
+//	postgres://user@host/db     Postgres database, by URL
+//	postgres:host=db dbname=x   Postgres database, by key=value DSN
+//
+// Instances sharing a Postgres database each record their checks, telling
+// them apart by instance.
+func Open(url, instance string) (Store, error) {
+	switch {
+	case url == "memory:":
+		return NewMemory(), nil
+	case strings.HasPrefix(url, "sqlite:"):
+		return openSQL(sqlite, strings.TrimPrefix(url, "sqlite:"), instance)
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		return openSQL(postgres, url, instance)
+	case strings.HasPrefix(url, "postgres:"):
+		return openSQL(postgres, strings.TrimPrefix(url, "postgres:"), instance)
+	default:
+		return nil, fmt.Errorf("unknown store %q: expected memory:, sqlite:<path> or postgres://", url)
+	}
+}
@@ -0,0 +1,97 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notifier tells systemd the state of the service (sd_notify), for units of
+// Type=notify, and pings its watchdog for units with WatchdogSec
+type Notifier struct {
+	addr     *net.UnixAddr
+	watchdog time.Duration // How often to ping the watchdog, zero without one
+}
+
+// FromEnv returns the notifier of the socket systemd passed in
+// NOTIFY_SOCKET, nil when not started by systemd or without Type=notify
+func FromEnv() *Notifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		// Abstract socket
+		socket = "\x00" + socket[1:]
+	}
+	n := &Notifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+
+	// WATCHDOG_PID tells which process the watchdog is for, when set
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n
+	}
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		// Ping twice per timeout, as systemd recommends
+		n.watchdog = time.Duration(usec) * time.Microsecond / 2
+	}
+	return n
+}
+
+// Send sends state lines, e.g. READY=1 or STATUS=..., to systemd. It does
+// nothing on a nil notifier.
+func (n *Notifier) Send(state ...string) error {
+	if n == nil {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		return fmt.Errorf("failed to reach systemd: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(state, "\n"))); err != nil {
+		return fmt.Errorf("failed to notify systemd: %v", err)
+	}
+	return nil
+}
+
+// Watchdog pings systemd's watchdog while live reports the service is
+// working, until ctx is cancelled. Once it isn't, the pings stop and systemd
+// restarts the service when the watchdog times out. It returns right away
+// without a watchdog.
+func (n *Notifier) Watchdog(ctx context.Context, live func() (bool, string)) {
+	if n == nil || n.watchdog == 0 {
+		return
+	}
+	log.Printf("🐕 Pinging the systemd watchdog every %s", n.watchdog)
+	ticker := time.NewTicker(n.watchdog)
+	defer ticker.Stop()
+	pinging := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, reason := live()
+		switch {
+		case ok:
+			if !pinging {
+				log.Printf("🐕 Pinging the systemd watchdog again")
+			}
+			if err := n.Send("WATCHDOG=1"); err != nil {
+				log.Printf("Error pinging the systemd watchdog: %v", err)
+			}
+		case pinging:
+			log.Printf("🐕 Not pinging the systemd watchdog any more, systemd will restart the service: %s", reason)
+			if err := n.Send("STATUS=Wedged: " + reason); err != nil {
+				log.Printf("Error notifying systemd: %v", err)
+			}
+		}
+		pinging = ok
+	}
+}
@@ -0,0 +1,140 @@
+package tablewatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/config"
+)
+
+// Alert limits, so the diff fits in a chat message
+const (
+	maxDiffLines = 60
+	maxDiffBytes = 3000
+)
+
+// Snapshot is the raw text of the table at one check
+type Snapshot struct {
+	TakenAt time.Time `json:"taken_at"`
+	Text    string    `json:"text"`
+}
+
+// state is what's persisted
+type state struct {
+	Good      *Snapshot `json:"good,omitempty"`       // Last check whose table was parsed into rows
+	ZeroSince time.Time `json:"zero_since,omitempty"` // First check without rows since, zero if parsed fine
+	Alerted   bool      `json:"alerted,omitempty"`    // Whether the current run without rows was alerted
+}
+
+// Watch notices when the parser keeps finding no rows in the table, as if
+// the site's layout changed, and then tells how the raw table text differs
+// from the last check that parsed fine, to fix the selectors from. It's
+// persisted to a JSON file so the period without rows spans restarts.
+type Watch struct {
+	path  string
+	after time.Duration
+
+	mu    sync.Mutex
+	state state
+}
+
+// Load reads the watch's state from path, starting without a snapshot if it
+// doesn't exist. Checks finding no rows for after are alerted.
+func Load(path string, after time.Duration) (*Watch, error) {
+	w := &Watch{path: path, after: after}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &w.state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return w, nil
+}
+
+// Observe records a successful check done at t, whether its table was
+// parsed into rows, and the table's raw text. It returns the alert to send
+// once checks have found no rows for the watch's period, once per period.
+func (w *Watch) Observe(parsed bool, text string, t time.Time) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if parsed {
+		good := w.state.Good
+		if text != "" {
+			good = &Snapshot{TakenAt: t, Text: text}
+		}
+		w.state = state{Good: good}
+		return "", w.save()
+	}
+	if w.state.ZeroSince.IsZero() {
+		w.state.ZeroSince = t
+		return "", w.save()
+	}
+	if w.state.Alerted || t.Sub(w.state.ZeroSince) < w.after {
+		return "", nil
+	}
+	w.state.Alerted = true
+	return w.alert(text), w.save()
+}
+
+// alert describes the period without rows, with the table text diff.
+// Callers hold mu.
+func (w *Watch) alert(text string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚠️ The parser has found no rows in the table since %s. ",
+		w.state.ZeroSince.In(config.JST).Format("01/02 15:04"))
+	switch {
+	case text == "":
+		b.WriteString("The table's text couldn't be read either, the page may not load at all.")
+	case w.state.Good == nil || w.state.Good.Text == "":
+		b.WriteString("There's no good check to compare with, the table's text is now:\n")
+		b.WriteString(truncate(strings.Split(text, "\n")))
+	default:
+		fmt.Fprintf(&b, "Table text changes since the last good check (%s):\n",
+			w.state.Good.TakenAt.In(config.JST).Format("01/02 15:04"))
+		b.WriteString(truncate(Diff(w.state.Good.Text, text)))
+	}
+	return b.String()
+}
+
+// truncate joins lines up to the alert limits
+func truncate(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i == maxDiffLines || b.Len()+len(line) > maxDiffBytes {
+			fmt.Fprintf(&b, "… %d more lines", len(lines)-i)
+			break
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// save writes the state. Callers hold mu.
+func (w *Watch) save() error {
+	data, err := json.MarshalIndent(w.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0750); err != nil {
+		return err
+	}
+	// Replace the file atomically so backups never read it half-written
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.path)
+}
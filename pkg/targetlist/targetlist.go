@@ -0,0 +1,99 @@
+package targetlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"policeScrapper/pkg/config"
+)
+
+// State is the targets changed at runtime
+type State struct {
+	Targets   []config.Target `json:"targets"`
+	ChangedAt time.Time       `json:"changed_at"`
+}
+
+// List remembers the targets changed at runtime, e.g. through the control
+// API, so they replace the configured ones across restarts until reset.
+// It's persisted to a JSON file.
+type List struct {
+	path string
+
+	mu    sync.Mutex
+	state State
+}
+
+// Load reads the targets from path, starting unchanged if it doesn't exist
+func Load(path string) (*List, error) {
+	l := &List{path: path}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &l.state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if err := config.ValidateTargets(l.state.Targets); err != nil {
+		return nil, fmt.Errorf("invalid targets in %s: %v", path, err)
+	}
+	return l, nil
+}
+
+// Targets returns the targets changed at runtime, nil if they weren't
+func (l *List) Targets() []config.Target {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state.Targets
+}
+
+// State returns the current state
+func (l *List) State() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
+
+// Set records the targets
+func (l *List) Set(targets []config.Target) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.state = State{Targets: targets, ChangedAt: time.Now()}
+	return l.save()
+}
+
+// Clear goes back to the configured targets
+func (l *List) Clear() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.state = State{}
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// save writes the state. Callers hold mu.
+func (l *List) save() error {
+	data, err := json.MarshalIndent(l.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0750); err != nil {
+		return err
+	}
+	// Replace the file atomically so backups never read it half-written
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
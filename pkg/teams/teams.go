@@ -0,0 +1,163 @@
+package teams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/scraper"
+)
+
+// Client posts notifications to a Microsoft Teams incoming webhook
+type Client struct {
+	webhookURL string
+	noNotify   bool
+	client     *http.Client
+}
+
+// NewClient creates a new Teams client
+func NewClient(webhookURL string, noNotify bool) *Client {
+	return &Client{
+		webhookURL: webhookURL,
+		noNotify:   noNotify,
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// NotifyAvailableSlots posts an Adaptive Card listing the slots
+func (c *Client) NotifyAvailableSlots(slots []scraper.Slot) error {
+	if len(slots) == 0 {
+		return nil
+	}
+
+	if c.noNotify {
+		log.Println("👔 Teams message skipped (--no-notify)")
+		return nil
+	}
+
+	if err := c.send(createSlotCard(slots)); err != nil {
+		return err
+	}
+	log.Printf("👔 Teams message sent")
+	return nil
+}
+
+// Alert posts a plain text card, used for operational alerts
+func (c *Client) Alert(text string) error {
+	if c.noNotify {
+		log.Printf("👔 Alert skipped (--no-notify): %s", text)
+		return nil
+	}
+
+	return c.send(card([]interface{}{
+		map[string]interface{}{
+			"type": "TextBlock",
+			"text": text,
+			"wrap": true,
+		},
+	}, nil))
+}
+
+// createSlotCard lays the slots out like the LINE flex message: a header,
+// one block per slot and a button to the reservation page
+func createSlotCard(slots []scraper.Slot) map[string]interface{} {
+	body := []interface{}{
+		map[string]interface{}{
+			"type":   "TextBlock",
+			"text":   fmt.Sprintf("🎉 空き枠発見！(%d件)", len(slots)),
+			"size":   "ExtraLarge",
+			"weight": "Bolder",
+			"color":  "Good",
+		},
+	}
+	for _, slot := range slots {
+		body = append(body, map[string]interface{}{
+			"type":      "Container",
+			"separator": true,
+			"spacing":   "Medium",
+			"items": []interface{}{
+				map[string]interface{}{
+					"type":   "TextBlock",
+					"text":   "📍 " + slot.Location,
+					"weight": "Bolder",
+					"color":  "Good",
+					"wrap":   true,
+				},
+				map[string]interface{}{
+					"type":     "TextBlock",
+					"text":     "👥 " + slot.Category,
+					"size":     "Small",
+					"isSubtle": true,
+					"spacing":  "Small",
+					"wrap":     true,
+				},
+				map[string]interface{}{
+					"type":     "TextBlock",
+					"text":     "📅 " + slot.When(),
+					"size":     "Small",
+					"isSubtle": true,
+					"spacing":  "Small",
+				},
+			},
+		})
+	}
+
+	actions := []interface{}{
+		map[string]interface{}{
+			"type":  "Action.OpenUrl",
+			"title": "予約する",
+			"url":   notify.ReserveURL,
+			"style": "positive",
+		},
+	}
+	return card(body, actions)
+}
+
+// card wraps an Adaptive Card in the message format of incoming webhooks
+func card(body, actions []interface{}) map[string]interface{} {
+	content := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if len(actions) > 0 {
+		content["actions"] = actions
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content":     content,
+			},
+		},
+	}
+}
+
+func (c *Client) send(payload interface{}) error {
+	if c.webhookURL == "" {
+		return fmt.Errorf("teams configuration is incomplete")
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %v", err)
+	}
+
+	resp, err := c.client.Post(c.webhookURL, "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to send Teams message: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Classic connectors answer 200, Workflows webhooks 202
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("teams message failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
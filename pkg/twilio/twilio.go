@@ -0,0 +1,158 @@
+package twilio
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"policeScrapper/pkg/notify"
+	"policeScrapper/pkg/scraper"
+)
+
+// APIURL is the base URL of the Twilio REST API
+const APIURL = "https://api.twilio.com/2010-04-01"
+
+// Client sends slot notifications as SMS through Twilio. SMS arrive over
+// the cellular network, so they still get through when mobile data for chat
+// apps is unreliable.
+type Client struct {
+	accountSID string
+	authToken  string
+	from       string
+	to         []string
+	noNotify   bool
+	client     *http.Client
+}
+
+// NewClient creates a new Twilio client sending from one number to every
+// number of to (E.164, e.g. +819012345678)
+func NewClient(accountSID, authToken, from string, to []string, noNotify bool) *Client {
+	return &Client{
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		to:         to,
+		noNotify:   noNotify,
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// NotifyAvailableSlots texts a one-line summary of the slots to every
+// number. A failed number doesn't keep the others from being texted.
+func (c *Client) NotifyAvailableSlots(slots []scraper.Slot) error {
+	if len(slots) == 0 {
+		return nil
+	}
+
+	if c.noNotify {
+		log.Println("💬 SMS skipped (--no-notify)")
+		return nil
+	}
+
+	sent, err := c.sendAll(notify.Text(notify.ProfileSMS, slots))
+	if sent > 0 {
+		log.Printf("💬 SMS sent to %d number(s)", sent)
+	}
+	return err
+}
+
+// Alert texts a plain text message to every number, even if some fail
+func (c *Client) Alert(text string) error {
+	if c.noNotify {
+		log.Printf("💬 Alert skipped (--no-notify): %s", text)
+		return nil
+	}
+	_, err := c.sendAll(notify.AlertText(notify.ProfileSMS, text))
+	return err
+}
+
+// Call phones every number and reads the text out loud, even if some
+// calls fail
+func (c *Client) Call(text string) error {
+	if c.noNotify {
+		log.Printf("📞 Call skipped (--no-notify): %s", text)
+		return nil
+	}
+	if err := c.complete(); err != nil {
+		return err
+	}
+
+	var twiml strings.Builder
+	twiml.WriteString("<Response><Say>")
+	if err := xml.EscapeText(&twiml, []byte(text)); err != nil {
+		return err
+	}
+	twiml.WriteString("</Say></Response>")
+
+	var errs []error
+	for _, to := range c.to {
+		form := url.Values{"From": {c.from}, "To": {to}, "Twiml": {twiml.String()}}
+		if err := c.post("Calls.json", form); err != nil {
+			errs = append(errs, fmt.Errorf("failed to call %s: %v", to, err))
+		}
+	}
+	if called := len(c.to) - len(errs); called > 0 {
+		log.Printf("📞 Called %d number(s)", called)
+	}
+	return errors.Join(errs...)
+}
+
+// sendAll texts body to every number, and returns how many were texted
+func (c *Client) sendAll(body string) (int, error) {
+	if err := c.complete(); err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, to := range c.to {
+		form := url.Values{"From": {c.from}, "To": {to}, "Body": {body}}
+		if err := c.post("Messages.json", form); err != nil {
+			errs = append(errs, fmt.Errorf("failed to text %s: %v", to, err))
+		}
+	}
+	return len(c.to) - len(errs), errors.Join(errs...)
+}
+
+func (c *Client) complete() error {
+	if c.accountSID == "" || c.authToken == "" || c.from == "" {
+		return fmt.Errorf("twilio configuration is incomplete")
+	}
+	return nil
+}
+
+// apiError is the body of failed Twilio API requests
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// post creates a resource (a message or a call) of the account
+func (c *Client) post(resource string, form url.Values) error {
+	endpoint := fmt.Sprintf("%s/Accounts/%s/%s", APIURL, url.PathEscape(c.accountSID), resource)
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.SetBasicAuth(c.accountSID, c.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach twilio: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr apiError
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("twilio error %d: %s", apiErr.Code, apiErr.Message)
+		}
+		return fmt.Errorf("twilio request failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
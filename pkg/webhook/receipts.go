@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Receipt records the outcome of one delivery, after any retries
+type Receipt struct {
+	ID        string    `json:"id"`    // Idempotency key of the delivery
+	Event     string    `json:"event"` // "slots" or "alert"
+	URL       string    `json:"url"`
+	Attempts  int       `json:"attempts"`
+	Status    int       `json:"status,omitempty"` // HTTP status of the last attempt, 0 if none
+	Delivered bool      `json:"delivered"`
+	Error     string    `json:"error,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// receiptsMu serializes writes, as several webhooks may share the file
+var receiptsMu sync.Mutex
+
+// recordReceipt appends the receipt to the JSON lines file at path
+func recordReceipt(path string, r Receipt) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	receiptsMu.Lock()
+	defer receiptsMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600) // #nosec G304 - path comes from configuration
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// PruneReceipts removes the receipts at path of deliveries before before,
+// returning how many
+func PruneReceipts(path string, before time.Time) (int, error) {
+	receiptsMu.Lock()
+	defer receiptsMu.Unlock()
+
+	data, err := os.ReadFile(path) // #nosec G304 - path comes from configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var kept []byte
+	pruned := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		var r Receipt
+		if json.Unmarshal(line, &r) == nil && r.At.Before(before) {
+			pruned++
+			continue
+		}
+		kept = append(kept, line...)
+	}
+	if pruned == 0 {
+		return 0, nil
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, kept, 0600); err != nil {
+		return 0, err
+	}
+	return pruned, os.Rename(tmp, path)
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	"policeScrapper/pkg/scraper"
+)
+
+// DefaultTemplate posts the slots as JSON
+const DefaultTemplate = `{"count": {{len .Slots}}, "slots": {{json .Slots}}, "url": {{json .URL}}}`
+
+// DefaultRetries is how many times a failed request is retried
+const DefaultRetries = 3
+
+// Config describes a webhook endpoint
+type Config struct {
+	URL           string
+	Method        string            // POST by default
+	ContentType   string            // application/json by default, or e.g. application/x-www-form-urlencoded
+	Headers       map[string]string // Extra headers, e.g. Authorization
+	Template      string            // Body for found slots, DefaultTemplate if empty
+	AlertTemplate string            // Body for operational alerts, alerts aren't sent if empty
+	Retries       int               // Retries of failed requests
+	Secret        string            // Signs requests with HMAC-SHA256 if set
+	Receipts      string            // JSON lines file recording deliveries, empty disables it
+}
+
+// Headers of every request. Retries of a delivery keep its idempotency key,
+// so the receiver can deduplicate them, but get a new timestamp and
+// signature. The signature covers the idempotency key, so a captured
+// request can't be replayed under another key.
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderTimestamp      = "X-Scraper-Timestamp" // Unix seconds
+	HeaderSignature      = "X-Scraper-Signature" // sha256=hex(HMAC(secret, timestamp + "." + key + "." + body))
+)
+
+// Sign returns the signature of a body sent at the Unix timestamp with the
+// idempotency key id
+func Sign(secret, timestamp, id string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + id + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SlotData is the data of the slot template
+type SlotData struct {
+	Slots []scraper.Slot
+	URL   string // Reservation page
+}
+
+// AlertData is the data of the alert template
+type AlertData struct {
+	Text string
+}
+
+// Client posts notifications to a webhook, rendering the body from templates
+type Client struct {
+	cfg      Config
+	body     *template.Template
+	alert    *template.Template
+	url      string
+	noNotify bool
+	client   *http.Client
+}
+
+// funcs are available in templates: json encodes a value as JSON, so it
+// can be embedded safely in a JSON body
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// NewClient parses the templates of cfg. reserveURL is passed to the slot
+// template as .URL.
+func NewClient(cfg Config, reserveURL string, noNotify bool) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook URL is missing")
+	}
+	if cfg.Method == "" {
+		cfg.Method = http.MethodPost
+	}
+	if cfg.ContentType == "" {
+		cfg.ContentType = "application/json"
+	}
+	if cfg.Template == "" {
+		cfg.Template = DefaultTemplate
+	}
+
+	c := &Client{
+		cfg:      cfg,
+		url:      reserveURL,
+		noNotify: noNotify,
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+	var err error
+	if c.body, err = template.New("slots").Funcs(funcs).Parse(cfg.Template); err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %v", err)
+	}
+	if cfg.AlertTemplate != "" {
+		if c.alert, err = template.New("alert").Funcs(funcs).Parse(cfg.AlertTemplate); err != nil {
+			return nil, fmt.Errorf("invalid webhook alert template: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// NotifyAvailableSlots posts the rendered slot template
+func (c *Client) NotifyAvailableSlots(slots []scraper.Slot) error {
+	if len(slots) == 0 {
+		return nil
+	}
+	if c.noNotify {
+		log.Println("🪝 Webhook skipped (--no-notify)")
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := c.body.Execute(&body, SlotData{Slots: slots, URL: c.url}); err != nil {
+		return fmt.Errorf("failed to render webhook body: %v", err)
+	}
+	if err := c.post("slots", body.Bytes()); err != nil {
+		return err
+	}
+	log.Printf("🪝 Webhook sent")
+	return nil
+}
+
+// Alert posts the rendered alert template, if there is one
+func (c *Client) Alert(text string) error {
+	if c.alert == nil {
+		return nil
+	}
+	if c.noNotify {
+		log.Printf("🪝 Alert skipped (--no-notify): %s", text)
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := c.alert.Execute(&body, AlertData{Text: text}); err != nil {
+		return fmt.Errorf("failed to render webhook alert: %v", err)
+	}
+	return c.post("alert", body.Bytes())
+}
+
+// post delivers the body, retrying network errors, 429 and 5xx responses,
+// and records the receipt
+func (c *Client) post(event string, body []byte) error {
+	receipt := Receipt{ID: newID(), Event: event, URL: c.cfg.URL}
+	var err error
+	for attempt := 0; attempt <= c.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		receipt.Attempts++
+		var retry bool
+		if receipt.Status, retry, err = c.send(receipt.ID, body); err == nil || !retry {
+			break
+		}
+		log.Printf("⚠️ Webhook attempt %d failed: %v", attempt+1, err)
+	}
+
+	if c.cfg.Receipts != "" {
+		receipt.Delivered = err == nil
+		if err != nil {
+			receipt.Error = err.Error()
+		}
+		receipt.At = time.Now()
+		if rerr := recordReceipt(c.cfg.Receipts, receipt); rerr != nil {
+			log.Printf("Error recording webhook receipt: %v", rerr)
+		}
+	}
+	return err
+}
+
+func (c *Client) send(id string, body []byte) (status int, retry bool, err error) {
+	req, err := http.NewRequest(c.cfg.Method, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", c.cfg.ContentType)
+	for k, v := range c.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(HeaderIdempotencyKey, id)
+	if c.cfg.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderSignature, Sign(c.cfg.Secret, timestamp, id, body))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, true, fmt.Errorf("failed to send webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return resp.StatusCode, retry, fmt.Errorf("webhook failed with status: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, false, nil
+}
+
+// newID returns a random delivery ID
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Unique enough to deduplicate retries
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
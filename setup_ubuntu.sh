@@ -13,6 +13,7 @@ sudo apt-get update
 sudo apt-get install -y \
     google-chrome-stable \
     golang \
+    gcc \
     supervisor
 
 # Create directories
@@ -21,7 +22,7 @@ mkdir -p ~/bin
 
 # Build the scraper
 echo "Building scraper..."
-go build -o ~/bin/scraper cmd/scraper/main.go
+go build -o ~/bin/scraper ./cmd/scraper
 
 # Create supervisor environment file
 echo "Creating supervisor environment file..."